@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/brodo/kubectl-k6/internal"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var tolerances internal.Tolerances
+
+// compareCmd represents the compare command
+var compareCmd = &cobra.Command{
+	Use:   "compare <baseline> <current>",
+	Short: "Compare the summary of a run with a baseline and detect regressions",
+	Long: `Compares the p95 and p99 of 'http_req_duration', the 'http_req_failed' rate and the 'http_reqs' rate of two
+summaries and fails if the current one is worse than the baseline by more than the configured tolerances.
+
+Summaries are either local JSON files, baseline config maps in the namespace ('configmap/<name>', see 'run --save-baseline')
+or the results of runs saved in the cluster ('run/<run id>', see the 'list' command).
+For example:
+
+kubectl-k6 compare baseline.json current.json
+kubectl-k6 compare configmap/checkout-baseline run/l4q5ph7vsplt2pxkkv4l`,
+	Args: cobra.ExactArgs(2),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return bindToleranceFlags(cmd)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		loadTolerances()
+		err, kc := internal.ConnectK8s(k8sConfig, viper.GetString("namespace"))
+		if err != nil {
+			return err
+		}
+		resultsKc := kc.WithNamespace(resultsNamespace())
+		store := internal.SummaryStore{Baselines: &kc, Results: &resultsKc}
+		baseline, err := store.Load(context.Background(), args[0])
+		if err != nil {
+			return err
+		}
+		current, err := store.Load(context.Background(), args[1])
+		if err != nil {
+			return err
+		}
+		comparisons := internal.CompareSummaries(baseline, current, tolerances)
+		fmt.Printf("Comparing '%s' with baseline '%s':\n%s", args[1], args[0], internal.FormatComparisons(comparisons))
+		if msg := internal.MissingWarning(comparisons); msg != "" {
+			printWarning(msg)
+		}
+		return internal.RegressionError(comparisons)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(compareCmd)
+	compareCmd.SilenceUsage = true
+	compareCmd.Flags().StringP("namespace", "n", "k6-operator-system", "k8s namespace of baseline config maps")
+	addResultsNamespaceFlag(compareCmd)
+	addToleranceFlags(compareCmd)
+	viper.SetDefault("tolerances.p95", 10.0)
+	viper.SetDefault("tolerances.p99", 10.0)
+	viper.SetDefault("tolerances.errorRate", 1.0)
+	viper.SetDefault("tolerances.throughput", 10.0)
+}
+
+func addToleranceFlags(cmd *cobra.Command) {
+	cmd.Flags().Float64Var(&tolerances.P95, "tolerance-p95", 10, "Allowed increase of the p95 of 'http_req_duration' compared to the baseline, in percent")
+	cmd.Flags().Float64Var(&tolerances.P99, "tolerance-p99", 10, "Allowed increase of the p99 of 'http_req_duration' compared to the baseline, in percent")
+	cmd.Flags().Float64Var(&tolerances.ErrorRate, "tolerance-error-rate", 1, "Allowed increase of the 'http_req_failed' rate compared to the baseline, in percentage points")
+	cmd.Flags().Float64Var(&tolerances.Throughput, "tolerance-throughput", 10, "Allowed decrease of the 'http_reqs' rate compared to the baseline, in percent")
+}
+
+// bindToleranceFlags binds the command's flags to viper. Commands that share flag names with `run` bind
+// their flags when they are executed, so the flags of the executed command take precedence.
+func bindToleranceFlags(cmd *cobra.Command) error {
+	if err := viper.BindPFlags(cmd.Flags()); err != nil {
+		return err
+	}
+	for key, flag := range map[string]string{
+		"tolerances.p95":        "tolerance-p95",
+		"tolerances.p99":        "tolerance-p99",
+		"tolerances.errorRate":  "tolerance-error-rate",
+		"tolerances.throughput": "tolerance-throughput",
+	} {
+		if err := viper.BindPFlag(key, cmd.Flags().Lookup(flag)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadTolerances() {
+	tolerances.P95 = viper.GetFloat64("tolerances.p95")
+	tolerances.P99 = viper.GetFloat64("tolerances.p99")
+	tolerances.ErrorRate = viper.GetFloat64("tolerances.errorRate")
+	tolerances.Throughput = viper.GetFloat64("tolerances.throughput")
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/brodo/kubectl-k6/internal"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var scaleOptions struct {
+	vus int64
+}
+
+// pauseCmd represents the pause command
+var pauseCmd = &cobra.Command{
+	Use:   "pause <run id>",
+	Short: "Pause all runners of a running test",
+	Long: `Pauses k6 in every runner pod of a running test through its REST API, so no new iterations are started.
+The run id is printed when the test starts; the name of a TestRun started from a manifest works as well.
+The runners of all zones of a run are controlled together.
+For example:
+
+kubectl-k6 pause l4q5ph7vsplt2pxkkv4l`,
+	Args: cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return viper.BindPFlags(cmd.Flags())
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setPaused(args[0], true)
+	},
+}
+
+// resumeCmd represents the resume command
+var resumeCmd = &cobra.Command{
+	Use:   "resume <run id>",
+	Short: "Resume all runners of a paused test",
+	Long: `Resumes k6 in every runner pod of a test that was paused with 'pause'.
+For example:
+
+kubectl-k6 resume l4q5ph7vsplt2pxkkv4l`,
+	Args: cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return viper.BindPFlags(cmd.Flags())
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setPaused(args[0], false)
+	},
+}
+
+// scaleCmd represents the scale command
+var scaleCmd = &cobra.Command{
+	Use:   "scale <run id> --vus N",
+	Short: "Change the number of VUs of a running test",
+	Long: `Sets the VUs of a running test. The VUs are split across the runner pods proportionally to their maximum VUs,
+which is raised if necessary. k6 only allows changing the VUs of scenarios with the 'externally-controlled' executor.
+For example:
+
+kubectl-k6 scale l4q5ph7vsplt2pxkkv4l --vus 300`,
+	Args: cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return viper.BindPFlags(cmd.Flags())
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if scaleOptions.vus < 0 {
+			return fmt.Errorf("--vus must not be negative")
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		runners, err := forwardRunners(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		shares, err := internal.ScaleRunners(ctx, runners, scaleOptions.vus)
+		if shares != nil {
+			for i, r := range runners {
+				fmt.Printf("Pod '%s': %d VUs\n", r.Pod, shares[i])
+			}
+		}
+		return err
+	},
+}
+
+// setPaused pauses or resumes all runners of the run.
+func setPaused(runId string, paused bool) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runners, err := forwardRunners(ctx, runId)
+	if err != nil {
+		return err
+	}
+	if err := internal.PauseRunners(ctx, runners, paused); err != nil {
+		return err
+	}
+	state := "Resumed"
+	if paused {
+		state = "Paused"
+	}
+	fmt.Printf("%s %d runner(s) of '%s'\n", state, len(runners), runId)
+	return nil
+}
+
+// forwardRunners forwards to the runners of the test runs of the run id, i.e. of all its zones, or, for runs of
+// manifests, of the test run with that name.
+func forwardRunners(ctx context.Context, runId string) ([]internal.Runner, error) {
+	err, kc := internal.ConnectK8s(k8sConfig, viper.GetString("namespace"))
+	if err != nil {
+		return nil, err
+	}
+	resNames, err := kc.RunTestRunNames(ctx, runId)
+	if err != nil {
+		return nil, err
+	}
+	if len(resNames) == 0 {
+		return nil, fmt.Errorf("run '%s': %w", runId, internal.ErrNoRunningRunners)
+	}
+	return kc.ForwardRunners(ctx, resNames...)
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{pauseCmd, resumeCmd, scaleCmd} {
+		rootCmd.AddCommand(cmd)
+		cmd.SilenceUsage = true
+		cmd.Flags().StringP("namespace", "n", defaultNamespace, "k8s namespace the tests run in")
+	}
+	scaleCmd.Flags().Int64Var(&scaleOptions.vus, "vus", 0, "Number of VUs across all runners")
+	cobra.CheckErr(scaleCmd.MarkFlagRequired("vus"))
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/brodo/kubectl-k6/internal"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check that the cluster and your permissions are ready to run tests",
+	Long: `Runs pre-flight checks against the current context and prints a checklist with fixes for every failed check.
+It checks that the cluster is reachable, the k6 operator is installed and running, you have all permissions the plugin
+needs for the configured features, the namespace and the image pull secret exist and that there is a default storage
+class for folder mode. The features are read from the flags and the config file like in the 'run' command.
+For example:
+
+kubectl-k6 doctor --namespace load-tests`,
+	Args: cobra.NoArgs,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return viper.BindPFlags(cmd.Flags())
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		features := configuredFeatures()
+		fmt.Println(activeContextCheck().String())
+		err, kc := internal.ConnectK8s(k8sConfig, config.namespace)
+		if err != nil {
+			return err
+		}
+		results := []internal.CheckResult{kc.CheckConnection()}
+		if results[0].Status != internal.CheckFailed {
+			// CheckK6API comes first, the permission checks use the negotiated API
+			results = append(results, kc.CheckK6API(), kc.CheckOperatorPod(ctx), kc.CheckNamespace(ctx))
+			results = append(results, kc.CheckPermissions(ctx, features)...)
+			results = append(results, kc.CheckImagePullSecret(ctx, config.imagePullSecret), kc.CheckDefaultStorageClass(ctx, features.Folder))
+		}
+		failed := 0
+		for _, result := range results {
+			fmt.Println(result.String())
+			if result.Status == internal.CheckFailed {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d check(s) failed", failed)
+		}
+		fmt.Println("All checks passed, you are ready to run tests!")
+		return nil
+	},
+}
+
+// activeContextCheck reports the context of the k8s config that is used.
+func activeContextCheck() internal.CheckResult {
+	name := "active context"
+	loadingRules := &clientcmd.ClientConfigLoadingRules{ExplicitPath: k8sConfigPath}
+	rawConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{}).RawConfig()
+	if err != nil {
+		return internal.CheckResult{Name: name, Status: internal.CheckWarning, Detail: err.Error()}
+	}
+	if rawConfig.CurrentContext == "" {
+		return internal.CheckResult{Name: name, Status: internal.CheckWarning, Detail: fmt.Sprintf("no context is selected in '%s'", k8sConfigPath),
+			Fix: "select one with 'kubectl config use-context <context>'"}
+	}
+	return internal.CheckResult{Name: name, Status: internal.CheckPassed, Detail: fmt.Sprintf("'%s' from '%s'", rawConfig.CurrentContext, k8sConfigPath)}
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	doctorCmd.SilenceUsage = true
+	doctorCmd.Flags().StringP("namespace", "n", "k6-operator-system", "k8s namespace the tests run in")
+	doctorCmd.Flags().StringP("ips", "s", "", "The name of the secret to use for pulling the OCI image")
+	doctorCmd.Flags().String("folder", "", "Checks the requirements of folder mode if set")
+}
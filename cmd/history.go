@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/brodo/kubectl-k6/internal"
+	"github.com/spf13/cobra"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var historyFilter struct {
+	script  string
+	outcome string
+	since   time.Duration
+	limit   int
+}
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List the runs started from this machine",
+	Long: `Every run is recorded in a local history in the user's config directory. This command lists the most recent runs.
+For example:
+
+kubectl-k6 history --script checkout --outcome error
+kubectl-k6 history show <run id>`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err, history := internal.NewHistory()
+		if err != nil {
+			return err
+		}
+		records, err := history.Records()
+		if err != nil {
+			return err
+		}
+		var filtered []internal.HistoryRecord
+		for i := len(records) - 1; i >= 0 && (historyFilter.limit <= 0 || len(filtered) < historyFilter.limit); i-- {
+			r := records[i]
+			if historyFilter.script != "" && !strings.Contains(r.ScriptPath, historyFilter.script) {
+				continue
+			}
+			if historyFilter.outcome != "" && r.Outcome != historyFilter.outcome {
+				continue
+			}
+			if historyFilter.since > 0 && time.Since(r.Start) > historyFilter.since {
+				continue
+			}
+			filtered = append(filtered, r)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "RUN ID\tSTARTED\tDURATION\tOUTCOME\tSCRIPT\tCOMMIT")
+		for _, r := range filtered {
+			commit := r.GitCommit
+			if len(commit) > 8 {
+				commit = commit[:8]
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", r.RunId, r.Start.Format(time.DateTime), r.End.Sub(r.Start).Round(time.Second), r.Outcome, r.ScriptPath, commit)
+		}
+		return w.Flush()
+	},
+}
+
+// historyShowCmd represents the history show command
+var historyShowCmd = &cobra.Command{
+	Use:   "show <run id>",
+	Short: "Show the details of a run from the history",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err, history := internal.NewHistory()
+		if err != nil {
+			return err
+		}
+		r, err := history.Find(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Run ID:      %s\n", r.RunId)
+		fmt.Printf("Script:      %s\n", r.ScriptPath)
+		fmt.Printf("Script hash: %s\n", r.ScriptHash)
+		fmt.Printf("Git commit:  %s\n", r.GitCommit)
+		fmt.Printf("Started:     %s\n", r.Start.Format(time.RFC3339))
+		fmt.Printf("Duration:    %s\n", r.End.Sub(r.Start).Round(time.Millisecond))
+		fmt.Printf("Outcome:     %s\n", r.Outcome)
+		if r.Error != "" {
+			fmt.Printf("Error:       %s\n", r.Error)
+		}
+		fmt.Println("Configuration:")
+		keys := make([]string, 0, len(r.Config))
+		for k := range r.Config {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Printf("   %s: %v\n", k, r.Config[k])
+		}
+		if len(r.Stages) > 0 {
+			fmt.Println("Stages:")
+			for _, stage := range r.Stages {
+				fmt.Printf("   %s: %s\n", stage.Name, stage.Duration.Round(time.Millisecond))
+			}
+		}
+		if r.Summary != nil {
+			fmt.Println(r.Summary.String())
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.AddCommand(historyShowCmd)
+	historyCmd.SilenceUsage = true
+	historyCmd.Flags().StringVar(&historyFilter.script, "script", "", "Only list runs of scripts whose path contains this string")
+	historyCmd.Flags().StringVar(&historyFilter.outcome, "outcome", "", "Only list runs with this outcome: passed, thresholds-failed, regression or error")
+	historyCmd.Flags().DurationVar(&historyFilter.since, "since", 0, "Only list runs started within this duration, e.g. 24h")
+	historyCmd.Flags().IntVar(&historyFilter.limit, "limit", 20, "Maximum number of runs to list, 0 lists all runs")
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/brodo/kubectl-k6/internal"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+var listFilter struct {
+	script  string
+	outcome string
+	limit   int
+}
+
+// listCmd represents the list command
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the results of runs saved in the cluster",
+	Long: `Lists the results records that 'run' saves in the cluster, including runs that teammates started from other machines.
+Use 'compare run/<run id> ...' to compare the summaries of two runs.
+For example:
+
+kubectl-k6 list --script checkout --outcome passed`,
+	Args: cobra.NoArgs,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return viper.BindPFlags(cmd.Flags())
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err, kc := internal.ConnectK8s(k8sConfig, resultsNamespace())
+		if err != nil {
+			return err
+		}
+		records, err := kc.ListResults(context.Background(), listFilter.script, listFilter.outcome, func(msg string) {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", msg)
+		})
+		if err != nil {
+			return err
+		}
+		if listFilter.limit > 0 && len(records) > listFilter.limit {
+			records = records[:listFilter.limit]
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "RUN ID\tSTARTED\tDURATION\tOUTCOME\tUSER\tSCRIPT\tCOMMIT")
+		for _, r := range records {
+			commit := r.GitCommit
+			if len(commit) > 8 {
+				commit = commit[:8]
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.RunId, r.Start.Format(time.DateTime), r.End.Sub(r.Start).Round(time.Second), r.Outcome, r.User, r.ScriptPath, commit)
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+	listCmd.SilenceUsage = true
+	listCmd.Flags().StringP("namespace", "n", "k6-operator-system", "k8s namespace the tests run in")
+	addResultsNamespaceFlag(listCmd)
+	listCmd.Flags().StringVar(&listFilter.script, "script", "", "Only list runs of this script, e.g. 'checkout' for 'tests/checkout.js'")
+	listCmd.Flags().StringVar(&listFilter.outcome, "outcome", "", "Only list runs with this outcome: passed, thresholds-failed, regression or error")
+	listCmd.Flags().IntVar(&listFilter.limit, "limit", 20, "Maximum number of runs to list, 0 lists all runs")
+}
+
+func addResultsNamespaceFlag(cmd *cobra.Command) {
+	cmd.Flags().String("results-namespace", "", "k8s namespace the results of runs are saved in (default is the namespace the tests run in)")
+}
+
+// resultsNamespace returns the namespace the results records are saved in.
+func resultsNamespace() string {
+	if ns := viper.GetString("results-namespace"); ns != "" {
+		return ns
+	}
+	return viper.GetString("namespace")
+}
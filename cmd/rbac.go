@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/brodo/kubectl-k6/internal"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	rbac "k8s.io/api/rbac/v1"
+	"os"
+)
+
+var rbacOptions struct {
+	name                    string
+	serviceAccount          string
+	serviceAccountNamespace string
+}
+
+// rbacCmd represents the rbac command
+var rbacCmd = &cobra.Command{
+	Use:   "rbac",
+	Short: "Print the roles and bindings a service account needs to run tests",
+	Long: `Prints a Role and a RoleBinding that grant a service account exactly the permissions the configured features need.
+If folder mode is used, a ClusterRole and a ClusterRoleBinding for persistent volumes are added. The features are read
+from the flags and the config file like in the 'run' command.
+For example:
+
+kubectl-k6 rbac --namespace load-tests --service-account ci | kubectl apply -f -`,
+	Args: cobra.NoArgs,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := viper.BindPFlags(cmd.Flags()); err != nil {
+			return err
+		}
+		return bindSidecarFlag(cmd)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		features := configuredFeatures()
+		k6API := internal.DefaultK6API
+		if err, kc := internal.NewK8sClient(k8sConfig, config.namespace); err == nil {
+			k6API = kc.K6API()
+		} else {
+			fmt.Fprintf(os.Stderr, "Could not negotiate the k6 operator API, assuming %s: %v\n", k6API, err)
+		}
+		saNamespace := rbacOptions.serviceAccountNamespace
+		if saNamespace == "" {
+			saNamespace = config.namespace
+		}
+		subject := rbac.Subject{Kind: rbac.ServiceAccountKind, Name: rbacOptions.serviceAccount, Namespace: saNamespace}
+		manifests := internal.RBACManifests(internal.RequiredPermissions(k6API, features), rbacOptions.name, config.namespace, subject)
+		out, err := internal.ManifestsToYAML(manifests)
+		if err != nil {
+			return err
+		}
+		fmt.Print(out)
+		return nil
+	},
+}
+
+// configuredFeatures returns the features the configuration of the run command uses.
+func configuredFeatures() internal.Features {
+	loadRunConfig()
+	features := internal.Features{
+		Folder:       config.folder != "",
+		EnvFile:      config.envFile != "",
+		SecretRefs:   len(config.runner.SecretNames()) > 0,
+		BundleScript: config.bundleScript,
+		SaveBaseline: internal.IsConfigMapRef(config.saveBaseline),
+		SaveResults:  config.saveResults,
+		PruneResults: config.saveResults && config.keepResults > 0,
+		ReadResults:  internal.IsRunRef(config.baseline),
+		QuitSidecars: config.sidecars.Quit,
+		Progress:     config.progress,
+		Zones:        len(config.zones) > 0,
+	}
+	if ns := resultsNamespace(); ns != config.namespace {
+		features.ResultsNamespace = ns
+	}
+	features.Guardrails = !config.guardrails.Empty()
+	for _, ns := range config.guardrails.RestartNamespaces() {
+		if ns == config.namespace {
+			ns = ""
+		}
+		features.GuardrailNamespaces = append(features.GuardrailNamespaces, ns)
+	}
+	return features
+}
+
+func init() {
+	rootCmd.AddCommand(rbacCmd)
+	rbacCmd.SilenceUsage = true
+	rbacCmd.Flags().StringP("namespace", "n", "k6-operator-system", "k8s namespace the tests run in")
+	rbacCmd.Flags().StringVar(&rbacOptions.name, "name", "kubectl-k6", "Name of the roles and bindings")
+	rbacCmd.Flags().StringVar(&rbacOptions.serviceAccount, "service-account", "kubectl-k6", "Name of the service account the plugin runs as")
+	rbacCmd.Flags().StringVar(&rbacOptions.serviceAccountNamespace, "service-account-namespace", "", "Namespace of the service account (default is the namespace the tests run in)")
+	rbacCmd.Flags().String("folder", "", "Grants the permissions folder mode needs if set")
+	rbacCmd.Flags().String("env-file", "", "Grants the permissions to upload .env files if set")
+	rbacCmd.Flags().String("baseline", "", "Grants the permissions to read the baseline if it is stored in the cluster")
+	rbacCmd.Flags().Bool("bundle-script", false, "Grants the permissions to upload the bundled script of manifests if set")
+	rbacCmd.Flags().String("save-baseline", "", "Grants the permissions to save baselines in config maps if set to 'configmap/<name>'")
+	rbacCmd.Flags().Bool("save-results", true, "Grants the permissions to save results records in the cluster")
+	rbacCmd.Flags().Int("keep-results", defaultKeepResults, "Grants the permissions to delete old results records if larger than 0")
+	rbacCmd.Flags().Bool("progress", true, "Grants the permissions to show the progress of runs")
+	rbacCmd.Flags().Bool("quit-sidecars", false, "Grants the permissions to shut down the sidecars of the runner pods if set")
+	addResultsNamespaceFlag(rbacCmd)
+}
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/brodo/kubectl-k6/internal"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+)
+
+var renderOptions struct {
+	dryRun    string
+	output    string
+	outputDir string
+}
+
+// renderCmd represents the render command
+var renderCmd = &cobra.Command{
+	Use:   "render [k6 script path]",
+	Short: "Print the objects a run would create without running the test",
+	Long: `Prints every object 'run' would create: the config map with the bundle or the persistent volume and claim in
+folder mode, the secret with the variables of the .env file and the test run. It accepts the same flags and config file
+as 'run' and is the same as 'run --dry-run=client'. Values that are configured for redaction are replaced.
+For example:
+
+kubectl-k6 render myTestScript.js -o json
+kubectl-k6 render myTestScript.js --output-dir manifests/`,
+	Args: cobra.MaximumNArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return bindToleranceFlags(cmd)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		loadRunConfig()
+		if (len(args) == 1) == (config.manifest != "") {
+			return fmt.Errorf("either a script or a manifest ('-f') must be given")
+		}
+		if config.manifest != "" {
+			return renderManifest(config.manifest, internal.DryRunClient)
+		}
+		return renderScript(args[0], internal.DryRunClient)
+	},
+}
+
+func addRenderFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&renderOptions.output, "output", "o", "yaml", "Format of the printed objects in dry run mode, 'yaml' or 'json'")
+	cmd.Flags().StringVar(&renderOptions.outputDir, "output-dir", "", "Writes the objects into one file each in this directory instead of printing them in dry run mode")
+}
+
+// renderScript prints the objects that running the script would create. In server mode, they are submitted to the
+// cluster with `dryRun=All` and the objects the server returned are printed.
+func renderScript(scriptPath string, mode internal.DryRunMode) error {
+	sps := internal.NewScriptProperties(scriptPath)
+	templateVars := internal.NewTemplateVars(sps)
+	err, k6args := templateVars.ApplyArgTemp(config.k6Arguments)
+	if err != nil {
+		return err
+	}
+	if err := templateVars.ApplyEnvTemp(&config.k6Env); err != nil {
+		return err
+	}
+	// the objects contain the values, they are replaced when they are printed
+	redactor.RedactEnv(config.k6Env)
+	redactor.RedactArgs(k6args)
+	err, kc := internal.ConnectK8s(k8sConfig, config.namespace)
+	if err != nil {
+		return err
+	}
+	if err := kc.NegotiateK6API(); err != nil {
+		if mode == internal.DryRunServer {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Could not negotiate the k6 operator API, assuming %s: %v\n", kc.K6API(), err)
+	}
+
+	k6Config := newK6Config(scriptPath, k6args)
+	manifests := &internal.Manifests{}
+	if config.folder == "" {
+		err, jsBundle := internal.Bundle(&sps, config.minify, config.summary)
+		if err != nil {
+			return err
+		}
+		if len(jsBundle) > 1048576 {
+			return fmt.Errorf("the bundled script is too large: %d MB, max 1 MB - please use `--folder`", len(jsBundle)/1_048_576)
+		}
+		manifests.ConfigMap = kc.NewScriptConfigMap(&sps, string(jsBundle))
+	} else {
+		folder, err := filepath.Abs(config.folder)
+		if err != nil {
+			return err
+		}
+		manifests.PV = internal.NewFolderPV(folder, sps.ConfigMapName(), config.namespace)
+		manifests.PVC = internal.NewFolderPVC(sps.ConfigMapName(), sps.ConfigMapName(), config.namespace)
+	}
+	if config.envFile != "" {
+		env, err := internal.ParseEnvFile(config.envFile)
+		if err != nil {
+			return err
+		}
+		for _, v := range env {
+			redactor.AddValues(v)
+		}
+		manifests.Secret = kc.NewEnvSecret(&sps, env)
+		k6Config.EnvSecret = sps.EnvSecretName()
+	}
+	if len(config.zones) > 0 {
+		for _, zone := range config.zones {
+			zoneConfig := zone.K6Config(k6Config)
+			zoneVars := templateVars
+			zoneVars.ScriptProperties = zone.ScriptProperties(sps)
+			testRun, err := kc.NewCustomResource(&zoneConfig, &zoneVars)
+			if err != nil {
+				return err
+			}
+			manifests.ZoneTestRuns = append(manifests.ZoneTestRuns, testRun)
+		}
+	} else if manifests.TestRun, err = kc.NewCustomResource(&k6Config, &templateVars); err != nil {
+		return err
+	}
+
+	return printManifests(&kc, manifests, mode)
+}
+
+// renderManifest prints the objects that running a pre-authored manifest would create.
+func renderManifest(manifestPath string, mode internal.DryRunMode) error {
+	sps := internal.NewScriptProperties(manifestPath)
+	templateVars := internal.NewTemplateVars(sps)
+	manifest, err := internal.LoadTestRunManifest(manifestPath, &templateVars)
+	if err != nil {
+		return err
+	}
+	if ns := manifest.TestRun.Namespace; ns != "" {
+		config.namespace = ns
+	}
+	manifest.Object.SetNamespace(config.namespace)
+	err, kc := internal.ConnectK8s(k8sConfig, config.namespace)
+	if err != nil {
+		return err
+	}
+	if mode == internal.DryRunServer {
+		if err := kc.UseK6API(manifest.Object.GetAPIVersion(), manifest.Object.GetKind()); err != nil {
+			return err
+		}
+	}
+	manifests := &internal.Manifests{TestRun: manifest.Object}
+	if config.bundleScript {
+		scriptPath, err := manifest.ScriptPath()
+		if err != nil {
+			return err
+		}
+		scriptSps := internal.NewScriptProperties(scriptPath)
+		err, jsBundle := internal.Bundle(&scriptSps, config.minify, config.summary)
+		if err != nil {
+			return err
+		}
+		manifests.ConfigMap = manifest.ScriptConfigMap(config.namespace, jsBundle)
+	}
+	return printManifests(&kc, manifests, mode)
+}
+
+// printManifests prints the objects or writes them into the output directory. In server mode, they are submitted
+// first and the objects the server returned are printed.
+func printManifests(kc *internal.K8sClient, manifests *internal.Manifests, mode internal.DryRunMode) error {
+	var err error
+	if mode == internal.DryRunServer {
+		fmt.Fprintf(os.Stderr, "Submitting the objects to namespace '%s' with dryRun=All...\n", config.namespace)
+		if manifests, err = kc.DryRunCreate(context.Background(), manifests); err != nil {
+			return fmt.Errorf("the server rejected the objects: %w", err)
+		}
+	}
+	if renderOptions.outputDir != "" {
+		written := *manifests
+		if manifests.Secret != nil {
+			// the values of the secret are redacted, applying the file would store the placeholders
+			written.Secret = nil
+			fmt.Fprintf(os.Stderr, "The secret '%s' is not written since its values are redacted, create it with "+
+				"'kubectl create secret generic %s -n %s --from-env-file=%s'\n", manifests.Secret.Name, manifests.Secret.Name, config.namespace, config.envFile)
+		}
+		paths, err := internal.WriteManifests(written.Objects(), renderOptions.output, renderOptions.outputDir, redactor.Redact)
+		for _, path := range paths {
+			fmt.Fprintf(os.Stderr, "Wrote '%s'\n", path)
+		}
+		return err
+	}
+	out, err := internal.FormatManifests(manifests.Objects(), renderOptions.output, redactor.Redact)
+	if err != nil {
+		return err
+	}
+	fmt.Print(out)
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(renderCmd)
+	renderCmd.SilenceUsage = true
+	addRunFlags(renderCmd)
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/brodo/kubectl-k6/internal"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	v1 "k8s.io/api/core/v1"
+	"maps"
+	"slices"
+)
+
+// rerunCmd represents the rerun command
+var rerunCmd = &cobra.Command{
+	Use:   "rerun <run id>",
+	Short: "Run a script again with the bundle and configuration of a previous run",
+	Long: `Replays a run from the local history. The bundle that was uploaded for the original run is uploaded again,
+so changes to the script since then have no effect. Runs in folder mode cannot be replayed.
+Sensitive environment variables are redacted in the history, their values are taken from the current configuration.
+For example:
+
+kubectl-k6 rerun l4q5ph7vsplt2pxkkv4l`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err, history := internal.NewHistory()
+		if err != nil {
+			return err
+		}
+		record, err := history.Find(args[0])
+		if err != nil {
+			return err
+		}
+		bundle, err := record.ReadBundle()
+		if err != nil {
+			return err
+		}
+		loadRunConfig()
+		currentEnv := maps.Clone(config.k6Env)
+		currentArgs := internal.ArgsEnv(config.k6Arguments)
+		currentPods := []internal.PodOptions{config.runner, config.initializer, config.starter}
+		for key, value := range record.Config {
+			viper.Set(key, value)
+		}
+		loadRunConfig()
+		for k, v := range config.k6Env {
+			if v != internal.RedactedPlaceholder {
+				continue
+			}
+			current, ok := currentEnv[k]
+			if !ok {
+				return fmt.Errorf("the value of '%s' was redacted in the history, set it in the configuration to replay the run", k)
+			}
+			config.k6Env[k] = current
+		}
+		for k, v := range internal.ArgsEnv(config.k6Arguments) {
+			if v != internal.RedactedPlaceholder {
+				continue
+			}
+			current, ok := currentArgs[k]
+			if !ok {
+				return fmt.Errorf("the value of '%s' was redacted from the arguments in the history, set it in the arguments to replay the run", k)
+			}
+			config.k6Arguments = internal.ReplaceArgsEnv(config.k6Arguments, k, v, current)
+		}
+		for i, po := range []*internal.PodOptions{&config.runner, &config.initializer, &config.starter} {
+			if err := restoreRedactedEnv(po, currentPods[i]); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("Replaying run '%s' of '%s'...\n", record.RunId, record.ScriptPath)
+		return executeRun(record.ScriptPath, bundle)
+	},
+}
+
+// restoreRedactedEnv takes the redacted values of the pods' environment from the current configuration.
+func restoreRedactedEnv(po *internal.PodOptions, current internal.PodOptions) error {
+	for i, env := range po.Env {
+		if env.Value != internal.RedactedPlaceholder {
+			continue
+		}
+		j := slices.IndexFunc(current.Env, func(e v1.EnvVar) bool { return e.Name == env.Name })
+		if j < 0 {
+			return fmt.Errorf("the value of '%s' was redacted from the pod options in the history, set it in the configuration to replay the run", env.Name)
+		}
+		po.Env[i].Value = current.Env[j].Value
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(rerunCmd)
+	rerunCmd.SilenceUsage = true
+}
@@ -1,14 +1,19 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"github.com/brodo/kubectl-k6/internal"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"io"
+	v1 "k8s.io/api/core/v1"
+	"maps"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -23,229 +28,696 @@ type configuration struct {
 	imagePullSecret string `mapstructure:"ips"`
 	minify          bool
 	folder          string
+	summary         bool
+	reports         []string `mapstructure:"report"`
+	baseline        string
+	saveBaseline    string `mapstructure:"save-baseline"`
+	history         bool
+	saveResults     bool   `mapstructure:"save-results"`
+	keepResults     int    `mapstructure:"keep-results"`
+	envFile         string `mapstructure:"env-file"`
+	manifest        string `mapstructure:"filename"`
+	bundleScript    bool   `mapstructure:"bundle-script"`
+	runner          internal.PodOptions
+	initializer     internal.PodOptions
+	starter         internal.PodOptions
+	timeouts        internal.StageTimeouts
+	diagnostics     string
+	sidecars        internal.SidecarOptions
+	progress        bool
+	guardrails      internal.Guardrails
+	zones           []internal.Zone
 }
 
 var config = configuration{}
 
+// redactor removes sensitive values from the console output, the reports and the history.
+var redactor *internal.Redactor
+
+// runnerFlags override the `runner` section of the config file.
+var runnerFlags struct {
+	requests      map[string]string
+	limits        map[string]string
+	nodeSelector  map[string]string
+	tolerations   []string
+	priorityClass string
+	spread        bool
+}
+
 // runCmd represents the run command
 var runCmd = &cobra.Command{
 	Use:   "run [k6 script path]",
 	Short: "Run one or more k6 scripts on a k8s cluster",
 	Long: `This script can run k6 tests on a remote k8s server if a k6 operator is installed on that cluster.
+Instead of a script, a pre-authored TestRun manifest can be run with '-f'.
 For example:
 
-kubectl-k6 run myTestScript.js`,
+kubectl-k6 run myTestScript.js
+kubectl-k6 run -f testrun.yaml --bundle-script`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		loadRunConfig()
-		scriptPath := args[0]
-		sps := internal.NewScriptProperties(scriptPath)
-		err, kc := internal.NewK8sClient(k8sConfig, config.namespace)
-		cobra.CheckErr(err)
-
-		templateVars := internal.NewTemplateVars(sps)
-		err, k6args := templateVars.ApplyArgTemp(config.k6Arguments)
-		cobra.CheckErr(err)
-		err = templateVars.ApplyEnvTemp(&config.k6Env)
-		cobra.CheckErr(err)
-
-		fmt.Printf("Running k6 with the following arguments: %s\n", k6args)
-		fmt.Printf("Running k6 with the following environment variables:\n%s\n", config.k6Env.String())
-		fmt.Println("Running pre clean-up...")
-		err = kc.DeleteResources(context.Background(), &sps)
-		cobra.CheckErr(err)
-		k6Config := internal.NewK6Config(config.k6Env, k6args, config.dockerImage, config.parallelism, config.imagePullSecret, config.folder, scriptPath)
-		if config.folder == "" {
-			fmt.Println("Bundling script...")
-			err, jsBundle := internal.Bundle(&sps, config.minify)
-			cobra.CheckErr(err)
-			if len(jsBundle) > 1048576 {
-				return fmt.Errorf("the bundled script is too large: %d MB, max 1 MB - please use `--folder`", len(jsBundle)/1_048_576)
+		if (len(args) == 1) == (config.manifest != "") {
+			return fmt.Errorf("either a script or a manifest ('-f') must be given")
+		}
+		if config.manifest != "" && len(config.zones) > 0 {
+			return fmt.Errorf("zones cannot be used with manifests ('-f'), define a manifest per zone instead")
+		}
+		mode, err := internal.ParseDryRunMode(renderOptions.dryRun)
+		if err != nil {
+			return err
+		}
+		if config.manifest != "" {
+			if mode != internal.DryRunNone {
+				return renderManifest(config.manifest, mode)
 			}
-			fmt.Printf("Uploading config map '%s'...\n", sps.ConfigMapName())
-			err = kc.CreateConfigMap(context.Background(), &sps, string(jsBundle))
-			cobra.CheckErr(err)
-		} else {
-			err, baseDir := internal.CreateTempFolder(config.folder)
-			cobra.CheckErr(err)
-			fmt.Printf("Uploading folder '%s' to persistant volume '%s'...\n", config.folder, sps.ConfigMapName())
-			err = kc.UploadFolderToPV(context.Background(), baseDir, sps.ConfigMapName(), config.namespace)
-			cobra.CheckErr(err)
-			err = kc.CreatePVC(context.Background(), sps.ConfigMapName(), sps.ConfigMapName(), config.namespace)
-			cobra.CheckErr(err)
+			return executeRun(config.manifest, nil)
 		}
+		if mode != internal.DryRunNone {
+			return renderScript(args[0], mode)
+		}
+		return executeRun(args[0], nil)
+	},
+	Args: cobra.MatchAll(cobra.MaximumNArgs(1), cobra.OnlyValidArgs),
+}
 
-		fmt.Printf("Uploading k6 custom resource '%s'...\n", sps.ResourceName())
-		err = kc.CreateCustomResource(context.Background(), &k6Config, &templateVars)
+// executeRun runs the script or, if a manifest is configured, the manifest at scriptPath, writes the reports and
+// records the run in the history. If bundle is not nil, it is uploaded instead of bundling the script again.
+func executeRun(scriptPath string, bundle []byte) error {
+	var reportTargets []internal.ReportTarget
+	for _, arg := range config.reports {
+		target, err := internal.ParseReportTarget(arg)
 		if err != nil {
-			fmt.Printf("Error creating custom resource '%s': %v\n", sps.ResourceName(), err)
 			return err
 		}
-		fmt.Println("Waiting for initialization phase...")
-		waitCtx, cancel := context.WithTimeout(context.Background(), time.Minute*3)
-		err = kc.WaitForStage(waitCtx, sps.ResourceName(), internal.InitializationStage)
-		cancel()
+		reportTargets = append(reportTargets, target)
+	}
+	effectiveConfig := effectiveRunConfig()
+	report := &internal.RunReport{ScriptPath: scriptPath, Start: time.Now()}
+	if config.manifest != "" {
+		report.Err = redactor.RedactError(runManifest(scriptPath, bundle, report))
+	} else {
+		report.Err = redactor.RedactError(runScript(scriptPath, bundle, report))
+	}
+	report.EndStage()
+	report.End = time.Now()
+	for _, target := range reportTargets {
+		if err := target.Write([]*internal.RunReport{report}); err != nil {
+			fmt.Printf("Error writing %s report '%s': %v\n", target.Format, target.Path, err)
+		}
+	}
+	if report.RunId == "" {
+		return report.Err
+	}
+	record := internal.NewHistoryRecord(report, effectiveConfig)
+	if config.history {
+		err, history := internal.NewHistory()
+		if err == nil {
+			err = history.Append(record, report.Bundle)
+		}
 		if err != nil {
-			logs, logErr := kc.GetOperatorLogsSince(context.Background(), templateVars.Time)
-			fmt.Printf("Error in initialization phase for '%s': %v\n", sps.ResourceName(), err)
-			if logErr != nil {
-				fmt.Printf("Error getting operator logs: %v\n", logErr)
-			} else {
-				fmt.Printf("Operator logs since %s:\n%s\n", templateVars.Time.Format(time.RFC3339), logs)
-			}
-			return err
+			fmt.Printf("Error recording the run in the history: %v\n", err)
+		}
+	}
+	if config.saveResults {
+		err, kc := internal.ConnectK8s(k8sConfig, resultsNamespace())
+		if err == nil {
+			fmt.Printf("Saving the results in namespace '%s'...\n", resultsNamespace())
+			err = kc.SaveResult(context.Background(), internal.NewResultRecord(record, config.namespace))
 		}
-		fmt.Println("Waiting for initialization job to complete...")
-		waitCtx, cancel = context.WithTimeout(context.Background(), time.Minute*3)
-		err = kc.WaitForInitJobCompletion(waitCtx, &sps, templateVars.Time)
-		cancel()
 		if err != nil {
-			logs, logErr := kc.GetOperatorLogsSince(context.Background(), templateVars.Time)
-			fmt.Printf("Init job '%s' did not complete in three minutes! Trying to get logs.\n %v ,\n", sps.InitJobName(), err)
-			if logErr != nil {
-				fmt.Printf("Error getting operator logs: %v\n", logErr)
-			} else {
-				if logs == "" {
-					logs = "The operator did not log any errors."
-				} else {
-					fmt.Printf("Operator logs since %s:\n%s\n", templateVars.Time.Format(time.RFC3339), logs)
-				}
-			}
-			logObjs, logErr := kc.GetJobPodLogs(context.Background(), sps.InitJobName())
-			if logErr != nil {
-				fmt.Printf("Error getting logs for job '%s': %v\n", sps.InitJobName(), logErr)
-			} else {
-				for _, obj := range logObjs {
-					if obj.Logs == "" {
-						fmt.Printf("The pod '%s' did not log anything.\n", obj.PodName)
-					} else {
-						fmt.Printf("Logs for pod '%s':\n%s\n", obj.PodName, obj.Logs)
-					}
-				}
+			fmt.Printf("Error saving the results in the cluster: %v\n", err)
+		} else if config.keepResults > 0 {
+			deleted, err := kc.PruneResults(context.Background(), config.keepResults, printWarning)
+			if err != nil {
+				fmt.Printf("Error deleting old results in the cluster: %v\n", err)
+			} else if deleted > 0 {
+				fmt.Printf("Deleted the %d oldest results record(s), %d are kept\n", deleted, config.keepResults)
 			}
+		}
+	}
+	return report.Err
+}
+
+// runScript runs a single script on the cluster and records the results in the report.
+func runScript(scriptPath string, bundle []byte, report *internal.RunReport) error {
+	sps := internal.NewScriptProperties(scriptPath)
+	report.RunId = sps.RunId
+	err, kc := internal.NewK8sClient(k8sConfig, config.namespace)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Using the k6 operator API %s\n", kc.K6API())
+	kc.SetSidecarOptions(config.sidecars)
+
+	templateVars := internal.NewTemplateVars(sps)
+	err, k6args := templateVars.ApplyArgTemp(config.k6Arguments)
+	if err != nil {
+		return err
+	}
+	if err := templateVars.ApplyEnvTemp(&config.k6Env); err != nil {
+		return err
+	}
 
+	report.Arguments = redactor.RedactArgs(k6args)
+	fmt.Printf("Running k6 with the following arguments: %s\n", report.Arguments)
+	report.Env = redactor.RedactEnv(config.k6Env)
+	fmt.Printf("Running k6 with the following environment variables:\n%s\n", report.Env.String())
+	report.Image = config.dockerImage
+	report.Parallelism = config.parallelism
+	addSecretValuesToRedactor(&kc)
+	defer func() {
+		logs, logErr := kc.GetOperatorLogsSince(context.Background(), templateVars.Time)
+		if logErr == nil {
+			report.OperatorLogs = redactor.Redact(logs)
+		}
+	}()
+
+	report.StartStage("pre clean-up")
+	fmt.Println("Running pre clean-up...")
+	if err := kc.DeleteResources(context.Background(), &sps); err != nil {
+		return err
+	}
+	k6Config := newK6Config(scriptPath, k6args)
+	report.StartStage("upload")
+	if config.folder == "" {
+		jsBundle := bundle
+		if jsBundle == nil {
+			fmt.Println("Bundling script...")
+			if err, jsBundle, report.SourceMap = internal.BundleWithSourceMap(&sps, config.minify, config.summary); err != nil {
+				return err
+			}
+		}
+		report.Bundle = jsBundle
+		if len(jsBundle) > 1048576 {
+			return fmt.Errorf("the bundled script is too large: %d MB, max 1 MB - please use `--folder`", len(jsBundle)/1_048_576)
+		}
+		fmt.Printf("Uploading config map '%s'...\n", sps.ConfigMapName())
+		if err := kc.CreateConfigMap(context.Background(), &sps, string(jsBundle)); err != nil {
 			return err
 		}
-		fmt.Println("Waiting for run jobs to be created...")
-		waitCtx, cancel = context.WithTimeout(context.Background(), time.Minute*10)
-		err = kc.WaitForStage(waitCtx, sps.ResourceName(), internal.CreatedStage)
-		cancel()
+	} else {
+		err, baseDir := internal.CreateTempFolder(config.folder)
 		if err != nil {
-			logs, logErr := kc.GetOperatorLogsSince(context.Background(), templateVars.Time)
-			fmt.Printf("Error in creation phase for '%s': %v\n\n", sps.ResourceName(), err)
-			if logErr != nil {
-				fmt.Printf("Error getting operator logs: %v\n", logErr)
-			} else {
-				fmt.Printf("Operator logs since %s:\n%s\n", templateVars.Time.Format(time.RFC3339), logs)
+			return err
+		}
+		fmt.Printf("Uploading folder '%s' to persistant volume '%s'...\n", config.folder, sps.ConfigMapName())
+		if err := kc.UploadFolderToPV(context.Background(), baseDir, sps.ConfigMapName(), config.namespace); err != nil {
+			return err
+		}
+		if err := kc.CreatePVC(context.Background(), sps.ConfigMapName(), sps.ConfigMapName(), config.namespace); err != nil {
+			return err
+		}
+	}
+
+	if config.envFile != "" {
+		env, err := internal.ParseEnvFile(config.envFile)
+		for _, v := range env {
+			redactor.AddValues(v)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Uploading %d variable(s) from '%s' as secret '%s'...\n", len(env), config.envFile, sps.EnvSecretName())
+		if err := kc.CreateEnvSecret(context.Background(), &sps, env); err != nil {
+			return err
+		}
+		k6Config.EnvSecret = sps.EnvSecretName()
+	}
+
+	if len(config.zones) > 0 {
+		return runZones(&kc, &sps, &k6Config, &templateVars, scriptPath, k6args, report)
+	}
+	fmt.Printf("Uploading k6 custom resource '%s'...\n", sps.ResourceName())
+	err = kc.CreateCustomResource(context.Background(), &k6Config, &templateVars)
+	if err != nil {
+		fmt.Printf("Error creating custom resource '%s': %v\n", sps.ResourceName(), err)
+		if k6Config.EnvSecret != "" {
+			if delErr := kc.DeleteSecret(context.Background(), k6Config.EnvSecret); delErr != nil {
+				fmt.Printf("Error deleting secret '%s': %v\n", k6Config.EnvSecret, delErr)
 			}
+		}
+		return err
+	}
+	if k6Config.EnvSecret != "" {
+		// The secret has to exist before the runners start, so its owner is set afterward
+		if err := kc.SetSecretOwner(context.Background(), k6Config.EnvSecret, sps.ResourceName()); err != nil {
+			fmt.Printf("Error setting the owner of secret '%s', it will not be deleted automatically: %v\n", k6Config.EnvSecret, err)
+		}
+	}
+	duration, timeout := runDurations(scriptPath, k6args)
+	if err := watchRun(&kc, &sps, &k6Config, &templateVars, duration, timeout, report); err != nil {
+		return err
+	}
 
-			fmt.Println("Getting logs for run jobs...")
+	report.StartStage("clean-up")
+	fmt.Println("Cleaning up...")
+	delErr := kc.DeleteResources(context.Background(), &sps)
+	if delErr != nil {
+		fmt.Printf("Error cleaning up resources: %v\n", delErr)
+	}
+	return nil
+}
 
-			for i := 0; i < config.parallelism; i++ {
-				logs, logErr := kc.GetPodLogs(context.Background(), sps.RunnerJobName(i), templateVars.Time)
-				if logErr != nil {
-					fmt.Printf("Error getting logs for job '%s': %v\n", sps.RunnerJobName(i), logErr)
-				} else {
-					fmt.Printf("Logs for job '%s':\n%s\n", sps.RunnerJobName(i), logs)
-				}
+// runManifest runs a pre-authored test run manifest and records the results in the report. The script the manifest
+// refers to is bundled and uploaded if configured, or bundle is uploaded if it is not nil.
+func runManifest(manifestPath string, bundle []byte, report *internal.RunReport) error {
+	ctx := context.Background()
+	sps := internal.NewScriptProperties(manifestPath)
+	report.RunId = sps.RunId
+	templateVars := internal.NewTemplateVars(sps)
+	manifest, err := internal.LoadTestRunManifest(manifestPath, &templateVars)
+	if err != nil {
+		return err
+	}
+	if ns := manifest.TestRun.Namespace; ns != "" {
+		config.namespace = ns
+	}
+	err, kc := internal.NewK8sClient(k8sConfig, config.namespace)
+	if err != nil {
+		return err
+	}
+	if err := kc.UseK6API(manifest.Object.GetAPIVersion(), manifest.Object.GetKind()); err != nil {
+		return err
+	}
+	kc.SetSidecarOptions(config.sidecars)
+	sps.Name = manifest.TestRun.Name
+	spec := manifest.TestRun.Spec
+	config.parallelism = spec.Parallelism
+	report.Image = spec.Runner.Image
+	report.Parallelism = spec.Parallelism
+	report.Arguments = redactor.RedactArgs(spec.Arguments)
+	fmt.Printf("Running test run '%s' from '%s' in namespace '%s'\n", sps.Name, manifestPath, config.namespace)
+
+	var configMap *v1.ConfigMap
+	if config.bundleScript {
+		if bundle == nil {
+			scriptPath, err := manifest.ScriptPath()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Bundling script '%s'...\n", scriptPath)
+			scriptSps := internal.NewScriptProperties(scriptPath)
+			if err, bundle, report.SourceMap = internal.BundleWithSourceMap(&scriptSps, config.minify, config.summary); err != nil {
+				return err
 			}
+		}
+		report.Bundle = bundle
+		configMap = manifest.ScriptConfigMap(config.namespace, bundle)
+	}
+	// only bundles contain the machine-readable summary
+	config.summary = config.summary && configMap != nil
+	config.folder = ""
+	defer func() {
+		logs, logErr := kc.GetOperatorLogsSince(ctx, templateVars.Time)
+		if logErr == nil {
+			report.OperatorLogs = redactor.Redact(logs)
+		}
+	}()
+
+	report.StartStage("pre clean-up")
+	fmt.Println("Running pre clean-up...")
+	if err := kc.DeleteCustomResource(ctx, sps.Name); err != nil {
+		return err
+	}
+	report.StartStage("upload")
+	if configMap != nil {
+		fmt.Printf("Uploading config map '%s'...\n", configMap.Name)
+		if err := kc.ApplyConfigMap(ctx, configMap); err != nil {
 			return err
 		}
-		fmt.Println("Waiting for run jobs to complete...")
-		if config.parallelism == 1 {
-			var wg sync.WaitGroup
-			wg.Add(2)
-			go func() {
-				defer wg.Done()
-				waitCtx, cancel = context.WithTimeout(context.Background(), time.Hour)
-				err = kc.WaitForRunJobCompletion(waitCtx, &sps, &k6Config, templateVars.Time)
-				cancel()
-			}()
-
-			go func() {
-				defer wg.Done()
-				fmt.Println("BEGIN k6 LOGS:")
-				rc, err := kc.GetPodLogStream(context.Background(), sps.RunnerJobName(0), templateVars.Time)
-				defer rc.Close()
-				if err != nil {
-					return
+	}
+	fmt.Printf("Uploading k6 custom resource '%s'...\n", sps.Name)
+	if err := kc.CreateCustomResourceObject(ctx, manifest.Object); err != nil {
+		return err
+	}
+	k6Config := internal.K6Config{Parallelism: spec.Parallelism}
+	var duration time.Duration
+	timeout := internal.DefaultRunTimeout
+	if config.timeouts.Run > 0 {
+		timeout = config.timeouts.Run
+	}
+	if scriptPath, err := manifest.ScriptPath(); err == nil {
+		duration, timeout = runDurations(scriptPath, spec.Arguments)
+	}
+	if err := watchRun(&kc, &sps, &k6Config, &templateVars, duration, timeout, report); err != nil {
+		return err
+	}
+
+	report.StartStage("clean-up")
+	fmt.Println("Cleaning up...")
+	if err := kc.DeleteCustomResource(ctx, sps.Name); err != nil {
+		fmt.Printf("Error cleaning up resources: %v\n", err)
+	}
+	if configMap != nil {
+		if err := kc.DeleteConfigMap(ctx, configMap.Name); err != nil {
+			fmt.Printf("Error cleaning up resources: %v\n", err)
+		}
+	}
+	return nil
+}
+
+// watchRun waits for the stages of the created custom resource, streams the logs, shows the progress and collects the
+// results. duration is the duration declared by the script, 0 if it is unknown. On errors, the logs of the operator
+// and the jobs are printed. The resources are not cleaned up.
+func watchRun(kc *internal.K8sClient, sps *internal.ScriptProperties, k6Config *internal.K6Config, templateVars *internal.TemplateVars, duration, runTimeout time.Duration, report *internal.RunReport) error {
+	// the pods are watched during all stages, so runs with stuck pods fail without waiting out the timeouts
+	runCtx, abort := context.WithCancelCause(context.Background())
+	defer abort(nil)
+	watchPods(runCtx, abort, kc, sps.ResourceName(), templateVars.Time)
+
+	runnerJobs, err := waitUntilCreated(runCtx, kc, sps, templateVars, report)
+	if err != nil {
+		return failRun(kc, sps, templateVars, report, err, true)
+	}
+	report.StartStage("run")
+	fmt.Println("Waiting for run jobs to complete...")
+	stopMonitors := startMonitors(runCtx, kc, []string{sps.ResourceName()}, duration)
+	if len(runnerJobs) == 1 {
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			err = waitForRunners(runCtx, kc, sps, k6Config, templateVars, runTimeout)
+		}()
+
+		go func() {
+			defer wg.Done()
+			fmt.Println("BEGIN k6 LOGS:")
+			rc, err := kc.GetPodLogStream(runCtx, runnerJobs[0], templateVars.Time)
+			if err != nil {
+				fmt.Printf("Error getting log stream!\n %v", err)
+				return
+			}
+			defer rc.Close()
+			reader := bufio.NewReader(rc)
+			for {
+				line, err := reader.ReadString('\n')
+				if !internal.IsSummaryLine(line) {
+					fmt.Print(redactor.Redact(line))
+				}
+				if err == io.EOF {
+					break
 				}
-				for {
-					buf := make([]byte, 2000)
-					numBytes, err := rc.Read(buf)
-					if err == io.EOF {
-						break
-					}
-					if err != nil {
-						fmt.Printf("Error getting log stream!\n %v", err)
-						break
-					}
-					if numBytes == 0 {
-						continue
-					}
-
-					message := string(buf[:numBytes])
-					fmt.Print(message)
+				if err != nil {
+					fmt.Printf("Error getting log stream!\n %v", err)
+					break
 				}
-				fmt.Println("END k6 LOGS")
-			}()
+			}
+			fmt.Println("END k6 LOGS")
+		}()
 
-			wg.Wait()
-		} else {
-			waitCtx, cancel = context.WithTimeout(context.Background(), time.Hour)
-			err = kc.WaitForRunJobCompletion(waitCtx, &sps, &k6Config, templateVars.Time)
-			cancel()
+		wg.Wait()
+	} else {
+		err = waitForRunners(runCtx, kc, sps, k6Config, templateVars, runTimeout)
+	}
+	if gErr := stopMonitors(); gErr != nil {
+		err = errors.Join(gErr, err)
+	}
+	if err != nil && !(collectSummary() && internal.OnlyThresholdsFailed(err)) {
+		fmt.Printf("Error running run jobs!\n %v\n", err)
+		// the error contains the logs of the failed runners
+		return failRun(kc, sps, templateVars, report, err, false)
+	}
+
+	report.StartStage("collect results")
+	runnerLogs := collectRunnerLogs(kc, runnerJobs, templateVars.Time, report, len(runnerJobs) > 1)
+	return checkResults(kc, report, runnerJobs, runnerLogs, err)
+}
+
+// watchPods checks the pods of the test run until runCtx is done and aborts the run if they are stuck.
+func watchPods(runCtx context.Context, abort context.CancelCauseFunc, kc *internal.K8sClient, resName string, since time.Time) {
+	go func() {
+		if err := kc.WatchTestRunPods(runCtx, resName, since, podCheckInterval); err != nil {
+			abort(err)
+		}
+	}()
+}
+
+// waitUntilCreated waits for the stages of the test run until the operator created the runner jobs and returns their
+// names. Errors are printed.
+func waitUntilCreated(runCtx context.Context, kc *internal.K8sClient, sps *internal.ScriptProperties, templateVars *internal.TemplateVars, report *internal.RunReport) ([]string, error) {
+	report.StartStage("initialization")
+	fmt.Println("Waiting for initialization phase...")
+	waitCtx, cancel := context.WithTimeout(runCtx, config.timeouts.Initialization)
+	err := stageError(runCtx, kc.WaitForStage(waitCtx, sps.ResourceName(), internal.InitializationStage), "initialization", config.timeouts.Initialization, "timeout-initialization")
+	cancel()
+	if err != nil {
+		fmt.Printf("Error in initialization phase for '%s': %v\n", sps.ResourceName(), err)
+		return nil, err
+	}
+	report.StartStage("initialization job")
+	fmt.Println("Waiting for initialization job to complete...")
+	waitCtx, cancel = context.WithTimeout(runCtx, config.timeouts.InitJob)
+	err = stageError(runCtx, kc.WaitForInitJobCompletion(waitCtx, sps, templateVars.Time, printWarning), "init job", config.timeouts.InitJob, "timeout-init-job")
+	cancel()
+	if err != nil {
+		fmt.Printf("Init job '%s' did not complete!\n %v\n", sps.InitJobName(), err)
+		return nil, err
+	}
+	report.StartStage("job creation")
+	fmt.Println("Waiting for run jobs to be created...")
+	waitCtx, cancel = context.WithTimeout(runCtx, config.timeouts.JobCreation)
+	err = stageError(runCtx, kc.WaitForStage(waitCtx, sps.ResourceName(), internal.CreatedStage), "job creation", config.timeouts.JobCreation, "timeout-job-creation")
+	cancel()
+	if err != nil {
+		fmt.Printf("Error in creation phase for '%s': %v\n", sps.ResourceName(), err)
+		return nil, err
+	}
+	// the runner jobs are found by the operator's labels, their names depend on the operator's version
+	runnerJobs, err := kc.GetRunnerJobNames(context.Background(), sps.ResourceName())
+	if err == nil && len(runnerJobs) == 0 {
+		err = fmt.Errorf("the operator did not create runner jobs with the labels '%s'", internal.RunnerLabelSelector(sps.ResourceName()))
+	}
+	if err != nil {
+		fmt.Printf("Error finding the run jobs of '%s': %v\n", sps.ResourceName(), err)
+		return nil, err
+	}
+	return runnerJobs, nil
+}
+
+// waitForRunners waits until the runner jobs of the test run completed, at most runTimeout.
+func waitForRunners(runCtx context.Context, kc *internal.K8sClient, sps *internal.ScriptProperties, k6Config *internal.K6Config, templateVars *internal.TemplateVars, runTimeout time.Duration) error {
+	waitCtx, cancel := context.WithTimeout(runCtx, runTimeout)
+	defer cancel()
+	return stageError(runCtx, kc.WaitForRunJobCompletion(waitCtx, sps, k6Config, templateVars.Time, printWarning), "run", runTimeout, "timeout-run")
+}
+
+// startMonitors shows the progress and checks the guardrails of the runners of the test runs. The returned function
+// stops them and returns the error of a guardrail that tripped.
+func startMonitors(runCtx context.Context, kc *internal.K8sClient, resNames []string, duration time.Duration) func() error {
+	progressCtx, stopProgress := context.WithCancel(runCtx)
+	if config.progress {
+		go func() {
+			err := kc.WatchProgress(progressCtx, resNames, duration, progressInterval, func(p internal.Progress) {
+				fmt.Printf("Progress: %s\n", p)
+			})
 			if err != nil {
-				fmt.Printf("Error running run jobs!\n %v", err)
-				return err
+				fmt.Printf("Cannot show the progress, disable it with '--progress=false': %v\n", err)
 			}
+		}()
+	}
+	guardrailCtx, stopGuardrails := context.WithCancel(runCtx)
+	guardrailErr := make(chan error, 1)
+	if config.guardrails.Empty() {
+		guardrailErr <- nil
+	} else {
+		go func() {
+			err := kc.WatchGuardrails(guardrailCtx, resNames, config.guardrails, guardrailInterval, printWarning)
+			var tripped *internal.GuardrailError
+			if errors.As(err, &tripped) {
+				fmt.Printf("Stopping the run: %v\n", err)
+			} else if err != nil {
+				fmt.Printf("Warning: %v\n", err)
+				err = nil
+			}
+			guardrailErr <- err
+		}()
+	}
+	return func() error {
+		stopProgress()
+		stopGuardrails()
+		return <-guardrailErr
+	}
+}
+
+// collectSummary reports whether the summaries of the runners are collected.
+func collectSummary() bool {
+	return config.summary && config.folder == ""
+}
+
+// collectRunnerLogs returns the logs of the runner jobs and records them in the report. The logs without the
+// summary are printed if printLogs is set.
+func collectRunnerLogs(kc *internal.K8sClient, runnerJobs []string, since time.Time, report *internal.RunReport, printLogs bool) []string {
+	runnerLogs := make([]string, len(runnerJobs))
+	for i, jobName := range runnerJobs {
+		logs, logErr := kc.GetPodLogs(context.Background(), jobName, since)
+		if logErr != nil {
+			fmt.Printf("Error getting logs for job '%s': %v\n", jobName, logErr)
+			continue
+		}
+		runnerLogs[i] = logs
+		report.RunnerLogs = append(report.RunnerLogs, internal.LogsWithNames{PodName: jobName, Logs: redactor.Redact(logs)})
+		if printLogs {
+			fmt.Printf("Logs for job '%s':\n%s\n", jobName, redactor.Redact(internal.StripSummary(logs)))
 		}
+	}
+	return runnerLogs
+}
 
-		fmt.Println("All jobs completed successfully!")
-		if config.parallelism > 1 {
-			for i := 0; i < config.parallelism; i++ {
-				logs, logErr := kc.GetPodLogs(context.Background(), sps.RunnerJobName(i), templateVars.Time)
-				if logErr != nil {
-					fmt.Printf("Error getting logs for job '%s': %v\n", sps.RunnerJobName(i), logErr)
-				} else {
-					fmt.Printf("Logs for job '%s':\n%s\n", sps.RunnerJobName(i), logs)
-				}
+// checkResults merges and prints the summaries of all runners and checks the thresholds and the baselines. err is
+// the error of the run stage; if it is only about thresholds, the merged summary decides instead.
+func checkResults(kc *internal.K8sClient, report *internal.RunReport, runnerJobs, runnerLogs []string, err error) error {
+	if collectSummary() {
+		summary, sumErr := mergeRunnerSummaries(runnerJobs, runnerLogs)
+		if sumErr != nil {
+			fmt.Printf("Error collecting the k6 summaries: %v\n", sumErr)
+		} else {
+			report.Summary = summary
+			fmt.Println(summary.String())
+			if failed := summary.FailedThresholds(); len(failed) > 0 {
+				err = fmt.Errorf("%w: %d threshold(s) failed across all runners", internal.ErrThresholdsCrossed, len(failed))
+			} else {
+				err = nil
 			}
 		}
+	}
+	if config.baseline != "" || config.saveBaseline != "" {
+		if report.Summary == nil {
+			err = errors.Join(err, fmt.Errorf("cannot compare with or save a baseline without a summary"))
+		} else {
+			err = errors.Join(err, handleBaselines(kc, report.Summary))
+		}
+	}
+	if err != nil {
+		fmt.Printf("Error running run jobs!\n %v\n", err)
+		return err
+	}
+	fmt.Println("All jobs completed successfully!")
+	return nil
+}
+
+// runDurations returns the duration declared in the options of the script, 0 if it cannot be determined, and the
+// timeout of the run stage, which is configured or derived from the declared duration.
+func runDurations(scriptPath, k6args string) (declared, timeout time.Duration) {
+	source, err := os.ReadFile(scriptPath)
+	if errors.Is(err, os.ErrNotExist) && config.folder != "" {
+		source, err = os.ReadFile(filepath.Join(config.folder, scriptPath))
+	}
+	if err == nil {
+		declared, err = internal.ScriptDuration(string(source), k6args)
+	}
+	if err != nil {
+		declared = 0
+	}
+	if config.timeouts.Run > 0 {
+		return declared, config.timeouts.Run
+	}
+	if err != nil {
+		fmt.Printf("Could not determine the duration of '%s', waiting up to %s for the run: %v\n", scriptPath, internal.DefaultRunTimeout, err)
+		return 0, internal.DefaultRunTimeout
+	}
+	timeout = internal.RunTimeout(declared)
+	fmt.Printf("The script runs for up to %s, waiting up to %s for the run\n", declared, timeout)
+	return declared, timeout
+}
 
-		fmt.Println("Cleaning up...")
-		delErr := kc.DeleteResources(context.Background(), &sps)
-		if delErr != nil {
-			fmt.Printf("Error cleaning up resources: %v\n", delErr)
+// failRun collects the diagnostics of a failed run and prints the events, the errors of the operator and, if
+// printLogs is set, the logs of the containers that failed. These logs are recorded in the report and the
+// diagnostics bundle is written if configured. It returns err.
+func failRun(kc *internal.K8sClient, sps *internal.ScriptProperties, templateVars *internal.TemplateVars, report *internal.RunReport, err error, printLogs bool) error {
+	d := kc.CollectDiagnostics(context.Background(), sps.ResourceName(), templateVars.Time)
+	var stuckErr *internal.StuckRunError
+	if len(d.Events) > 0 && !errors.As(err, &stuckErr) {
+		fmt.Printf("Events of '%s':\n", sps.ResourceName())
+		for _, event := range d.Events {
+			fmt.Println(redactor.Redact(internal.FormatEvent(event)))
 		}
-		return nil
-	},
-	Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	}
+	if logs := d.OperatorErrors(); logs != "" {
+		fmt.Printf("Operator errors since %s:\n%s\n", templateVars.Time.Format(time.RFC3339), redactor.Redact(logs))
+	}
+	if printLogs {
+		for _, l := range d.FailedContainerLogs() {
+			if l.Logs == "" {
+				fmt.Printf("The container '%s' of pod '%s' did not log anything.\n", l.Container, l.Pod)
+			} else {
+				fmt.Printf("Logs of container '%s' of pod '%s':\n%s\n", l.Container, l.Pod, redactor.Redact(l.Logs))
+			}
+		}
+	}
+	if len(report.RunnerLogs) == 0 {
+		// the reports show these logs instead of the error, which contains the logs of all attempts
+		for _, l := range d.FailedContainerLogs() {
+			if l.Logs != "" {
+				report.RunnerLogs = append(report.RunnerLogs, internal.LogsWithNames{PodName: l.Pod + "/" + l.Container, Logs: redactor.Redact(l.Logs)})
+			}
+		}
+	}
+	if config.diagnostics != "" {
+		d.RunId = sps.RunId
+		d.Err = err
+		d.Bundle = report.Bundle
+		d.SourceMap = report.SourceMap
+		d.Config = effectiveRunConfig()
+		if path, writeErr := d.WriteTarballFile(config.diagnostics, redactor.Redact); writeErr != nil {
+			fmt.Printf("Error writing the diagnostics bundle: %v\n", writeErr)
+		} else {
+			fmt.Printf("Wrote the diagnostics bundle to '%s', attach it to bug reports.\n", path)
+		}
+	}
+	return err
 }
 
-func init() {
-	rootCmd.AddCommand(runCmd)
-	runCmd.SilenceUsage = true
+// printWarning prints a warning about the run that does not fail it, e.g. a retry of a job.
+func printWarning(msg string) {
+	fmt.Printf("Warning: %s\n", msg)
+}
 
-	const defaultNamespace = "k6-operator-system"
-	runCmd.Flags().StringVarP(&config.namespace, "namespace", "n", defaultNamespace, "k8s namespace to run in")
+// podCheckInterval is how often the pods of a run are checked for problems that keep them from completing.
+const podCheckInterval = 5 * time.Second
 
-	runCmd.Flags().StringVarP(&config.k6Arguments, "arguments", "a",
-		"",
-		`runs k6 with the given arguments. 
-You can provide a go template string (https://pkg.go.dev/text/template) here. See the documentation for supported variables.`)
+// progressInterval is how often the progress of a run is shown.
+const progressInterval = 10 * time.Second
 
-	runCmd.Flags().StringToStringVarP((*map[string]string)(&config.k6Env), "env", "e", make(internal.K6Environment),
-		`runs k6 with the given environment arguments.
-You can provide a go template string (https://pkg.go.dev/text/template) here. See the documentation for supported variables.`)
+// guardrailInterval is how often the guardrails of a run are checked.
+const guardrailInterval = 5 * time.Second
+
+// stageError returns why the wait for a stage failed: the problem of a stuck pod that aborted the run, or how to
+// raise the timeout if the stage timed out.
+func stageError(runCtx context.Context, err error, stage string, timeout time.Duration, flag string) error {
+	var stuckErr *internal.StuckRunError
+	switch {
+	case errors.Is(err, context.Canceled) && errors.As(context.Cause(runCtx), &stuckErr):
+		return stuckErr
+	case errors.Is(err, context.DeadlineExceeded):
+		return fmt.Errorf("the %s stage did not complete within %s, raise the timeout with '--%s': %w", stage, timeout, flag, err)
+	}
+	return err
+}
 
-	runCmd.Flags().IntVarP(&config.parallelism, "parallelism", "p", 1, "How many times a script should be run in parallel. Every parallel execution starts a k8s job.")
+const defaultNamespace = "k6-operator-system"
 
-	runCmd.Flags().StringVarP(&config.dockerImage, "image", "i", "", "The OCI image to use for running k6")
-	runCmd.Flags().StringVarP(&config.dockerImage, "ips", "s", "", "The name of the secret to use for pulling the OCI image. This is only used if the image is private.")
-	runCmd.Flags().BoolVarP(&config.minify, "minify", "m", false, "Minify Javascript before uploading it to the cluster")
-	runCmd.Flags().StringVarP(&config.folder, "folder", "f", "", "Uploads the provided a folder into a persistent volume on k8s.")
+// defaultKeepResults is how many results records are kept in the cluster by default.
+const defaultKeepResults = 100
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+	runCmd.SilenceUsage = true
+	addRunFlags(runCmd)
+	runCmd.Flags().StringVar(&renderOptions.dryRun, "dry-run", string(internal.DryRunNone), `Prints the objects instead of running the test. Must be 'none', 'client' or 'server'.
+With 'server', the objects are submitted with 'dryRun=All', so admission webhooks and quotas are checked without starting a test.`)
+
+	runCmd.Flags().StringVar(&config.diagnostics, "diagnostics", "", `Writes a diagnostics bundle for bug reports to the given file or directory if the run fails. The tarball contains the
+test run, its jobs, pods and events, the logs of all containers and of the operator, the bundle with its source map and the configuration.`)
+	runCmd.Flags().BoolVar(&config.progress, "progress", true, `Shows the VUs, iterations, elapsed and remaining time and the thresholds of all runners during the run.
+The REST API of k6 in the runner pods is reached through port-forwards.`)
 
 	cobra.CheckErr(viper.BindPFlags(runCmd.Flags()))
+	addTimeoutFlags(runCmd)
+	cobra.CheckErr(bindToleranceFlags(runCmd))
+	cobra.CheckErr(bindTimeoutFlags(runCmd))
+	addSidecarFlag(runCmd)
+	cobra.CheckErr(bindSidecarFlag(runCmd))
 	viper.SetDefault("namespace", defaultNamespace)
 	viper.SetDefault("arguments", "")
 	viper.SetDefault("env", make(internal.K6Environment))
@@ -254,6 +726,103 @@ You can provide a go template string (https://pkg.go.dev/text/template) here. Se
 	viper.SetDefault("parallelism", 1)
 	viper.SetDefault("minify", false)
 	viper.SetDefault("folder", "")
+	viper.SetDefault("summary", true)
+	viper.SetDefault("report", []string{})
+	viper.SetDefault("baseline", "")
+	viper.SetDefault("save-baseline", "")
+	viper.SetDefault("history", true)
+	viper.SetDefault("env-file", "")
+	viper.SetDefault("save-results", true)
+	viper.SetDefault("keep-results", defaultKeepResults)
+	viper.SetDefault("filename", "")
+	viper.SetDefault("bundle-script", false)
+	viper.SetDefault("diagnostics", "")
+	viper.SetDefault("progress", true)
+	viper.SetDefault("timeouts.initialization", 3*time.Minute)
+	viper.SetDefault("timeouts.initJob", 3*time.Minute)
+	viper.SetDefault("timeouts.jobCreation", 10*time.Minute)
+	viper.SetDefault("timeouts.run", time.Duration(0))
+	viper.SetDefault("sidecars.quit", false)
+}
+
+func addSidecarFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("quit-sidecars", false, `Shuts down the sidecars of a runner pod, e.g. the Istio proxy, through their quit endpoint after k6 exited.
+Without it, a run is done when k6 exited even if sidecars keep the pods running, but the pods are only removed with the test run.`)
+}
+
+// bindSidecarFlag binds the flag to the key of the `sidecars` config section it overrides.
+func bindSidecarFlag(cmd *cobra.Command) error {
+	return viper.BindPFlag("sidecars.quit", cmd.Flags().Lookup("quit-sidecars"))
+}
+
+// timeoutFlags maps the keys of the `timeouts` config section to the flags that override them.
+var timeoutFlags = map[string]string{
+	"timeouts.initialization": "timeout-initialization",
+	"timeouts.initJob":        "timeout-init-job",
+	"timeouts.jobCreation":    "timeout-job-creation",
+	"timeouts.run":            "timeout-run",
+}
+
+func addTimeoutFlags(cmd *cobra.Command) {
+	cmd.Flags().DurationVar(&config.timeouts.Initialization, "timeout-initialization", 3*time.Minute, "How long to wait for the operator to pick up the test run")
+	cmd.Flags().DurationVar(&config.timeouts.InitJob, "timeout-init-job", 3*time.Minute, "How long to wait for the initializer job, which pulls the image and inspects the script")
+	cmd.Flags().DurationVar(&config.timeouts.JobCreation, "timeout-job-creation", 10*time.Minute, "How long to wait for the operator to create the runner jobs")
+	cmd.Flags().DurationVar(&config.timeouts.Run, "timeout-run", 0, `How long to wait for the runner jobs to complete.
+Defaults to the duration declared in the script's options plus a margin of 10 percent, but at least five minutes, or one hour if the duration cannot be determined.`)
+}
+
+func bindTimeoutFlags(cmd *cobra.Command) error {
+	for key, flag := range timeoutFlags {
+		if err := viper.BindPFlag(key, cmd.Flags().Lookup(flag)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addRunFlags adds the flags that configure a run. The render command shares them.
+func addRunFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&config.namespace, "namespace", "n", defaultNamespace, "k8s namespace to run in")
+
+	cmd.Flags().StringVarP(&config.k6Arguments, "arguments", "a",
+		"",
+		`runs k6 with the given arguments. 
+You can provide a go template string (https://pkg.go.dev/text/template) here. See the documentation for supported variables.`)
+
+	cmd.Flags().StringToStringVarP((*map[string]string)(&config.k6Env), "env", "e", make(internal.K6Environment),
+		`runs k6 with the given environment arguments.
+You can provide a go template string (https://pkg.go.dev/text/template) here. See the documentation for supported variables.`)
+
+	cmd.Flags().StringVar(&config.envFile, "env-file", "", `Uploads the variables of a .env file as a short-lived secret and adds them to the runners' environment.
+The secret is deleted together with the test run.`)
+
+	cmd.Flags().IntVarP(&config.parallelism, "parallelism", "p", 1, "How many times a script should be run in parallel. Every parallel execution starts a k8s job.")
+
+	cmd.Flags().StringVarP(&config.dockerImage, "image", "i", "", "The OCI image to use for running k6")
+	cmd.Flags().StringVarP(&config.dockerImage, "ips", "s", "", "The name of the secret to use for pulling the OCI image. This is only used if the image is private.")
+	cmd.Flags().BoolVarP(&config.minify, "minify", "m", false, "Minify Javascript before uploading it to the cluster")
+	cmd.Flags().StringVar(&config.folder, "folder", "", "Uploads the provided a folder into a persistent volume on k8s.")
+	cmd.Flags().StringVarP(&config.manifest, "filename", "f", "", `Runs a pre-authored TestRun manifest instead of a script. The template variables are rendered into it first.
+The namespace of the manifest takes precedence over '--namespace'.`)
+	cmd.Flags().BoolVar(&config.bundleScript, "bundle-script", false, "Bundles the script the manifest's config map refers to, relative to the manifest, and uploads it into that config map")
+	cmd.Flags().StringArrayVar(&config.reports, "report", nil, `Writes a report after the run. Use <format>=<path>, the supported formats are 'html', 'junit' and 'markdown'.
+Markdown reports are appended to the file, e.g. --report 'markdown=$GITHUB_STEP_SUMMARY'. Can be used multiple times.`)
+	cmd.Flags().BoolVar(&config.summary, "summary", true, "Collect the summaries of all runners, check the thresholds against the combined data and print one global summary")
+
+	cmd.Flags().StringVar(&config.baseline, "baseline", "", "Compares the summary with a baseline and fails if there are regressions. Either a local JSON file, 'configmap/<name>' or 'run/<run id>'.")
+	cmd.Flags().StringVar(&config.saveBaseline, "save-baseline", "", "Saves the summary as a baseline. Either a local JSON file or 'configmap/<name>'.")
+	cmd.Flags().BoolVar(&config.history, "history", true, "Records the run in the local history, see the 'history' command")
+	cmd.Flags().BoolVar(&config.saveResults, "save-results", true, "Saves a results record of the run in the cluster, see the 'list' command")
+	cmd.Flags().IntVar(&config.keepResults, "keep-results", defaultKeepResults, "Number of results records kept in the cluster, older ones are deleted; 0 keeps all")
+	addResultsNamespaceFlag(cmd)
+	cmd.Flags().StringToStringVar(&runnerFlags.requests, "runner-requests", nil, "Resource requests of the runner pods, e.g. 'cpu=500m,memory=512Mi'")
+	cmd.Flags().StringToStringVar(&runnerFlags.limits, "runner-limits", nil, "Resource limits of the runner pods, e.g. 'cpu=1,memory=1Gi'")
+	cmd.Flags().StringToStringVar(&runnerFlags.nodeSelector, "runner-node-selector", nil, "Node selector of the runner pods, e.g. 'node-pool=load-generators'")
+	cmd.Flags().StringArrayVar(&runnerFlags.tolerations, "runner-toleration", nil, "Toleration of the runner pods in the syntax of 'kubectl taint', e.g. 'dedicated=k6:NoSchedule'. Can be used multiple times.")
+	cmd.Flags().StringVar(&runnerFlags.priorityClass, "runner-priority-class", "", "Priority class of the runner pods")
+	cmd.Flags().BoolVar(&runnerFlags.spread, "spread-runners", false, "Schedule the runner pods on different nodes if possible")
+	addToleranceFlags(cmd)
+	addRenderFlags(cmd)
 }
 
 func loadRunConfig() {
@@ -264,6 +833,57 @@ func loadRunConfig() {
 	config.imagePullSecret = viper.GetString("ips")
 	config.minify = viper.GetBool("minify")
 	config.folder = viper.GetString("folder")
+	config.summary = viper.GetBool("summary")
+	config.reports = viper.GetStringSlice("report")
+	config.baseline = viper.GetString("baseline")
+	config.saveBaseline = viper.GetString("save-baseline")
+	config.history = viper.GetBool("history")
+	config.saveResults = viper.GetBool("save-results")
+	config.keepResults = viper.GetInt("keep-results")
+	config.envFile = viper.GetString("env-file")
+	config.manifest = viper.GetString("filename")
+	config.bundleScript = viper.GetBool("bundle-script")
+	config.diagnostics = viper.GetString("diagnostics")
+	config.progress = viper.GetBool("progress")
+	var err error
+	config.initializer, err = internal.ParsePodOptions(viper.Get("initializer"))
+	cobra.CheckErr(wrapConfigErr("initializer", err))
+	config.starter, err = internal.ParsePodOptions(viper.Get("starter"))
+	cobra.CheckErr(wrapConfigErr("starter", err))
+	config.runner, err = internal.ParsePodOptions(viper.Get("runner"))
+	cobra.CheckErr(wrapConfigErr("runner", err))
+	config.guardrails, err = internal.ParseGuardrails(viper.Get("guardrails"), config.namespace)
+	cobra.CheckErr(wrapConfigErr("guardrails", err))
+	config.zones, err = internal.ParseZones(viper.Get("zones"))
+	cobra.CheckErr(wrapConfigErr("zones", err))
+	cobra.CheckErr(config.runner.SetResources(runnerFlags.requests, runnerFlags.limits))
+	for k, v := range runnerFlags.nodeSelector {
+		if config.runner.NodeSelector == nil {
+			config.runner.NodeSelector = map[string]string{}
+		}
+		config.runner.NodeSelector[k] = v
+	}
+	for _, t := range runnerFlags.tolerations {
+		toleration, err := internal.ParseToleration(t)
+		cobra.CheckErr(err)
+		config.runner.Tolerations = append(config.runner.Tolerations, toleration)
+	}
+	if runnerFlags.priorityClass != "" {
+		config.runner.PriorityClassName = runnerFlags.priorityClass
+	}
+	config.runner.SpreadAcrossNodes = config.runner.SpreadAcrossNodes || runnerFlags.spread
+	loadTolerances()
+	config.timeouts.Initialization = viper.GetDuration("timeouts.initialization")
+	config.timeouts.InitJob = viper.GetDuration("timeouts.initJob")
+	config.timeouts.JobCreation = viper.GetDuration("timeouts.jobCreation")
+	config.timeouts.Run = viper.GetDuration("timeouts.run")
+	config.sidecars.Quit = viper.GetBool("sidecars.quit")
+	config.sidecars.QuitCommands = maps.Clone(internal.DefaultSidecarQuitCommands)
+	for name, command := range viper.GetStringMapStringSlice("sidecars.quitCommands") {
+		config.sidecars.QuitCommands[name] = command
+	}
+	redactor, err = internal.NewRedactor(viper.GetStringSlice("redact.keys"), viper.GetStringSlice("redact.patterns"))
+	cobra.CheckErr(wrapConfigErr("redact", err))
 
 	// This is clumsy, but it is currently the only way to get a string map from an env variable.
 	// I've tested `Unmarshal` and `UnmarshalKey` but they don't work. I've also tested `viper.GetStringMapString`
@@ -288,4 +908,127 @@ func loadRunConfig() {
 			}
 		}
 	}
+	// the literal values of sensitive variables are registered right away, so they are redacted from everything
+	// that is printed or stored
+	redactor.RedactArgs(config.k6Arguments)
+	for _, po := range []internal.PodOptions{config.runner, config.initializer, config.starter} {
+		redactor.RedactPodOptions(po)
+	}
+}
+
+// newK6Config creates the configuration of the custom resource from the run configuration.
+func newK6Config(scriptPath, k6args string) internal.K6Config {
+	k6Config := internal.NewK6Config(config.k6Env, k6args, config.dockerImage, config.parallelism, config.imagePullSecret, config.folder, scriptPath)
+	k6Config.Runner = config.runner
+	k6Config.Initializer = config.initializer
+	k6Config.Starter = config.starter
+	return k6Config
+}
+
+// handleBaselines compares the summary with the configured baseline and saves it as a new baseline if requested.
+func handleBaselines(kc *internal.K8sClient, summary *internal.Summary) error {
+	resultsKc := kc.WithNamespace(resultsNamespace())
+	store := internal.SummaryStore{Baselines: kc, Results: &resultsKc}
+	var regressionErr error
+	if config.baseline != "" {
+		baseline, err := store.Load(context.Background(), config.baseline)
+		if err != nil {
+			return err
+		}
+		comparisons := internal.CompareSummaries(baseline, summary, tolerances)
+		fmt.Printf("Comparison with baseline '%s':\n%s\n", config.baseline, internal.FormatComparisons(comparisons))
+		if msg := internal.MissingWarning(comparisons); msg != "" {
+			printWarning(msg)
+		}
+		regressionErr = internal.RegressionError(comparisons)
+	}
+	if config.saveBaseline != "" {
+		fmt.Printf("Saving summary as baseline '%s'...\n", config.saveBaseline)
+		if err := store.Save(context.Background(), config.saveBaseline, summary); err != nil {
+			return errors.Join(regressionErr, fmt.Errorf("could not save baseline: %w", err))
+		}
+	}
+	return regressionErr
+}
+
+// mergeRunnerSummaries parses the summaries from the logs of all runners and merges them into one.
+func mergeRunnerSummaries(jobNames []string, runnerLogs []string) (*internal.Summary, error) {
+	var summaries []*internal.Summary
+	var errs []error
+	for i, logs := range runnerLogs {
+		summary, err := internal.ParseSummaryFromLogs(logs)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("job '%s': %w", jobNames[i], err))
+			continue
+		}
+		summaries = append(summaries, summary)
+	}
+	if len(summaries) == 0 {
+		return nil, errors.Join(errs...)
+	}
+	for _, err := range errs {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	return internal.MergeSummaries(summaries), nil
+}
+
+// effectiveRunConfig returns the configuration that determines how a script is run, so the run can be replayed.
+// Sensitive environment variables are redacted, also those in the pod options and the arguments; `rerun` takes them
+// from the current configuration.
+func effectiveRunConfig() map[string]interface{} {
+	env := make(map[string]interface{}, len(config.k6Env))
+	for k, v := range redactor.RedactEnv(config.k6Env) {
+		env[k] = v
+	}
+	return map[string]interface{}{
+		"namespace":     config.namespace,
+		"arguments":     redactor.RedactArgs(config.k6Arguments),
+		"env":           env,
+		"parallelism":   config.parallelism,
+		"image":         config.dockerImage,
+		"ips":           config.imagePullSecret,
+		"minify":        config.minify,
+		"folder":        config.folder,
+		"env-file":      config.envFile,
+		"filename":      config.manifest,
+		"bundle-script": config.bundleScript,
+		"summary":       config.summary,
+		"runner":        redactor.RedactPodOptions(config.runner),
+		"initializer":   redactor.RedactPodOptions(config.initializer),
+		"starter":       redactor.RedactPodOptions(config.starter),
+		"timeouts": map[string]interface{}{
+			"initialization": config.timeouts.Initialization.String(),
+			"initJob":        config.timeouts.InitJob.String(),
+			"jobCreation":    config.timeouts.JobCreation.String(),
+			"run":            config.timeouts.Run.String(),
+		},
+		"guardrails": config.guardrails,
+		"zones":      config.zones,
+		"sidecars": map[string]interface{}{
+			"quit":         config.sidecars.Quit,
+			"quitCommands": config.sidecars.QuitCommands,
+		},
+	}
+}
+
+// addSecretValuesToRedactor reads the secrets the runners' environment refers to, so their values are redacted
+// from the logs. Reading secrets may not be allowed, so this is best effort.
+func addSecretValuesToRedactor(kc *internal.K8sClient) {
+	for _, name := range config.runner.SecretNames() {
+		values, err := kc.GetSecretValues(context.Background(), name)
+		if err != nil {
+			fmt.Printf("Warning: could not read secret '%s', its values will not be redacted from the logs: %v\n", name, err)
+			continue
+		}
+		for _, v := range values {
+			redactor.AddValues(v)
+		}
+	}
+}
+
+func wrapConfigErr(section string, err error) error {
+	if err != nil {
+		return fmt.Errorf("invalid '%s' section in the config: %w", section, err)
+	}
+	return nil
 }
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/brodo/kubectl-k6/internal"
+	"sync"
+	"time"
+)
+
+// zoneStartInterval is how often the runners of the zones are checked until all of them are ready to start.
+const zoneStartInterval = 2 * time.Second
+
+// runZones creates a paused test run per zone from the uploaded script, starts the runners of all zones together
+// and collects the results per zone.
+func runZones(kc *internal.K8sClient, sps *internal.ScriptProperties, k6Config *internal.K6Config, templateVars *internal.TemplateVars, scriptPath, k6args string, report *internal.RunReport) error {
+	ctx := context.Background()
+	zoneSps := make([]internal.ScriptProperties, len(config.zones))
+	zoneConfigs := make([]internal.K6Config, len(config.zones))
+	report.Parallelism = 0
+	for i, zone := range config.zones {
+		zoneSps[i] = zone.ScriptProperties(*sps)
+		zoneConfigs[i] = zone.K6Config(*k6Config)
+		report.Parallelism += zone.Parallelism
+		zoneVars := *templateVars
+		zoneVars.ScriptProperties = zoneSps[i]
+		fmt.Printf("Uploading k6 custom resource '%s' for zone '%s'...\n", zoneSps[i].ResourceName(), zone.Name)
+		if err := kc.CreateCustomResource(ctx, &zoneConfigs[i], &zoneVars); err != nil {
+			fmt.Printf("Error creating custom resource '%s': %v\n", zoneSps[i].ResourceName(), err)
+			deleteZones(kc, zoneSps[:i])
+			if k6Config.EnvSecret != "" {
+				if delErr := kc.DeleteSecret(ctx, k6Config.EnvSecret); delErr != nil {
+					fmt.Printf("Error deleting secret '%s': %v\n", k6Config.EnvSecret, delErr)
+				}
+			}
+			return err
+		}
+	}
+	if k6Config.EnvSecret != "" {
+		// the runners of all zones share the secret, it is deleted with the test run of the first zone
+		if err := kc.SetSecretOwner(ctx, k6Config.EnvSecret, zoneSps[0].ResourceName()); err != nil {
+			fmt.Printf("Error setting the owner of secret '%s', it will not be deleted automatically: %v\n", k6Config.EnvSecret, err)
+		}
+	}
+	duration, timeout := runDurations(scriptPath, k6args)
+	if err := watchZones(kc, zoneSps, zoneConfigs, templateVars, duration, timeout, report); err != nil {
+		return err
+	}
+
+	report.StartStage("clean-up")
+	fmt.Println("Cleaning up...")
+	deleteZones(kc, zoneSps)
+	if err := kc.DeleteConfigMap(ctx, sps.ConfigMapName()); err != nil {
+		fmt.Printf("Error cleaning up resources: %v\n", err)
+	}
+	return nil
+}
+
+// deleteZones deletes the test runs of the zones.
+func deleteZones(kc *internal.K8sClient, zoneSps []internal.ScriptProperties) {
+	for _, zsp := range zoneSps {
+		if err := kc.DeleteCustomResource(context.Background(), zsp.ResourceName()); err != nil {
+			fmt.Printf("Error deleting custom resource '%s': %v\n", zsp.ResourceName(), err)
+		}
+	}
+}
+
+// watchZones waits until the runners of all zones are created, starts them together and waits for them to
+// complete. The logs and summaries are printed per zone, the thresholds and baselines are checked for all runners.
+// On errors, the diagnostics of the zone that failed are collected. The resources are not cleaned up.
+func watchZones(kc *internal.K8sClient, zoneSps []internal.ScriptProperties, zoneConfigs []internal.K6Config, templateVars *internal.TemplateVars, duration, runTimeout time.Duration, report *internal.RunReport) error {
+	runCtx, abort := context.WithCancelCause(context.Background())
+	defer abort(nil)
+	resNames := make([]string, len(zoneSps))
+	for i := range zoneSps {
+		resNames[i] = zoneSps[i].ResourceName()
+		watchPods(runCtx, abort, kc, resNames[i], templateVars.Time)
+	}
+
+	runnerJobs := make([][]string, len(zoneSps))
+	runnerCount := 0
+	for i, zone := range config.zones {
+		fmt.Printf("Preparing zone '%s'...\n", zone.Name)
+		jobs, err := waitUntilCreated(runCtx, kc, &zoneSps[i], templateVars, report)
+		if err != nil {
+			return failRun(kc, &zoneSps[i], templateVars, report, err, true)
+		}
+		runnerJobs[i] = jobs
+		runnerCount += zoneConfigs[i].Parallelism
+	}
+	report.StartStage("start")
+	fmt.Printf("Starting the %d runner(s) of %d zone(s) together...\n", runnerCount, len(zoneSps))
+	startCtx, cancel := context.WithTimeout(runCtx, config.timeouts.JobCreation)
+	err := stageError(runCtx, kc.StartRunnersTogether(startCtx, resNames, runnerCount, zoneStartInterval), "start", config.timeouts.JobCreation, "timeout-job-creation")
+	cancel()
+	if err != nil {
+		fmt.Printf("Error starting the runners: %v\n", err)
+		return failRun(kc, &zoneSps[0], templateVars, report, err, true)
+	}
+
+	report.StartStage("run")
+	fmt.Println("Waiting for run jobs to complete...")
+	stopMonitors := startMonitors(runCtx, kc, resNames, duration)
+	errs := make([]error, len(zoneSps))
+	var wg sync.WaitGroup
+	for i := range zoneSps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := waitForRunners(runCtx, kc, &zoneSps[i], &zoneConfigs[i], templateVars, runTimeout); err != nil {
+				errs[i] = fmt.Errorf("zone '%s': %w", config.zones[i].Name, err)
+			}
+		}()
+	}
+	wg.Wait()
+	err = errors.Join(errs...)
+	if gErr := stopMonitors(); gErr != nil {
+		err = errors.Join(gErr, err)
+	}
+	if err != nil && !(collectSummary() && internal.OnlyThresholdsFailed(err)) {
+		fmt.Printf("Error running run jobs!\n %v\n", err)
+		failed := 0
+		for i, zoneErr := range errs {
+			if zoneErr != nil {
+				failed = i
+				break
+			}
+		}
+		// the error contains the logs of the failed runners
+		return failRun(kc, &zoneSps[failed], templateVars, report, err, false)
+	}
+
+	report.StartStage("collect results")
+	var allJobs, allLogs []string
+	for i, zone := range config.zones {
+		fmt.Printf("Results of zone '%s':\n", zone.Name)
+		runnerLogs := collectRunnerLogs(kc, runnerJobs[i], templateVars.Time, report, true)
+		if collectSummary() {
+			if summary, sumErr := mergeRunnerSummaries(runnerJobs[i], runnerLogs); sumErr != nil {
+				fmt.Printf("Error collecting the k6 summaries of zone '%s': %v\n", zone.Name, sumErr)
+			} else {
+				fmt.Println(summary.String())
+			}
+		}
+		allJobs = append(allJobs, runnerJobs[i]...)
+		allLogs = append(allLogs, runnerLogs...)
+	}
+	fmt.Println("Results of all zones:")
+	return checkResults(kc, report, allJobs, allLogs, err)
+}
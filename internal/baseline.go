@@ -0,0 +1,100 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"os"
+	"strings"
+)
+
+const (
+	// configMapRefPrefix marks summary references that point to a baseline ConfigMap instead of a local file.
+	configMapRefPrefix = "configmap/"
+	// runRefPrefix marks summary references that point to the results record of a run in the cluster.
+	runRefPrefix = "run/"
+	// BaselineLabel is set on all ConfigMaps that contain a baseline summary.
+	BaselineLabel       = "k6k8s.io/baseline"
+	summaryConfigMapKey = "summary.json"
+)
+
+// SummaryStore loads and saves summaries referenced by local file paths, `configmap/<name>` for baseline
+// ConfigMaps and `run/<run id>` for the results records of runs in the cluster.
+type SummaryStore struct {
+	// Baselines is the client for the namespace that contains the baseline ConfigMaps.
+	Baselines *K8sClient
+	// Results is the client for the namespace that contains the results records.
+	Results *K8sClient
+}
+
+// Load loads the referenced summary.
+func (ss SummaryStore) Load(ctx context.Context, ref string) (*Summary, error) {
+	var data []byte
+	if name, ok := strings.CutPrefix(ref, configMapRefPrefix); ok {
+		cm, err := ss.Baselines.GetConfigMap(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("could not load baseline '%s': %w", ref, err)
+		}
+		content, ok := cm.Data[summaryConfigMapKey]
+		if !ok {
+			return nil, fmt.Errorf("the config map '%s' does not contain a summary", name)
+		}
+		data = []byte(content)
+	} else if runId, ok := strings.CutPrefix(ref, runRefPrefix); ok {
+		record, err := ss.Results.GetResult(ctx, runId)
+		if err != nil {
+			return nil, err
+		}
+		if record.Summary == nil {
+			return nil, fmt.Errorf("the results of run '%s' do not contain a summary", runId)
+		}
+		return record.Summary, nil
+	} else {
+		var err error
+		data, err = os.ReadFile(ref)
+		if err != nil {
+			return nil, fmt.Errorf("could not load baseline '%s': %w", ref, err)
+		}
+	}
+	var summary Summary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return nil, fmt.Errorf("could not parse summary '%s': %w", ref, err)
+	}
+	return &summary, nil
+}
+
+// Save stores a summary in a local JSON file or, if the reference starts with `configmap/`, in a labeled
+// ConfigMap so the whole team can use it as a baseline.
+func (ss SummaryStore) Save(ctx context.Context, ref string, summary *Summary) error {
+	if strings.HasPrefix(ref, runRefPrefix) {
+		return fmt.Errorf("cannot save a summary as '%s', the results of runs are saved automatically", ref)
+	}
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	name, ok := strings.CutPrefix(ref, configMapRefPrefix)
+	if !ok {
+		return os.WriteFile(ref, data, 0644)
+	}
+	return ss.Baselines.ApplyConfigMap(ctx, &v1.ConfigMap{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      name,
+			Namespace: ss.Baselines.namespace,
+			Labels:    map[string]string{BaselineLabel: "true"},
+		},
+		Data: map[string]string{summaryConfigMapKey: string(data)},
+	})
+}
+
+// IsConfigMapRef reports whether the summary reference points to a baseline config map.
+func IsConfigMapRef(ref string) bool {
+	return strings.HasPrefix(ref, configMapRefPrefix)
+}
+
+// IsRunRef reports whether the summary reference points to the results record of a run.
+func IsRunRef(ref string) bool {
+	return strings.HasPrefix(ref, runRefPrefix)
+}
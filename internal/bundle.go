@@ -1,14 +1,53 @@
 package internal
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/evanw/esbuild/pkg/api"
+	"path/filepath"
+	"strings"
 )
 
-func Bundle(sps *ScriptProperties, minify bool) (error, []byte) {
-	result := api.Build(api.BuildOptions{
-		EntryPoints:       []string{sps.ScriptPath},
+// Bundle bundles the script and all of its imports into a single file. If injectSummary is set, the script's
+// `handleSummary` is wrapped so that the machine-readable summary is printed to the runner's log as well.
+func Bundle(sps *ScriptProperties, minify bool, injectSummary bool) (error, []byte) {
+	err, contents, _ := BundleWithSourceMap(sps, minify, injectSummary)
+	return err, contents
+}
+
+// BundleWithSourceMap bundles the script like Bundle and returns the source map of the bundle as well. The bundle
+// does not refer to the source map, so it is identical to the one Bundle returns.
+func BundleWithSourceMap(sps *ScriptProperties, minify bool, injectSummary bool) (error, []byte, []byte) {
+	options := bundleOptions(minify)
+	options.EntryPoints = []string{sps.ScriptPath}
+	options.Metafile = injectSummary
+	result := api.Build(options)
+	err, contents, sourceMap := buildOutput(result)
+	if err != nil || !injectSummary {
+		return err, contents, sourceMap
+	}
+
+	err, exports := bundleExports(result.Metafile)
+	if err != nil {
+		return err, nil, nil
+	}
+	absPath, err := filepath.Abs(sps.ScriptPath)
+	if err != nil {
+		return err, nil, nil
+	}
+	options = bundleOptions(minify)
+	options.Stdin = &api.StdinOptions{
+		Contents:   summaryWrapper(filepath.ToSlash(absPath), exports),
+		ResolveDir: filepath.Dir(absPath),
+		Sourcefile: "kubectl-k6-summary.js",
+		Loader:     api.LoaderJS,
+	}
+	return buildOutput(api.Build(options))
+}
+
+func bundleOptions(minify bool) api.BuildOptions {
+	return api.BuildOptions{
 		Outfile:           "out.js",
 		Bundle:            true,
 		Write:             false,
@@ -18,13 +57,87 @@ func Bundle(sps *ScriptProperties, minify bool) (error, []byte) {
 		Platform:          api.PlatformNeutral,
 		External:          []string{"k6*"},
 		Target:            api.ES2015,
-	})
+		Sourcemap:         api.SourceMapExternal,
+	}
+}
+
+func buildOutput(result api.BuildResult) (error, []byte, []byte) {
 	errs := make([]error, len(result.Errors))
 	for i, message := range result.Errors {
 		errs[i] = fmt.Errorf("%s", message.Text)
 	}
-	if len(result.OutputFiles) == 0 {
-		return errors.Join(errs...), nil
+	var contents, sourceMap []byte
+	for _, file := range result.OutputFiles {
+		if strings.HasSuffix(file.Path, ".map") {
+			sourceMap = file.Contents
+		} else {
+			contents = file.Contents
+		}
+	}
+	return errors.Join(errs...), contents, sourceMap
+}
+
+func bundleExports(metafile string) (error, []string) {
+	var meta struct {
+		Outputs map[string]struct {
+			Exports []string `json:"exports"`
+		} `json:"outputs"`
+	}
+	if err := json.Unmarshal([]byte(metafile), &meta); err != nil {
+		return fmt.Errorf("could not read the esbuild metafile: %w", err), nil
+	}
+	for path, output := range meta.Outputs {
+		if !strings.HasSuffix(path, ".map") {
+			return nil, output.Exports
+		}
+	}
+	return fmt.Errorf("esbuild did not produce any output"), nil
+}
+
+// defaultSummaryTrendStats are the trend statistics k6 puts into the summary by default.
+var defaultSummaryTrendStats = []string{"avg", "min", "med", "max", "p(90)", "p(95)"}
+
+// summaryWrapper generates an entry point that re-exports everything from the script and replaces its
+// `handleSummary` with one that also prints the summary data between markers. The p99 is added to the
+// `summaryTrendStats` of the options, so it can be compared with baselines.
+func summaryWrapper(scriptPath string, exports []string) string {
+	var reExports []string
+	hasHandleSummary, hasOptions := false, false
+	for _, export := range exports {
+		switch export {
+		case "handleSummary":
+			hasHandleSummary = true
+		case "options":
+			hasOptions = true
+		default:
+			reExports = append(reExports, export)
+		}
+	}
+	path, _ := json.Marshal(scriptPath)
+	defaultStats, _ := json.Marshal(defaultSummaryTrendStats)
+	var sb strings.Builder
+	if len(reExports) > 0 {
+		sb.WriteString(fmt.Sprintf("export { %s } from %s;\n", strings.Join(reExports, ", "), path))
+	}
+	if hasOptions {
+		sb.WriteString(fmt.Sprintf("import { options as scriptOptions } from %s;\n", path))
+	} else {
+		sb.WriteString("const scriptOptions = {};\n")
+	}
+	sb.WriteString(fmt.Sprintf("const trendStats = (scriptOptions && scriptOptions.summaryTrendStats) || %s;\n", defaultStats))
+	sb.WriteString("export const options = Object.assign({}, scriptOptions, {\n")
+	sb.WriteString("  summaryTrendStats: trendStats.includes(\"p(99)\") ? trendStats : trendStats.concat([\"p(99)\"]),\n")
+	sb.WriteString("});\n")
+	if hasHandleSummary {
+		sb.WriteString(fmt.Sprintf("import { handleSummary as scriptHandleSummary } from %s;\n", path))
+	}
+	sb.WriteString("export function handleSummary(data) {\n")
+	if hasHandleSummary {
+		sb.WriteString("  const result = Object.assign({}, scriptHandleSummary(data));\n")
+	} else {
+		sb.WriteString("  const result = {};\n")
 	}
-	return errors.Join(errs...), result.OutputFiles[0].Contents
+	sb.WriteString(fmt.Sprintf("  result.stdout = (result.stdout || \"\") + \"\\n%s\" + JSON.stringify(data) + \"%s\\n\";\n", summaryBeginMarker, summaryEndMarker))
+	sb.WriteString("  return result;\n}\n")
+	return sb.String()
 }
@@ -0,0 +1,175 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrRegression is returned when a run is worse than its baseline by more than the tolerances allow.
+var ErrRegression = errors.New("regression detected")
+
+// Tolerances define how much worse than the baseline a run may be before it counts as a regression.
+type Tolerances struct {
+	// P95 is the allowed increase of the 95th percentile of `http_req_duration`, in percent.
+	P95 float64 `mapstructure:"p95"`
+	// P99 is the allowed increase of the 99th percentile of `http_req_duration`, in percent.
+	P99 float64 `mapstructure:"p99"`
+	// ErrorRate is the allowed increase of the `http_req_failed` rate, in percentage points.
+	ErrorRate float64 `mapstructure:"errorRate"`
+	// Throughput is the allowed decrease of the `http_reqs` rate, in percent.
+	Throughput float64 `mapstructure:"throughput"`
+}
+
+// Comparison is the result of comparing one value of a run with its baseline.
+type Comparison struct {
+	Name       string
+	Metric     string
+	Baseline   float64
+	Current    float64
+	Tolerance  float64
+	Regression bool
+	// Missing is set if the value is not contained in one of the summaries.
+	Missing bool
+	// percentagePoints is set for rates, whose change is measured absolutely.
+	percentagePoints bool
+	// higherIsBetter is set for values like the throughput, which regress when they decrease.
+	higherIsBetter bool
+}
+
+type comparedValue struct {
+	name             string
+	metric           string
+	value            string
+	tolerance        func(Tolerances) float64
+	percentagePoints bool
+	higherIsBetter   bool
+}
+
+var comparedValues = []comparedValue{
+	{name: "p95", metric: "http_req_duration", value: "p(95)", tolerance: func(t Tolerances) float64 { return t.P95 }},
+	{name: "p99", metric: "http_req_duration", value: "p(99)", tolerance: func(t Tolerances) float64 { return t.P99 }},
+	{name: "error rate", metric: "http_req_failed", value: "rate", tolerance: func(t Tolerances) float64 { return t.ErrorRate }, percentagePoints: true},
+	{name: "throughput", metric: "http_reqs", value: "rate", tolerance: func(t Tolerances) float64 { return t.Throughput }, higherIsBetter: true},
+}
+
+// CompareSummaries compares the p95, p99, error rate and throughput of a run with a baseline.
+func CompareSummaries(baseline, current *Summary, tol Tolerances) []Comparison {
+	comparisons := make([]Comparison, 0, len(comparedValues))
+	for _, cv := range comparedValues {
+		c := Comparison{
+			Name:             cv.name,
+			Metric:           cv.metric,
+			Tolerance:        cv.tolerance(tol),
+			percentagePoints: cv.percentagePoints,
+			higherIsBetter:   cv.higherIsBetter,
+		}
+		var okBaseline, okCurrent bool
+		c.Baseline, okBaseline = baseline.value(cv.metric, cv.value)
+		c.Current, okCurrent = current.value(cv.metric, cv.value)
+		c.Missing = !okBaseline || !okCurrent
+		if !c.Missing {
+			c.Regression = c.Change() > c.Tolerance
+		}
+		comparisons = append(comparisons, c)
+	}
+	return comparisons
+}
+
+// Change is how much worse the current value is than the baseline, in percent or percentage points.
+// Negative values are improvements.
+func (c Comparison) Change() float64 {
+	if c.percentagePoints {
+		return (c.Current - c.Baseline) * 100
+	}
+	if c.Baseline == 0 {
+		return 0
+	}
+	change := (c.Current - c.Baseline) / c.Baseline * 100
+	if c.higherIsBetter {
+		return -change
+	}
+	return change
+}
+
+// Regressions returns the comparisons that exceeded their tolerance.
+func Regressions(comparisons []Comparison) []Comparison {
+	var regressions []Comparison
+	for _, c := range comparisons {
+		if c.Regression {
+			regressions = append(regressions, c)
+		}
+	}
+	return regressions
+}
+
+// RegressionError returns an error wrapping ErrRegression if there are regressions, nil otherwise.
+func RegressionError(comparisons []Comparison) error {
+	regressions := Regressions(comparisons)
+	if len(regressions) == 0 {
+		return nil
+	}
+	names := make([]string, len(regressions))
+	for i, r := range regressions {
+		names[i] = r.Name
+	}
+	return fmt.Errorf("%w: %s", ErrRegression, strings.Join(names, ", "))
+}
+
+// MissingWarning describes the values that could not be compared because one of the summaries does not contain
+// them, e.g. the p99 of a baseline saved before the plugin added it to the summaries. It returns "" if all values were
+// compared.
+func MissingWarning(comparisons []Comparison) string {
+	var missing []string
+	for _, c := range comparisons {
+		if c.Missing {
+			missing = append(missing, fmt.Sprintf("%s (%s)", c.Name, c.Metric))
+		}
+	}
+	if len(missing) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s not compared because the baseline or the run does not contain it, regressions of it are not detected", strings.Join(missing, ", "))
+}
+
+// FormatComparisons renders the comparisons as a table.
+func FormatComparisons(comparisons []Comparison) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("   %-12s %14s %14s %10s %10s\n", "", "baseline", "current", "change", "tolerance"))
+	for _, c := range comparisons {
+		mark := "✓"
+		if c.Regression {
+			mark = "✗"
+		}
+		if c.Missing {
+			sb.WriteString(fmt.Sprintf(" - %-12s not contained in both summaries (%s)\n", c.Name, c.Metric))
+			continue
+		}
+		unit := "%"
+		if c.percentagePoints {
+			unit = "pp"
+		}
+		sb.WriteString(fmt.Sprintf(" %s %-12s %14s %14s %+9.1f%s %9.1f%s\n", mark, c.Name, c.format(c.Baseline), c.format(c.Current), c.Change(), unit, c.Tolerance, unit))
+	}
+	return sb.String()
+}
+
+func (c Comparison) format(v float64) string {
+	switch {
+	case c.percentagePoints:
+		return fmt.Sprintf("%.2f%%", v*100)
+	case c.higherIsBetter:
+		return fmt.Sprintf("%.2f/s", v)
+	default:
+		return fmt.Sprintf("%.2fms", v)
+	}
+}
+
+func (s *Summary) value(metric, value string) (float64, bool) {
+	m, ok := s.Metrics[metric]
+	if !ok {
+		return 0, false
+	}
+	v, ok := m.Values[value]
+	return v, ok
+}
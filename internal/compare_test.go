@@ -0,0 +1,51 @@
+package internal_test
+
+import (
+	"github.com/brodo/kubectl-k6/internal"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompareSummaries(t *testing.T) {
+	baseline := mergedSummary(t, 600, 6, 100, 10, 400, 200, 0)
+	tolerances := internal.Tolerances{P95: 10, P99: 10, ErrorRate: 1, Throughput: 10}
+
+	comparisons := internal.CompareSummaries(baseline, mergedSummary(t, 600, 6, 100, 10, 400, 210, 0), tolerances)
+	require.NoError(t, internal.RegressionError(comparisons))
+	require.True(t, comparisons[1].Missing, "p99 is not in the summary")
+	require.Equal(t, "p99 (http_req_duration) not compared because the baseline or the run does not contain it, regressions of it are not detected", internal.MissingWarning(comparisons))
+
+	comparisons = internal.CompareSummaries(baseline, mergedSummary(t, 480, 24, 100, 10, 400, 230, 0), tolerances)
+	require.Equal(t, []string{"p95", "error rate", "throughput"}, names(internal.Regressions(comparisons)))
+	require.ErrorIs(t, internal.RegressionError(comparisons), internal.ErrRegression)
+	require.InDelta(t, 15, comparisons[0].Change(), 0.001)
+	require.InDelta(t, 20, comparisons[3].Change(), 0.001)
+
+	withP99 := mergedSummary(t, 600, 6, 100, 10, 400, 200, 0)
+	withP99.Metrics["http_req_duration"].Values["p(99)"] = 380
+	comparisons = internal.CompareSummaries(withP99, withP99, tolerances)
+	require.Empty(t, internal.MissingWarning(comparisons))
+}
+
+func TestSaveAndLoadSummary(t *testing.T) {
+	summary := runnerSummary(t, 600, 6, 100, 10, 400, 200, 0)
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	require.NoError(t, internal.SummaryStore{}.Save(t.Context(), path, summary))
+	loaded, err := internal.SummaryStore{}.Load(t.Context(), path)
+	require.NoError(t, err)
+	require.Equal(t, summary, loaded)
+}
+
+func names(comparisons []internal.Comparison) []string {
+	n := make([]string, len(comparisons))
+	for i, c := range comparisons {
+		n[i] = c.Name
+	}
+	return n
+}
+
+// mergedSummary returns a summary of one runner whose rates have been computed like in a merged summary.
+func mergedSummary(t *testing.T, count, failed, avg, min, max, p95, checkFails int) *internal.Summary {
+	return internal.MergeSummaries([]*internal.Summary{runnerSummary(t, count, failed, avg, min, max, p95, checkFails)})
+}
@@ -0,0 +1,234 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoRunningRunners is returned if a test run has no running runner pods to control.
+var ErrNoRunningRunners = errors.New("no running runner pods")
+
+// K6StatusPatch changes the status of a k6 instance. Fields that are nil are not changed.
+type K6StatusPatch struct {
+	Paused  *bool  `json:"paused,omitempty"`
+	VUs     *int64 `json:"vus,omitempty"`
+	VUsMax  *int64 `json:"vus-max,omitempty"`
+	Stopped *bool  `json:"stopped,omitempty"`
+}
+
+// SetStatus patches the status of the k6 instance and returns the new status.
+func (c *K6APIClient) SetStatus(ctx context.Context, patch K6StatusPatch) (K6Status, error) {
+	body := map[string]interface{}{
+		"data": map[string]interface{}{"type": "status", "id": "default", "attributes": patch},
+	}
+	var data struct {
+		Attributes K6Status `json:"attributes"`
+	}
+	err := c.do(ctx, http.MethodPatch, "/v1/status", body, &data)
+	return data.Attributes, err
+}
+
+// Runner is the REST API of k6 in a runner pod.
+type Runner struct {
+	Pod string
+	API *K6APIClient
+}
+
+// ForwardRunners forwards to the REST API of every running runner pod of the test runs until ctx is done. The
+// runners are sorted by pod name. ErrNoRunningRunners is returned if no runner pod is running.
+func (kc *K8sClient) ForwardRunners(ctx context.Context, resNames ...string) ([]Runner, error) {
+	pods, err := kc.clientSet.CoreV1().Pods(kc.namespace).List(ctx, meta.ListOptions{LabelSelector: RunnerLabelSelector(resNames...)})
+	if err != nil {
+		return nil, err
+	}
+	var runners []Runner
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != v1.PodRunning || !k6Running(pod) {
+			continue
+		}
+		api, err := kc.ForwardK6API(ctx, pod.Name)
+		if err != nil {
+			return nil, err
+		}
+		runners = append(runners, Runner{Pod: pod.Name, API: api})
+	}
+	if len(runners) == 0 {
+		return nil, fmt.Errorf("test run '%s': %w", strings.Join(resNames, "', '"), ErrNoRunningRunners)
+	}
+	slices.SortFunc(runners, func(a, b Runner) int { return strings.Compare(a.Pod, b.Pod) })
+	return runners, nil
+}
+
+// RunTestRunNames returns the names of the test runs of the run id that have runner pods: `run-<run id>`, the
+// test runs of its zones named `run-<run id>-<zone>`, or for runs of manifests the test run named after the id.
+func (kc *K8sClient) RunTestRunNames(ctx context.Context, runId string) ([]string, error) {
+	pods, err := kc.clientSet.CoreV1().Pods(kc.namespace).List(ctx, meta.ListOptions{LabelSelector: TestRunLabel + ",runner=true"})
+	if err != nil {
+		return nil, err
+	}
+	resName := (&ScriptProperties{RunId: runId}).ResourceName()
+	var names []string
+	for _, pod := range pods.Items {
+		name := pod.Labels[TestRunLabel]
+		if name == resName || name == runId || strings.HasPrefix(name, resName+"-") {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// k6Running reports whether the k6 container of the pod is running.
+func k6Running(pod v1.Pod) bool {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == k6ContainerName || len(pod.Status.ContainerStatuses) == 1 {
+			return status.State.Running != nil
+		}
+	}
+	return false
+}
+
+// PauseRunners pauses or resumes all runners. It continues on errors and returns them joined.
+func PauseRunners(ctx context.Context, runners []Runner, paused bool) error {
+	var errs []error
+	for _, r := range runners {
+		if _, err := r.API.SetStatus(ctx, K6StatusPatch{Paused: &paused}); err != nil {
+			errs = append(errs, fmt.Errorf("pod '%s': %w", r.Pod, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// StopRunners stops k6 in all running runner pods of the test runs. k6 ends gracefully, i.e. it runs the teardown
+// and prints the summary.
+func (kc *K8sClient) StopRunners(ctx context.Context, resNames ...string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	runners, err := kc.ForwardRunners(ctx, resNames...)
+	if err != nil {
+		return fmt.Errorf("could not stop the runners: %w", err)
+	}
+	stopped := true
+	var errs []error
+	for _, r := range runners {
+		if _, err := r.API.SetStatus(ctx, K6StatusPatch{Stopped: &stopped}); err != nil {
+			errs = append(errs, fmt.Errorf("could not stop pod '%s': %w", r.Pod, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// StartRunnersTogether waits until the given number of runners of the paused test runs are running and their REST
+// API answers, then resumes all of them at the same instant.
+func (kc *K8sClient) StartRunnersTogether(ctx context.Context, resNames []string, runnerCount int, interval time.Duration) error {
+	var runners []Runner
+	var release context.CancelFunc = func() {}
+	defer func() { release() }()
+	err := wait.PollUntilContextCancel(ctx, interval, true, func(ctx context.Context) (done bool, err error) {
+		release()
+		var forwardCtx context.Context
+		forwardCtx, release = context.WithCancel(ctx)
+		runners, err = kc.ForwardRunners(forwardCtx, resNames...)
+		if forwardForbidden(err) {
+			return false, err
+		}
+		if err != nil || len(runners) < runnerCount {
+			return false, nil
+		}
+		for _, r := range runners {
+			if _, err := r.API.Status(forwardCtx); err != nil {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("%d of %d runners are ready: %w", len(runners), runnerCount, err)
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, len(runners))
+	paused := false
+	for i, r := range runners {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := r.API.SetStatus(ctx, K6StatusPatch{Paused: &paused}); err != nil {
+				errs[i] = fmt.Errorf("could not start pod '%s': %w", r.Pod, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// ScaleRunners splits the VUs across the runners proportionally to their maximum VUs and sets the VUs of every
+// runner, raising its maximum if necessary. It returns the VUs of every runner and continues on errors.
+func ScaleRunners(ctx context.Context, runners []Runner, vus int64) ([]int64, error) {
+	weights := make([]int64, len(runners))
+	for i, r := range runners {
+		status, err := r.API.Status(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("pod '%s': %w", r.Pod, err)
+		}
+		weights[i] = status.VUsMax
+	}
+	shares := SplitVUs(vus, weights)
+	var errs []error
+	for i, r := range runners {
+		patch := K6StatusPatch{VUs: &shares[i]}
+		if shares[i] > weights[i] {
+			patch.VUsMax = &shares[i]
+		}
+		if _, err := r.API.SetStatus(ctx, patch); err != nil {
+			errs = append(errs, fmt.Errorf("pod '%s': %w", r.Pod, err))
+		}
+	}
+	return shares, errors.Join(errs...)
+}
+
+// SplitVUs splits the VUs proportionally to the weights, e.g. the maximum VUs of the runners, so the shares add up to
+// vus. The VUs that are left after rounding down go to the largest remainders. Without weights, the VUs are split
+// evenly.
+func SplitVUs(vus int64, weights []int64) []int64 {
+	shares := make([]int64, len(weights))
+	if len(weights) == 0 {
+		return shares
+	}
+	var total int64
+	for _, w := range weights {
+		total += w
+	}
+	if total == 0 {
+		weights = slices.Repeat([]int64{1}, len(weights))
+		total = int64(len(weights))
+	}
+	remainders := make([]int64, len(weights))
+	left := vus
+	for i, w := range weights {
+		shares[i] = vus * w / total
+		remainders[i] = vus * w % total
+		left -= shares[i]
+	}
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	// stable, so equal remainders favor the first runners
+	slices.SortStableFunc(order, func(a, b int) int { return int(remainders[b] - remainders[a]) })
+	for i := 0; left > 0; i++ {
+		shares[order[i%len(order)]]++
+		left--
+	}
+	return shares
+}
@@ -0,0 +1,95 @@
+package internal_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/brodo/kubectl-k6/internal"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSplitVUs(t *testing.T) {
+	require.Equal(t, []int64{34, 33, 33}, internal.SplitVUs(100, []int64{10, 10, 10}))
+	require.Equal(t, []int64{75, 25}, internal.SplitVUs(100, []int64{30, 10}))
+	require.Equal(t, []int64{1, 2}, internal.SplitVUs(3, []int64{1, 2}))
+	require.Equal(t, []int64{2, 1}, internal.SplitVUs(3, []int64{0, 0}))
+	require.Equal(t, []int64{0, 0}, internal.SplitVUs(0, []int64{5, 5}))
+}
+
+// fakeK6 serves the status of a k6 instance and applies patches to it.
+func fakeK6(t *testing.T, status *internal.K6Status) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/status", r.URL.Path)
+		if r.Method == http.MethodPatch {
+			var body struct {
+				Data struct {
+					Attributes internal.K6StatusPatch `json:"attributes"`
+				} `json:"data"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			patch := body.Data.Attributes
+			if patch.Paused != nil {
+				status.Paused = *patch.Paused
+			}
+			if patch.VUsMax != nil {
+				status.VUsMax = *patch.VUsMax
+			}
+			if patch.VUs != nil {
+				status.VUs = *patch.VUs
+			}
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"attributes": status}}))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestScaleRunners(t *testing.T) {
+	first := &internal.K6Status{Running: true, VUs: 10, VUsMax: 20}
+	second := &internal.K6Status{Running: true, VUs: 10, VUsMax: 20}
+	runners := []internal.Runner{
+		{Pod: "run-abc-1-x", API: internal.NewK6APIClient(fakeK6(t, first).URL)},
+		{Pod: "run-abc-2-y", API: internal.NewK6APIClient(fakeK6(t, second).URL)},
+	}
+	shares, err := internal.ScaleRunners(context.Background(), runners, 50)
+	require.NoError(t, err)
+	require.Equal(t, []int64{25, 25}, shares)
+	require.Equal(t, internal.K6Status{Running: true, VUs: 25, VUsMax: 25}, *first)
+
+	require.NoError(t, internal.PauseRunners(context.Background(), runners, true))
+	require.True(t, first.Paused)
+	require.True(t, second.Paused)
+}
+
+func TestRunTestRunNames(t *testing.T) {
+	runnerPod := func(name, testRun string) *v1.Pod {
+		return &v1.Pod{ObjectMeta: meta.ObjectMeta{Name: name, Namespace: "k6", Labels: map[string]string{internal.TestRunLabel: testRun, "runner": "true"}}}
+	}
+	initializer := runnerPod("run-l4q5ph7vsplt2pxkkv4l-initializer-x", "run-l4q5ph7vsplt2pxkkv4l")
+	delete(initializer.Labels, "runner")
+	kc := internal.NewFakeK8sClient("k6",
+		runnerPod("run-l4q5ph7vsplt2pxkkv4l-eu-west-1-a", "run-l4q5ph7vsplt2pxkkv4l-eu-west"),
+		runnerPod("run-l4q5ph7vsplt2pxkkv4l-eu-west-2-b", "run-l4q5ph7vsplt2pxkkv4l-eu-west"),
+		runnerPod("run-l4q5ph7vsplt2pxkkv4l-spot-1-c", "run-l4q5ph7vsplt2pxkkv4l-spot"),
+		runnerPod("run-m8a2fx3qzcnd7wkrb1ty-1-d", "run-m8a2fx3qzcnd7wkrb1ty"),
+		runnerPod("checkout-1-e", "checkout"),
+		initializer,
+	)
+	ctx := context.Background()
+	names, err := kc.RunTestRunNames(ctx, "l4q5ph7vsplt2pxkkv4l")
+	require.NoError(t, err)
+	require.Equal(t, []string{"run-l4q5ph7vsplt2pxkkv4l-eu-west", "run-l4q5ph7vsplt2pxkkv4l-spot"}, names)
+	names, err = kc.RunTestRunNames(ctx, "m8a2fx3qzcnd7wkrb1ty")
+	require.NoError(t, err)
+	require.Equal(t, []string{"run-m8a2fx3qzcnd7wkrb1ty"}, names)
+	names, err = kc.RunTestRunNames(ctx, "checkout")
+	require.NoError(t, err)
+	require.Equal(t, []string{"checkout"}, names)
+	names, err = kc.RunTestRunNames(ctx, "unknown")
+	require.NoError(t, err)
+	require.Empty(t, names)
+}
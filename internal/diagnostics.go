@@ -0,0 +1,280 @@
+package internal
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	batch "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"os"
+	"path"
+	"path/filepath"
+	"sigs.k8s.io/yaml"
+	"strings"
+	"time"
+)
+
+// ContainerLogs are the logs of one container. Previous is set for the logs of the container's last restart.
+type ContainerLogs struct {
+	Pod       string
+	Container string
+	Previous  bool
+	Logs      string
+}
+
+func (l ContainerLogs) fileName() string {
+	name := l.Container
+	if l.Previous {
+		name += ".previous"
+	}
+	return path.Join("logs", l.Pod, name+".log")
+}
+
+// Diagnostics is everything needed to find out why a run failed. Anything that could not be collected is listed in
+// CollectionErrors.
+type Diagnostics struct {
+	RunId   string
+	Err     error
+	TestRun runtime.Object
+	Jobs    []batch.Job
+	Pods    []v1.Pod
+	Events  []v1.Event
+	// Logs are the logs of all containers of the test run's pods: the initializer, the starter and the runners.
+	Logs         []ContainerLogs
+	OperatorLogs []ContainerLogs
+	Bundle       []byte
+	SourceMap    []byte
+	// Config is the effective configuration of the run; sensitive values must be redacted already.
+	Config           map[string]interface{}
+	CollectionErrors []string
+}
+
+func (d *Diagnostics) collectionError(what string, err error) {
+	d.CollectionErrors = append(d.CollectionErrors, fmt.Sprintf("%s: %v", what, err))
+}
+
+// CollectDiagnostics collects the test run, its jobs, pods, events and the logs of all their containers as well as
+// the logs of the operator since the given time. Collecting continues on errors.
+func (kc *K8sClient) CollectDiagnostics(ctx context.Context, resName string, since time.Time) *Diagnostics {
+	d := &Diagnostics{}
+	testRun, err := kc.GetCustomResource(ctx, resName)
+	if err != nil {
+		d.collectionError("test run", err)
+	} else {
+		testRun.SetManagedFields(nil)
+		d.TestRun = testRun
+	}
+	selector := meta.ListOptions{LabelSelector: fmt.Sprintf("%s=%s", TestRunLabel, resName)}
+	jobs, err := kc.clientSet.BatchV1().Jobs(kc.namespace).List(ctx, selector)
+	if err != nil {
+		d.collectionError("jobs", err)
+	} else {
+		d.Jobs = jobs.Items
+	}
+	pods, err := kc.clientSet.CoreV1().Pods(kc.namespace).List(ctx, selector)
+	if err != nil {
+		d.collectionError("pods", err)
+	} else {
+		d.Pods = pods.Items
+	}
+	if d.Events, err = kc.GetTestRunEvents(ctx, resName, since); err != nil {
+		d.collectionError("events", err)
+	}
+	for _, pod := range d.Pods {
+		d.Logs = append(d.Logs, kc.collectContainerLogs(ctx, d, pod, nil)...)
+	}
+	operatorPods, err := kc.clientSet.CoreV1().Pods(kc.namespace).List(ctx, meta.ListOptions{LabelSelector: OperatorLabelSelector})
+	if err != nil {
+		d.collectionError("operator pods", err)
+	} else {
+		for _, pod := range operatorPods.Items {
+			d.OperatorLogs = append(d.OperatorLogs, kc.collectContainerLogs(ctx, d, pod, &meta.Time{Time: since})...)
+		}
+	}
+	return d
+}
+
+// collectContainerLogs returns the logs of all containers of the pod, including the logs before the last restart.
+func (kc *K8sClient) collectContainerLogs(ctx context.Context, d *Diagnostics, pod v1.Pod, since *meta.Time) []ContainerLogs {
+	var logs []ContainerLogs
+	statuses := append(append([]v1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		if status.State.Waiting != nil && status.RestartCount == 0 {
+			// the container never started
+			continue
+		}
+		for _, previous := range []bool{false, true} {
+			if previous && status.RestartCount == 0 {
+				continue
+			}
+			raw, err := kc.clientSet.CoreV1().Pods(kc.namespace).GetLogs(pod.Name, &v1.PodLogOptions{
+				Container: status.Name, Previous: previous, SinceTime: since,
+			}).Do(ctx).Raw()
+			l := ContainerLogs{Pod: pod.Name, Container: status.Name, Previous: previous, Logs: string(raw)}
+			if err != nil {
+				d.collectionError(l.fileName(), err)
+				continue
+			}
+			logs = append(logs, l)
+		}
+	}
+	return logs
+}
+
+// FailedContainerLogs returns the logs of the containers that have not exited successfully.
+func (d *Diagnostics) FailedContainerLogs() []ContainerLogs {
+	failed := make(map[string]bool)
+	for _, pod := range d.Pods {
+		statuses := append(append([]v1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+		for _, status := range statuses {
+			if terminated := status.State.Terminated; terminated == nil || terminated.ExitCode != 0 {
+				failed[pod.Name+"/"+status.Name] = true
+			}
+		}
+	}
+	var logs []ContainerLogs
+	for _, l := range d.Logs {
+		if failed[l.Pod+"/"+l.Container] {
+			logs = append(logs, l)
+		}
+	}
+	return logs
+}
+
+// OperatorErrors returns the errors and warnings the operator logged.
+func (d *Diagnostics) OperatorErrors() string {
+	var sb strings.Builder
+	for _, l := range d.OperatorLogs {
+		sb.WriteString(operatorErrorLines(l.Logs))
+	}
+	return sb.String()
+}
+
+// WriteTarball writes the diagnostics as a gzipped tarball with one directory named after the run. redact is
+// applied to every file but the bundle and the source map.
+func (d *Diagnostics) WriteTarball(w io.Writer, redact func(string) string) error {
+	if redact == nil {
+		redact = func(s string) string { return s }
+	}
+	files, err := d.files(redact)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	dir := "kubectl-k6-diagnostics-" + d.RunId
+	now := time.Now()
+	for _, f := range files {
+		header := &tar.Header{Name: path.Join(dir, f.name), Mode: 0600, Size: int64(len(f.contents)), ModTime: now}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(f.contents); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// WriteTarballFile writes the tarball to the path. If the path is a directory, the file is named after the run.
+// The path of the written file is returned.
+func (d *Diagnostics) WriteTarballFile(filePath string, redact func(string) string) (string, error) {
+	if info, err := os.Stat(filePath); err == nil && info.IsDir() {
+		filePath = filepath.Join(filePath, fmt.Sprintf("kubectl-k6-diagnostics-%s.tar.gz", d.RunId))
+	}
+	f, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", err
+	}
+	if err := d.WriteTarball(f, redact); err != nil {
+		f.Close()
+		return "", err
+	}
+	return filePath, f.Close()
+}
+
+type diagnosticsFile struct {
+	name     string
+	contents []byte
+}
+
+func (d *Diagnostics) files(redact func(string) string) ([]diagnosticsFile, error) {
+	var files []diagnosticsFile
+	text := func(name, contents string) {
+		files = append(files, diagnosticsFile{name: name, contents: []byte(redact(contents))})
+	}
+	manifests := func(name string, objects []runtime.Object) error {
+		if len(objects) == 0 {
+			return nil
+		}
+		formatted, err := FormatManifests(objects, "yaml", redact)
+		if err != nil {
+			return err
+		}
+		files = append(files, diagnosticsFile{name: name, contents: []byte(formatted)})
+		return nil
+	}
+
+	if d.Err != nil {
+		text("error.txt", d.Err.Error()+"\n")
+	}
+	if d.TestRun != nil {
+		if err := manifests("testrun.yaml", []runtime.Object{d.TestRun}); err != nil {
+			return nil, err
+		}
+	}
+	var jobs, pods []runtime.Object
+	for _, job := range d.Jobs {
+		job.TypeMeta = meta.TypeMeta{APIVersion: "batch/v1", Kind: "Job"}
+		job.ManagedFields = nil
+		jobs = append(jobs, &job)
+	}
+	for _, pod := range d.Pods {
+		pod.TypeMeta = meta.TypeMeta{APIVersion: "v1", Kind: "Pod"}
+		pod.ManagedFields = nil
+		pods = append(pods, &pod)
+	}
+	if err := manifests("jobs.yaml", jobs); err != nil {
+		return nil, err
+	}
+	if err := manifests("pods.yaml", pods); err != nil {
+		return nil, err
+	}
+	if len(d.Events) > 0 {
+		var sb strings.Builder
+		for _, event := range d.Events {
+			sb.WriteString(FormatEvent(event) + "\n")
+		}
+		text("events.txt", sb.String())
+	}
+	for _, l := range d.Logs {
+		text(l.fileName(), l.Logs)
+	}
+	for _, l := range d.OperatorLogs {
+		text(path.Join("operator", l.fileName()), l.Logs)
+	}
+	if d.Bundle != nil {
+		files = append(files, diagnosticsFile{name: "script/out.js", contents: d.Bundle})
+	}
+	if d.SourceMap != nil {
+		files = append(files, diagnosticsFile{name: "script/out.js.map", contents: d.SourceMap})
+	}
+	if d.Config != nil {
+		config, err := yaml.Marshal(d.Config)
+		if err != nil {
+			return nil, err
+		}
+		text("config.yaml", string(config))
+	}
+	if len(d.CollectionErrors) > 0 {
+		text("collection-errors.txt", strings.Join(d.CollectionErrors, "\n")+"\n")
+	}
+	return files, nil
+}
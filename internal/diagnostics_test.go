@@ -0,0 +1,81 @@
+package internal_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"github.com/brodo/kubectl-k6/internal"
+	"github.com/stretchr/testify/require"
+	"io"
+	batch "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+	"testing"
+)
+
+func TestDiagnostics_WriteTarball(t *testing.T) {
+	d := &internal.Diagnostics{
+		RunId: "l4q5ph7vsplt2pxkkv4l",
+		Err:   errors.New("job 'run-l4q5ph7vsplt2pxkkv4l-1' failed"),
+		Jobs:  []batch.Job{{ObjectMeta: meta.ObjectMeta{Name: "run-l4q5ph7vsplt2pxkkv4l-1"}}},
+		Pods: []v1.Pod{{
+			ObjectMeta: meta.ObjectMeta{Name: "run-l4q5ph7vsplt2pxkkv4l-1-x2v7q", ManagedFields: []meta.ManagedFieldsEntry{{Manager: "kubelet"}}},
+			Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "k6", Env: []v1.EnvVar{{Name: "TOKEN", Value: "s3cr3t"}}}}},
+		}},
+		Logs: []internal.ContainerLogs{
+			{Pod: "run-l4q5ph7vsplt2pxkkv4l-1-x2v7q", Container: "k6", Logs: "level=error msg=\"login with s3cr3t failed\"\n"},
+			{Pod: "run-l4q5ph7vsplt2pxkkv4l-1-x2v7q", Container: "k6", Previous: true, Logs: "attempt 1\n"},
+		},
+		OperatorLogs:     []internal.ContainerLogs{{Pod: "k6-operator-controller-manager-5c6f", Container: "manager", Logs: "INFO ok\nERROR no runner\n"}},
+		Bundle:           []byte("export default function () {}"),
+		SourceMap:        []byte(`{"version":3}`),
+		Config:           map[string]interface{}{"parallelism": 1},
+		CollectionErrors: []string{"events: forbidden"},
+	}
+	require.Equal(t, "ERROR no runner\n", d.OperatorErrors())
+
+	var buf bytes.Buffer
+	require.NoError(t, d.WriteTarball(&buf, func(s string) string { return strings.ReplaceAll(s, "s3cr3t", internal.RedactedPlaceholder) }))
+	gz, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+	files := map[string]string{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		contents, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[strings.TrimPrefix(header.Name, "kubectl-k6-diagnostics-l4q5ph7vsplt2pxkkv4l/")] = string(contents)
+	}
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	require.ElementsMatch(t, []string{
+		"error.txt", "jobs.yaml", "pods.yaml",
+		"logs/run-l4q5ph7vsplt2pxkkv4l-1-x2v7q/k6.log", "logs/run-l4q5ph7vsplt2pxkkv4l-1-x2v7q/k6.previous.log",
+		"operator/logs/k6-operator-controller-manager-5c6f/manager.log",
+		"script/out.js", "script/out.js.map", "config.yaml", "collection-errors.txt",
+	}, names)
+	require.Contains(t, files["pods.yaml"], "kind: Pod")
+	require.Contains(t, files["pods.yaml"], "value: '[REDACTED]'")
+	require.NotContains(t, files["pods.yaml"], "managedFields")
+	require.Equal(t, "level=error msg=\"login with [REDACTED] failed\"\n", files["logs/run-l4q5ph7vsplt2pxkkv4l-1-x2v7q/k6.log"])
+	require.Equal(t, "parallelism: 1\n", files["config.yaml"])
+}
+
+func TestBundleWithSourceMap(t *testing.T) {
+	sps := internal.NewScriptProperties("../examples/liveness/liveness.js")
+	err, bundle, sourceMap := internal.BundleWithSourceMap(&sps, false, true)
+	require.NoError(t, err)
+	require.Contains(t, string(sourceMap), "liveness.js")
+	require.NotContains(t, string(bundle), "sourceMappingURL")
+	err, plain := internal.Bundle(&sps, false, true)
+	require.NoError(t, err)
+	require.Equal(t, plain, bundle)
+}
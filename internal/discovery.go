@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"fmt"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/discovery"
+	"slices"
+	"strings"
+)
+
+const (
+	k6Group = "k6.io"
+	// LegacyK6Kind is the kind older operators use for test runs.
+	LegacyK6Kind = "K6"
+	// PrivateLoadZoneKind is the kind newer operators use for load zones of Grafana Cloud k6.
+	PrivateLoadZoneKind = "PrivateLoadZone"
+)
+
+// operatorInstallHint tells users how to fix a cluster without the k6 operator.
+const operatorInstallHint = "install it with 'kubectl apply --server-side -f https://raw.githubusercontent.com/grafana/k6-operator/main/bundle.yaml' " +
+	"or the Helm chart 'grafana/k6-operator', or use a context that points to a cluster with the operator"
+
+// testRunKinds are the kinds the operator runs tests with, the preferred kind first.
+var testRunKinds = []string{TestRunKind, LegacyK6Kind}
+
+// K6API is the k6 operator API the cluster serves.
+type K6API struct {
+	GVR  schema.GroupVersionResource
+	Kind string
+	// PrivateLoadZones is true if the operator serves private load zones in the same version.
+	PrivateLoadZones bool
+}
+
+// DefaultK6API is the API used if none was negotiated.
+var DefaultK6API = K6API{
+	GVR:  schema.GroupVersionResource{Group: k6Group, Version: "v1alpha1", Resource: "testruns"},
+	Kind: TestRunKind,
+}
+
+func (api K6API) APIVersion() string {
+	return api.GVR.GroupVersion().String()
+}
+
+func (api K6API) String() string {
+	return fmt.Sprintf("%s %s", api.APIVersion(), api.Kind)
+}
+
+// Apply adjusts the test run to the API, so it can be submitted to the cluster.
+func (api K6API) Apply(tr *TestRun) {
+	tr.TypeMeta = meta.TypeMeta{APIVersion: api.APIVersion(), Kind: api.Kind}
+}
+
+// DiscoverK6API uses API discovery to find the k6 custom resources the cluster serves. The newest version that
+// serves test runs is chosen; the TestRun kind is preferred over the legacy K6 kind within a version.
+func DiscoverK6API(d discovery.DiscoveryInterface) (K6API, error) {
+	groups, err := d.ServerGroups()
+	if err != nil {
+		return K6API{}, fmt.Errorf("could not discover the APIs of the cluster: %w", err)
+	}
+	var versions []string
+	for _, group := range groups.Groups {
+		if group.Name != k6Group {
+			continue
+		}
+		for _, v := range group.Versions {
+			versions = append(versions, v.Version)
+		}
+	}
+	if len(versions) == 0 {
+		return K6API{}, fmt.Errorf("the k6 operator is not installed: the cluster does not serve the API group '%s'; %s", k6Group, operatorInstallHint)
+	}
+	slices.SortFunc(versions, func(a, b string) int { return -version.CompareKubeAwareVersionStrings(a, b) })
+
+	var found []string
+	for _, v := range versions {
+		gv := schema.GroupVersion{Group: k6Group, Version: v}
+		resources, err := d.ServerResourcesForGroupVersion(gv.String())
+		if err != nil {
+			return K6API{}, fmt.Errorf("could not discover the resources of '%s': %w", gv, err)
+		}
+		byKind := make(map[string]meta.APIResource, len(resources.APIResources))
+		for _, r := range resources.APIResources {
+			// skip subresources like 'testruns/status'
+			if r.Kind != "" && !strings.Contains(r.Name, "/") {
+				byKind[r.Kind] = r
+				found = append(found, fmt.Sprintf("%s %s", gv, r.Kind))
+			}
+		}
+		for _, kind := range testRunKinds {
+			if r, ok := byKind[kind]; ok {
+				_, plz := byKind[PrivateLoadZoneKind]
+				return K6API{GVR: gv.WithResource(r.Name), Kind: kind, PrivateLoadZones: plz}, nil
+			}
+		}
+	}
+	return K6API{}, fmt.Errorf("the k6 operator in the cluster serves neither the %s nor the %s kind (found %v); %s", TestRunKind, LegacyK6Kind, found, operatorInstallHint)
+}
+
+// FindK6API looks up the resource of a k6 kind in the given API version, e.g. for pre-authored manifests.
+func FindK6API(d discovery.DiscoveryInterface, apiVersion, kind string) (K6API, error) {
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return K6API{}, err
+	}
+	if gv.Group != k6Group || !slices.Contains(testRunKinds, kind) {
+		return K6API{}, fmt.Errorf("'%s %s' is not a k6 test run, use the %s or the %s kind of the group '%s'", apiVersion, kind, TestRunKind, LegacyK6Kind, k6Group)
+	}
+	resources, err := d.ServerResourcesForGroupVersion(apiVersion)
+	if err != nil {
+		return K6API{}, fmt.Errorf("the cluster does not serve '%s', check the apiVersion of the manifest or install the k6 operator: %w", apiVersion, err)
+	}
+	api := K6API{Kind: kind}
+	for _, r := range resources.APIResources {
+		switch {
+		case strings.Contains(r.Name, "/"): // subresources like 'testruns/status'
+		case r.Kind == kind:
+			api.GVR = gv.WithResource(r.Name)
+		case r.Kind == PrivateLoadZoneKind:
+			api.PrivateLoadZones = true
+		}
+	}
+	if api.GVR.Resource == "" {
+		return K6API{}, fmt.Errorf("the cluster does not serve the %s kind in '%s'", kind, apiVersion)
+	}
+	return api, nil
+}
@@ -0,0 +1,68 @@
+package internal_test
+
+import (
+	"github.com/brodo/kubectl-k6/internal"
+	"github.com/stretchr/testify/require"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"testing"
+)
+
+func TestDiscoverK6API(t *testing.T) {
+	d := &fake.FakeDiscovery{Fake: &k8stesting.Fake{}}
+	_, err := internal.DiscoverK6API(d)
+	require.ErrorContains(t, err, "the k6 operator is not installed")
+
+	d.Resources = []*meta.APIResourceList{{
+		GroupVersion: "k6.io/v1alpha1",
+		APIResources: []meta.APIResource{{Name: "k6s", Kind: "K6"}, {Name: "k6s/status", Kind: "K6"}},
+	}}
+	api, err := internal.DiscoverK6API(d)
+	require.NoError(t, err)
+	require.Equal(t, "K6", api.Kind)
+	require.Equal(t, "k6s", api.GVR.Resource)
+
+	tVars := internal.NewTemplateVars(internal.NewScriptProperties("liveness.js"))
+	k6Conf := internal.NewK6Config(internal.K6Environment{}, "", "", 1, "", "", "liveness.js")
+	tr, err := internal.NewTestRun(&k6Conf, &tVars)
+	require.NoError(t, err)
+	api.Apply(tr)
+	u, err := tr.ToUnstructured()
+	require.NoError(t, err)
+	require.Equal(t, "k6.io/v1alpha1", u.GetAPIVersion())
+	require.Equal(t, "K6", u.GetKind())
+
+	d.Resources = append(d.Resources,
+		&meta.APIResourceList{
+			GroupVersion: "k6.io/v1alpha1",
+			APIResources: []meta.APIResource{{Name: "testruns", Kind: "TestRun"}},
+		},
+		&meta.APIResourceList{
+			GroupVersion: "k6.io/v1beta1",
+			APIResources: []meta.APIResource{{Name: "testruns", Kind: "TestRun"}, {Name: "privateloadzones", Kind: "PrivateLoadZone"}},
+		})
+	api, err = internal.DiscoverK6API(d)
+	require.NoError(t, err)
+	require.Equal(t, "k6.io/v1beta1 TestRun", api.String())
+	require.True(t, api.PrivateLoadZones)
+
+	d.Resources = []*meta.APIResourceList{{
+		GroupVersion: "k6.io/v1alpha1",
+		APIResources: []meta.APIResource{{Name: "privateloadzones", Kind: "PrivateLoadZone"}},
+	}}
+	_, err = internal.DiscoverK6API(d)
+	require.ErrorContains(t, err, "serves neither the TestRun nor the K6 kind")
+
+	d.Resources = []*meta.APIResourceList{{
+		GroupVersion: "k6.io/v1alpha1",
+		APIResources: []meta.APIResource{{Name: "testruns", Kind: "TestRun"}, {Name: "k6s", Kind: "K6"}, {Name: "k6s/status", Kind: "K6"}},
+	}}
+	api, err = internal.FindK6API(d, "k6.io/v1alpha1", "K6")
+	require.NoError(t, err)
+	require.Equal(t, "k6s", api.GVR.Resource)
+	_, err = internal.FindK6API(d, "k6.io/v1beta1", "TestRun")
+	require.ErrorContains(t, err, "the cluster does not serve 'k6.io/v1beta1'")
+	_, err = internal.FindK6API(d, "v1", "ConfigMap")
+	require.ErrorContains(t, err, "is not a k6 test run")
+}
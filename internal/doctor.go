@@ -0,0 +1,215 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	authorization "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"slices"
+	"strings"
+)
+
+// OperatorLabelSelector selects the pods of the k6 operator.
+const OperatorLabelSelector = "app.kubernetes.io/name=k6-operator"
+
+// defaultStorageClassAnnotation marks the storage class that is used for claims without a storage class.
+const defaultStorageClassAnnotation = "storageclass.kubernetes.io/is-default-class"
+
+type CheckStatus string
+
+const (
+	CheckPassed  CheckStatus = "PASS"
+	CheckWarning CheckStatus = "WARN"
+	CheckFailed  CheckStatus = "FAIL"
+	CheckSkipped CheckStatus = "SKIP"
+)
+
+// CheckResult is one item of the checklist printed by the doctor command.
+type CheckResult struct {
+	Name   string
+	Status CheckStatus
+	Detail string
+	// Fix tells the user how to resolve a failed check.
+	Fix string
+}
+
+func (r CheckResult) String() string {
+	s := fmt.Sprintf("[%s] %s", r.Status, r.Name)
+	if r.Detail != "" {
+		s += ": " + r.Detail
+	}
+	if r.Fix != "" && (r.Status == CheckFailed || r.Status == CheckWarning) {
+		s += "\n       fix: " + r.Fix
+	}
+	return s
+}
+
+// CheckConnection checks that the API server is reachable.
+func (kc *K8sClient) CheckConnection() CheckResult {
+	name := "cluster is reachable"
+	v, err := kc.clientSet.Discovery().ServerVersion()
+	if err != nil {
+		return CheckResult{Name: name, Status: CheckFailed, Detail: err.Error(),
+			Fix: "check the context with 'kubectl config current-context', the VPN and that your credentials are not expired"}
+	}
+	return CheckResult{Name: name, Status: CheckPassed, Detail: fmt.Sprintf("Kubernetes %s", v.GitVersion)}
+}
+
+// CheckK6API checks that the k6 operator's custom resources are installed. The negotiated API is used by the
+// following checks.
+func (kc *K8sClient) CheckK6API() CheckResult {
+	name := "k6 custom resources are installed"
+	k6API, err := DiscoverK6API(kc.clientSet.Discovery())
+	if err != nil {
+		return CheckResult{Name: name, Status: CheckFailed, Detail: err.Error(), Fix: "install the k6 operator: " + operatorInstallHint}
+	}
+	kc.k6API = k6API
+	if k6API.Kind == LegacyK6Kind {
+		return CheckResult{Name: name, Status: CheckWarning, Detail: k6API.String(),
+			Fix: "the operator only serves the legacy K6 kind, consider upgrading it"}
+	}
+	return CheckResult{Name: name, Status: CheckPassed, Detail: k6API.String()}
+}
+
+// CheckOperatorPod checks that an operator pod runs in the namespace, so its logs can be shown when a run fails.
+func (kc *K8sClient) CheckOperatorPod(ctx context.Context) CheckResult {
+	name := "operator pod is running"
+	pods, err := kc.clientSet.CoreV1().Pods(kc.namespace).List(ctx, meta.ListOptions{LabelSelector: OperatorLabelSelector})
+	if err != nil {
+		return CheckResult{Name: name, Status: CheckFailed, Detail: err.Error(), Fix: "allow listing pods in the namespace"}
+	}
+	var phases []string
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == "Running" {
+			return CheckResult{Name: name, Status: CheckPassed, Detail: fmt.Sprintf("pod '%s' in namespace '%s'", pod.Name, kc.namespace)}
+		}
+		phases = append(phases, fmt.Sprintf("%s is %s", pod.Name, pod.Status.Phase))
+	}
+	if len(phases) > 0 {
+		return CheckResult{Name: name, Status: CheckFailed, Detail: strings.Join(phases, ", "),
+			Fix: fmt.Sprintf("inspect the pods with 'kubectl describe pods -n %s -l %s'", kc.namespace, OperatorLabelSelector)}
+	}
+	return CheckResult{Name: name, Status: CheckFailed, Detail: fmt.Sprintf("no pod with label '%s' in namespace '%s'", OperatorLabelSelector, kc.namespace),
+		Fix: "run the tests in the namespace of the operator with '--namespace', or install the operator: " + operatorInstallHint}
+}
+
+// CheckPermissions checks every permission the configured features need with SelfSubjectAccessReviews. There is one
+// result per resource.
+func (kc *K8sClient) CheckPermissions(ctx context.Context, features Features) []CheckResult {
+	var results []CheckResult
+	for _, p := range RequiredPermissions(kc.k6API, features) {
+		namespace := kc.permissionNamespace(p)
+		name := fmt.Sprintf("permission to %s %s", strings.Join(p.Verbs, ", "), p)
+		if namespace != "" {
+			name += fmt.Sprintf(" in namespace '%s'", namespace)
+		}
+		var denied []string
+		var reviewErr error
+		for _, verb := range p.Verbs {
+			attrs := &authorization.ResourceAttributes{Verb: verb, Group: p.Group, Resource: p.Resource, Subresource: p.Subresource}
+			attrs.Namespace = namespace
+			review, err := kc.clientSet.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorization.SelfSubjectAccessReview{
+				Spec: authorization.SelfSubjectAccessReviewSpec{ResourceAttributes: attrs},
+			}, meta.CreateOptions{})
+			if err != nil {
+				reviewErr = err
+				break
+			}
+			if !review.Status.Allowed {
+				denied = append(denied, verb)
+			}
+		}
+		switch {
+		case reviewErr != nil:
+			results = append(results, CheckResult{Name: name, Status: CheckFailed, Detail: reviewErr.Error(),
+				Fix: "the permission could not be reviewed, check it with 'kubectl auth can-i'"})
+		case len(denied) > 0:
+			results = append(results, CheckResult{Name: name, Status: CheckFailed, Detail: "denied: " + strings.Join(denied, ", "),
+				Fix: fmt.Sprintf("ask an administrator to grant the permission, check it with 'kubectl auth can-i %s %s%s'", denied[0], p, namespaceFlag(namespace))})
+		default:
+			results = append(results, CheckResult{Name: name, Status: CheckPassed})
+		}
+	}
+	return results
+}
+
+// permissionNamespace returns the namespace a permission is needed in, or an empty string for cluster-scoped resources.
+func (kc *K8sClient) permissionNamespace(p Permission) string {
+	switch {
+	case p.ClusterScoped:
+		return ""
+	case p.Namespace != "":
+		return p.Namespace
+	}
+	return kc.namespace
+}
+
+func namespaceFlag(namespace string) string {
+	if namespace == "" {
+		return ""
+	}
+	return " -n " + namespace
+}
+
+// CheckNamespace checks that the namespace the tests run in exists.
+func (kc *K8sClient) CheckNamespace(ctx context.Context) CheckResult {
+	name := fmt.Sprintf("namespace '%s' exists", kc.namespace)
+	_, err := kc.clientSet.CoreV1().Namespaces().Get(ctx, kc.namespace, meta.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		return CheckResult{Name: name, Status: CheckFailed, Detail: "not found",
+			Fix: fmt.Sprintf("create it with 'kubectl create namespace %s' or use '--namespace'", kc.namespace)}
+	case errors.IsForbidden(err):
+		return CheckResult{Name: name, Status: CheckWarning, Detail: "not allowed to read namespaces, the namespace could not be verified"}
+	case err != nil:
+		return CheckResult{Name: name, Status: CheckFailed, Detail: err.Error()}
+	}
+	return CheckResult{Name: name, Status: CheckPassed}
+}
+
+// CheckImagePullSecret checks that the configured image pull secret exists in the namespace.
+func (kc *K8sClient) CheckImagePullSecret(ctx context.Context, secretName string) CheckResult {
+	name := "image pull secret exists"
+	if secretName == "" {
+		return CheckResult{Name: name, Status: CheckSkipped, Detail: "no image pull secret configured"}
+	}
+	name = fmt.Sprintf("image pull secret '%s' exists", secretName)
+	_, err := kc.clientSet.CoreV1().Secrets(kc.namespace).Get(ctx, secretName, meta.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		return CheckResult{Name: name, Status: CheckFailed, Detail: fmt.Sprintf("not found in namespace '%s'", kc.namespace),
+			Fix: fmt.Sprintf("create it with 'kubectl create secret docker-registry %s -n %s ...'", secretName, kc.namespace)}
+	case err != nil:
+		return CheckResult{Name: name, Status: CheckFailed, Detail: err.Error()}
+	}
+	return CheckResult{Name: name, Status: CheckPassed}
+}
+
+// CheckDefaultStorageClass checks that a default storage class exists, which folder mode needs. The check only
+// fails if folder mode is used.
+func (kc *K8sClient) CheckDefaultStorageClass(ctx context.Context, folderMode bool) CheckResult {
+	name := "default storage class exists"
+	missing := CheckWarning
+	if folderMode {
+		missing = CheckFailed
+	}
+	classes, err := kc.clientSet.StorageV1().StorageClasses().List(ctx, meta.ListOptions{})
+	if err != nil {
+		return CheckResult{Name: name, Status: missing, Detail: err.Error(), Fix: "allow listing storage classes to verify the check"}
+	}
+	var names []string
+	for _, sc := range classes.Items {
+		if sc.Annotations[defaultStorageClassAnnotation] == "true" {
+			return CheckResult{Name: name, Status: CheckPassed, Detail: sc.Name}
+		}
+		names = append(names, sc.Name)
+	}
+	detail := "the cluster has no storage classes"
+	if len(names) > 0 {
+		slices.Sort(names)
+		detail = fmt.Sprintf("none of %s is the default", strings.Join(names, ", "))
+	}
+	return CheckResult{Name: name, Status: missing, Detail: detail,
+		Fix: fmt.Sprintf("folder mode ('--folder') needs one, mark a storage class with the annotation '%s=true'", defaultStorageClassAnnotation)}
+}
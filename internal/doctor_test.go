@@ -0,0 +1,138 @@
+package internal_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/brodo/kubectl-k6/internal"
+	"github.com/stretchr/testify/require"
+	authorization "k8s.io/api/authorization/v1"
+	v1 "k8s.io/api/core/v1"
+	storage "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stesting "k8s.io/client-go/testing"
+	"testing"
+)
+
+func TestCheckResult_String(t *testing.T) {
+	failed := internal.CheckResult{Name: "namespace 'load' exists", Status: internal.CheckFailed, Detail: "not found", Fix: "create it"}
+	require.Equal(t, "[FAIL] namespace 'load' exists: not found\n       fix: create it", failed.String())
+	passed := internal.CheckResult{Name: "cluster is reachable", Status: internal.CheckPassed, Fix: "not shown"}
+	require.Equal(t, "[PASS] cluster is reachable", passed.String())
+}
+
+// forbid makes the fake cluster deny the verb on the resource.
+func forbid(kc *internal.K8sClient, verb, resource string) {
+	internal.FakeClientset(kc).PrependReactor(verb, resource, func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewForbidden(schema.GroupResource{Resource: resource}, "", fmt.Errorf("denied"))
+	})
+}
+
+func TestCheckNamespace(t *testing.T) {
+	ctx := context.Background()
+	kc := internal.NewFakeK8sClient("load", &v1.Namespace{ObjectMeta: meta.ObjectMeta{Name: "load"}})
+	require.Equal(t, internal.CheckPassed, kc.CheckNamespace(ctx).Status)
+
+	kc = internal.NewFakeK8sClient("load")
+	result := kc.CheckNamespace(ctx)
+	require.Equal(t, internal.CheckFailed, result.Status)
+	require.Equal(t, "not found", result.Detail)
+	require.Contains(t, result.Fix, "kubectl create namespace load")
+
+	forbid(&kc, "get", "namespaces")
+	require.Equal(t, internal.CheckWarning, kc.CheckNamespace(ctx).Status)
+}
+
+func TestCheckImagePullSecret(t *testing.T) {
+	ctx := context.Background()
+	kc := internal.NewFakeK8sClient("load", &v1.Secret{ObjectMeta: meta.ObjectMeta{Name: "registry", Namespace: "load"}})
+	require.Equal(t, internal.CheckSkipped, kc.CheckImagePullSecret(ctx, "").Status)
+	require.Equal(t, internal.CheckPassed, kc.CheckImagePullSecret(ctx, "registry").Status)
+	result := kc.CheckImagePullSecret(ctx, "missing")
+	require.Equal(t, internal.CheckFailed, result.Status)
+	require.Equal(t, "not found in namespace 'load'", result.Detail)
+	require.Contains(t, result.Fix, "kubectl create secret docker-registry missing -n load")
+}
+
+func TestCheckDefaultStorageClass(t *testing.T) {
+	ctx := context.Background()
+	standard := &storage.StorageClass{ObjectMeta: meta.ObjectMeta{Name: "standard"}}
+	kc := internal.NewFakeK8sClient("load", standard)
+	result := kc.CheckDefaultStorageClass(ctx, false)
+	require.Equal(t, internal.CheckWarning, result.Status)
+	require.Equal(t, "none of standard is the default", result.Detail)
+	result = kc.CheckDefaultStorageClass(ctx, true)
+	require.Equal(t, internal.CheckFailed, result.Status)
+
+	kc = internal.NewFakeK8sClient("load")
+	require.Equal(t, "the cluster has no storage classes", kc.CheckDefaultStorageClass(ctx, true).Detail)
+	forbid(&kc, "list", "storageclasses")
+	require.Equal(t, internal.CheckWarning, kc.CheckDefaultStorageClass(ctx, false).Status)
+
+	fast := &storage.StorageClass{ObjectMeta: meta.ObjectMeta{Name: "fast", Annotations: map[string]string{"storageclass.kubernetes.io/is-default-class": "true"}}}
+	kc = internal.NewFakeK8sClient("load", standard, fast)
+	result = kc.CheckDefaultStorageClass(ctx, true)
+	require.Equal(t, internal.CheckPassed, result.Status)
+	require.Equal(t, "fast", result.Detail)
+}
+
+func TestCheckOperatorPod(t *testing.T) {
+	ctx := context.Background()
+	operatorPod := func(name string, phase v1.PodPhase) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: meta.ObjectMeta{Name: name, Namespace: "k6-operator-system", Labels: map[string]string{"app.kubernetes.io/name": "k6-operator"}},
+			Status:     v1.PodStatus{Phase: phase},
+		}
+	}
+	kc := internal.NewFakeK8sClient("k6-operator-system", operatorPod("k6-operator-a", v1.PodPending), operatorPod("k6-operator-b", v1.PodRunning))
+	result := kc.CheckOperatorPod(ctx)
+	require.Equal(t, internal.CheckPassed, result.Status)
+	require.Equal(t, "pod 'k6-operator-b' in namespace 'k6-operator-system'", result.Detail)
+
+	kc = internal.NewFakeK8sClient("k6-operator-system", operatorPod("k6-operator-a", v1.PodPending))
+	result = kc.CheckOperatorPod(ctx)
+	require.Equal(t, internal.CheckFailed, result.Status)
+	require.Equal(t, "k6-operator-a is Pending", result.Detail)
+
+	kc = internal.NewFakeK8sClient("load", operatorPod("k6-operator-a", v1.PodRunning))
+	result = kc.CheckOperatorPod(ctx)
+	require.Equal(t, internal.CheckFailed, result.Status)
+	require.Contains(t, result.Detail, "in namespace 'load'")
+	require.Contains(t, result.Fix, "--namespace")
+
+	forbid(&kc, "list", "pods")
+	result = kc.CheckOperatorPod(ctx)
+	require.Equal(t, internal.CheckFailed, result.Status)
+	require.Equal(t, "allow listing pods in the namespace", result.Fix)
+}
+
+func TestCheckPermissions(t *testing.T) {
+	kc := internal.NewFakeK8sClient("load")
+	internal.FakeClientset(&kc).PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorization.SelfSubjectAccessReview)
+		attrs := review.Spec.ResourceAttributes
+		if attrs.Resource == "events" {
+			return true, nil, fmt.Errorf("the server is unavailable")
+		}
+		review.Status.Allowed = !(attrs.Resource == "configmaps" && attrs.Verb == "delete")
+		return true, review, nil
+	})
+	results := kc.CheckPermissions(context.Background(), internal.Features{})
+	byName := map[string]internal.CheckResult{}
+	for _, r := range results {
+		byName[r.Name] = r
+	}
+	require.Len(t, byName, len(internal.RequiredPermissions(internal.DefaultK6API, internal.Features{})))
+
+	configMaps := byName["permission to create, get, delete configmaps in namespace 'load'"]
+	require.Equal(t, internal.CheckFailed, configMaps.Status)
+	require.Equal(t, "denied: delete", configMaps.Detail)
+	require.Contains(t, configMaps.Fix, "kubectl auth can-i delete configmaps -n load")
+
+	require.Equal(t, internal.CheckPassed, byName["permission to list pods in namespace 'load'"].Status)
+	events := byName["permission to list, watch events in namespace 'load'"]
+	require.Equal(t, internal.CheckFailed, events.Status)
+	require.Equal(t, "the server is unavailable", events.Detail)
+}
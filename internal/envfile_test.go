@@ -0,0 +1,32 @@
+package internal_test
+
+import (
+	"github.com/brodo/kubectl-k6/internal"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	require.NoError(t, os.WriteFile(path, []byte(`# credentials for GetNodes.js
+CLIENT_ID=k6-load-test
+export CLIENT_SECRET="s3cr=t\nline"
+TOKEN_URL='https://auth.example.com/token' 
+TREE_DEPTH=9 # comment
+
+`), 0600))
+	env, err := internal.ParseEnvFile(path)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"CLIENT_ID":     "k6-load-test",
+		"CLIENT_SECRET": "s3cr=t\nline",
+		"TOKEN_URL":     "https://auth.example.com/token",
+		"TREE_DEPTH":    "9",
+	}, env)
+
+	require.NoError(t, os.WriteFile(path, []byte("NOT A PAIR\n"), 0600))
+	_, err = internal.ParseEnvFile(path)
+	require.ErrorContains(t, err, ":1: expected KEY=value")
+}
@@ -0,0 +1,19 @@
+package internal
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// NewFakeK8sClient returns a client of a fake cluster that contains the objects.
+func NewFakeK8sClient(namespace string, objects ...runtime.Object) K8sClient {
+	return K8sClient{clientSet: fake.NewClientset(objects...), namespace: namespace, k6API: DefaultK6API, events: &eventCache{}}
+}
+
+// FakeClientset returns the fake cluster of a client created with NewFakeK8sClient, e.g. to add reactors.
+func FakeClientset(kc *K8sClient) *fake.Clientset {
+	return kc.clientSet.(*fake.Clientset)
+}
+
+// LabelValue exposes labelValue to the tests.
+var LabelValue = labelValue
@@ -0,0 +1,371 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	errors2 "errors"
+	"fmt"
+	"k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"slices"
+	"strings"
+	"time"
+)
+
+// MetricGuardrail trips if a statistic of a k6 metric, aggregated across all runners, stays above a limit for a
+// duration. The statistics are checked within the windows between the polls of the runners, see WindowMetric.
+type MetricGuardrail struct {
+	Metric string `json:"metric"`
+	// Stat is the statistic of the metric, e.g. 'rate', 'count', 'avg' or 'p(95)'. It defaults to 'rate' for rates
+	// and counters and to 'value' for gauges.
+	Stat  string     `json:"stat"`
+	Above float64    `json:"above"`
+	For   k6Duration `json:"for"`
+}
+
+func (g MetricGuardrail) String() string {
+	s := g.Metric
+	if g.Stat != "" {
+		s += " " + g.Stat
+	}
+	s += fmt.Sprintf(" > %v", g.Above)
+	if g.For > 0 {
+		s += fmt.Sprintf(" for %s", time.Duration(g.For))
+	}
+	return s
+}
+
+// RestartGuardrail trips if the containers of a deployment's pods restart more than Max times during the run.
+type RestartGuardrail struct {
+	Deployment string `json:"deployment"`
+	// Namespace of the deployment, defaults to the namespace the tests run in.
+	Namespace string `json:"namespace"`
+	Max       int32  `json:"max"`
+}
+
+func (g RestartGuardrail) String() string {
+	return fmt.Sprintf("restarts of deployment %s/%s > %d", g.Namespace, g.Deployment, g.Max)
+}
+
+// Guardrails stop a run when the system under test suffers, even if the thresholds of every single runner hold.
+type Guardrails struct {
+	Metrics  []MetricGuardrail  `json:"metrics"`
+	Restarts []RestartGuardrail `json:"restarts"`
+}
+
+// Empty reports whether no guardrail is configured.
+func (g Guardrails) Empty() bool {
+	return len(g.Metrics) == 0 && len(g.Restarts) == 0
+}
+
+// RestartNamespaces returns the namespaces of the deployments whose restarts are watched.
+func (g Guardrails) RestartNamespaces() []string {
+	var namespaces []string
+	for _, r := range g.Restarts {
+		if !slices.Contains(namespaces, r.Namespace) {
+			namespaces = append(namespaces, r.Namespace)
+		}
+	}
+	return namespaces
+}
+
+// ParseGuardrails decodes the `guardrails` section of the configuration. Deployments without a namespace are looked
+// up in defaultNamespace.
+func ParseGuardrails(value interface{}, defaultNamespace string) (Guardrails, error) {
+	var g Guardrails
+	if value == nil {
+		return g, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return g, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&g); err != nil {
+		return g, err
+	}
+	for _, m := range g.Metrics {
+		if m.Metric == "" {
+			return g, fmt.Errorf("all metric guardrails need a metric")
+		}
+	}
+	for i, r := range g.Restarts {
+		if r.Deployment == "" {
+			return g, fmt.Errorf("all restart guardrails need a deployment")
+		}
+		if r.Namespace == "" {
+			g.Restarts[i].Namespace = defaultNamespace
+		}
+	}
+	return g, nil
+}
+
+// GuardrailError is returned when a guardrail tripped and the run was stopped.
+type GuardrailError struct {
+	Guardrail string
+	Value     string
+}
+
+func (e *GuardrailError) Error() string {
+	return fmt.Sprintf("guardrail '%s' tripped (%s)", e.Guardrail, e.Value)
+}
+
+// requestsMetric is the counter whose requests weight the rates and averages of the runners.
+const requestsMetric = "http_reqs"
+
+// PolledStatus is the status of a runner at the time it was polled.
+type PolledStatus struct {
+	RunnerStatus
+	At time.Time
+}
+
+// WindowMetric aggregates a statistic of a metric across the runners within the window since the previous poll of
+// each runner. The REST API of k6 returns the values since the start of the test, so the values of the window are
+// derived from the difference to the previous values; runners without previous values count from the start. Counts
+// are summed, and the `rate` of counters is the sum of the runners' rates within the window. Rates and the averages
+// of trends are weighted by the requests (`http_reqs`) of each runner in the window. Other statistics of trends
+// cannot be derived for a window, so the highest value of any runner since the start is taken, and for gauges the
+// highest current value. It returns false if no runner reported the statistic or there were no requests in the
+// window.
+func WindowMetric(previous map[string]PolledStatus, current []RunnerStatus, now time.Time, metric, stat string) (float64, bool) {
+	var metricType string
+	for _, r := range current {
+		if m, ok := r.Metrics[metric]; ok {
+			metricType = m.Type
+			break
+		}
+	}
+	if metricType == "" {
+		return 0, false
+	}
+	if stat == "" {
+		stat = defaultStat(metricType)
+	}
+	switch {
+	case metricType == "counter":
+		return windowCount(previous, current, now, metric, stat)
+	case metricType == "rate" || metricType == "trend" && stat == "avg":
+		return windowWeighted(previous, current, metric, stat)
+	}
+	var values []float64
+	for _, r := range current {
+		if v, ok := r.Metrics[metric].Sample[stat]; ok {
+			values = append(values, v)
+		}
+	}
+	if len(values) == 0 {
+		return 0, false
+	}
+	return slices.Max(values), true
+}
+
+// windowCount sums the counts of a counter, or their rates, within the window.
+func windowCount(previous map[string]PolledStatus, current []RunnerStatus, now time.Time, metric, stat string) (float64, bool) {
+	var sum float64
+	found := false
+	for _, r := range current {
+		count, ok := r.Metrics[metric].Sample["count"]
+		if !ok {
+			continue
+		}
+		prev, hasPrev := previous[r.Pod]
+		prevCount, ok := prev.Metrics[metric].Sample["count"]
+		// a runner whose count decreased was restarted
+		hasPrev = hasPrev && ok && prevCount <= count && now.After(prev.At)
+		switch {
+		case stat == "count" && hasPrev:
+			sum += count - prevCount
+		case stat == "rate" && hasPrev:
+			sum += (count - prevCount) / now.Sub(prev.At).Seconds()
+		default:
+			v, ok := r.Metrics[metric].Sample[stat]
+			if !ok {
+				continue
+			}
+			sum += v
+		}
+		found = true
+	}
+	return sum, found
+}
+
+// windowWeighted returns the rate or average within the window, weighted by the requests of each runner.
+func windowWeighted(previous map[string]PolledStatus, current []RunnerStatus, metric, stat string) (float64, bool) {
+	var total, requests float64
+	for _, r := range current {
+		value, ok := r.Metrics[metric].Sample[stat]
+		count, okCount := r.Metrics[requestsMetric].Sample["count"]
+		if !ok || !okCount {
+			continue
+		}
+		prev := previous[r.Pod]
+		prevValue, ok := prev.Metrics[metric].Sample[stat]
+		prevCount, okCount := prev.Metrics[requestsMetric].Sample["count"]
+		if !ok || !okCount || prevCount > count {
+			prevValue, prevCount = 0, 0
+		}
+		total += value*count - prevValue*prevCount
+		requests += count - prevCount
+	}
+	if requests <= 0 {
+		return 0, false
+	}
+	return max(total/requests, 0), true
+}
+
+func defaultStat(metricType string) string {
+	if metricType == "gauge" {
+		return "value"
+	}
+	return "rate"
+}
+
+// guardrailState tracks since when the limit of a metric guardrail is exceeded.
+type guardrailState struct {
+	MetricGuardrail
+	since time.Time
+}
+
+// check returns an error if the limit has been exceeded for long enough. Windows without a value, e.g. without
+// requests, do not change the state.
+func (s *guardrailState) check(previous map[string]PolledStatus, runners []RunnerStatus, now time.Time) error {
+	value, ok := WindowMetric(previous, runners, now, s.Metric, s.Stat)
+	if !ok {
+		return nil
+	}
+	if value <= s.Above {
+		s.since = time.Time{}
+		return nil
+	}
+	if s.since.IsZero() {
+		s.since = now
+	}
+	if now.Sub(s.since) < time.Duration(s.For) {
+		return nil
+	}
+	return &GuardrailError{Guardrail: s.String(), Value: fmt.Sprintf("%s is %.4g", strings.TrimSpace(s.Metric+" "+s.Stat), value)}
+}
+
+// CheckMetricGuardrails returns a function that checks the metric guardrails against the status of the runners at
+// the given time. It keeps the previous status of every runner, so the metrics are checked within the windows
+// between the polls, and tracks how long each limit has been exceeded.
+func CheckMetricGuardrails(guardrails []MetricGuardrail) func(runners []RunnerStatus, now time.Time) error {
+	states := make([]*guardrailState, len(guardrails))
+	for i, g := range guardrails {
+		states[i] = &guardrailState{MetricGuardrail: g}
+	}
+	previous := make(map[string]PolledStatus)
+	return func(runners []RunnerStatus, now time.Time) error {
+		var err error
+		for _, s := range states {
+			if err = s.check(previous, runners, now); err != nil {
+				break
+			}
+		}
+		for _, r := range runners {
+			// runners that could not be reached keep their last values
+			if r.Err == nil && r.Metrics != nil {
+				previous[r.Pod] = PolledStatus{RunnerStatus: r, At: now}
+			}
+		}
+		return err
+	}
+}
+
+// deploymentRestarts returns the restarts of the containers of the deployment's pods by pod.
+func (kc *K8sClient) deploymentRestarts(ctx context.Context, g RestartGuardrail) (map[types.UID]int32, error) {
+	deployment, err := kc.clientSet.AppsV1().Deployments(g.Namespace).Get(ctx, g.Deployment, meta.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	selector, err := meta.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+	pods, err := kc.clientSet.CoreV1().Pods(g.Namespace).List(ctx, meta.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+	restarts := make(map[types.UID]int32, len(pods.Items))
+	for _, pod := range pods.Items {
+		restarts[pod.UID] = podRestarts(pod)
+	}
+	return restarts, nil
+}
+
+func podRestarts(pod v1.Pod) int32 {
+	var restarts int32
+	for _, status := range append(append([]v1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...) {
+		restarts += status.RestartCount
+	}
+	return restarts
+}
+
+// RestartsSince counts the restarts of the current pods since the baseline. Pods that are not in the baseline were
+// created during the run, so all their restarts count.
+func RestartsSince(baseline, current map[types.UID]int32) int32 {
+	var restarts int32
+	for uid, count := range current {
+		restarts += max(count-baseline[uid], 0)
+	}
+	return restarts
+}
+
+// WatchGuardrails checks the guardrails of the test runs every interval until ctx is done. When one trips, the
+// runners are stopped through the REST API of k6, so they end gracefully, and the GuardrailError is returned. warn is
+// called for guardrails that cannot be checked.
+func (kc *K8sClient) WatchGuardrails(ctx context.Context, resNames []string, guardrails Guardrails, interval time.Duration, warn func(string)) error {
+	forwards := kc.newRunnerForwards(resNames)
+	defer forwards.close()
+	checkMetrics := CheckMetricGuardrails(guardrails.Metrics)
+	baselines := make([]map[types.UID]int32, len(guardrails.Restarts))
+	warned := make(map[string]bool)
+	warnOnce := func(msg string) {
+		if !warned[msg] {
+			warned[msg] = true
+			warn(msg)
+		}
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		var tripped error
+		if len(guardrails.Metrics) > 0 {
+			statuses, err := forwards.poll(ctx)
+			if err != nil {
+				return fmt.Errorf("cannot check the metric guardrails: %w", err)
+			}
+			tripped = checkMetrics(statuses, time.Now())
+		}
+		for i, g := range guardrails.Restarts {
+			if tripped != nil {
+				break
+			}
+			restarts, err := kc.deploymentRestarts(ctx, g)
+			if err != nil {
+				if ctx.Err() == nil {
+					warnOnce(fmt.Sprintf("cannot check guardrail '%s': %v", g, err))
+				}
+				continue
+			}
+			if baselines[i] == nil {
+				baselines[i] = restarts
+				continue
+			}
+			if n := RestartsSince(baselines[i], restarts); n > g.Max {
+				tripped = &GuardrailError{Guardrail: g.String(), Value: fmt.Sprintf("%d restarts", n)}
+			}
+		}
+		if tripped != nil {
+			return errors2.Join(tripped, kc.StopRunners(ctx, resNames...))
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package internal_test
+
+import (
+	"encoding/json"
+	"github.com/brodo/kubectl-k6/internal"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+	"testing"
+	"time"
+)
+
+func TestParseGuardrails(t *testing.T) {
+	raw := map[string]interface{}{
+		"metrics":  []interface{}{map[string]interface{}{"metric": "http_req_failed", "above": 0.2, "for": "30s"}},
+		"restarts": []interface{}{map[string]interface{}{"deployment": "checkout", "max": 3}},
+	}
+	g, err := internal.ParseGuardrails(raw, "staging")
+	require.NoError(t, err)
+	require.Equal(t, "http_req_failed > 0.2 for 30s", g.Metrics[0].String())
+	require.Equal(t, "restarts of deployment staging/checkout > 3", g.Restarts[0].String())
+	require.Equal(t, []string{"staging"}, g.RestartNamespaces())
+
+	// the effective configuration is replayed, so the guardrails must survive a round trip
+	data, err := json.Marshal(g)
+	require.NoError(t, err)
+	var decoded interface{}
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	again, err := internal.ParseGuardrails(decoded, "other")
+	require.NoError(t, err)
+	require.Equal(t, g, again)
+
+	_, err = internal.ParseGuardrails(map[string]interface{}{"metrics": []interface{}{map[string]interface{}{"metirc": "x"}}}, "")
+	require.ErrorContains(t, err, "metirc")
+}
+
+func TestWindowMetric(t *testing.T) {
+	runner := func(pod string, failed, requests, avg, p95 float64) internal.RunnerStatus {
+		return internal.RunnerStatus{Pod: pod, Metrics: map[string]internal.K6Metric{
+			"http_req_failed":   {Type: "rate", Sample: map[string]float64{"rate": failed}},
+			"http_reqs":         {Type: "counter", Sample: map[string]float64{"count": requests, "rate": requests / 10}},
+			"http_req_duration": {Type: "trend", Sample: map[string]float64{"avg": avg, "p(95)": p95}},
+		}}
+	}
+	start := time.Date(2024, 5, 3, 10, 0, 0, 0, time.UTC)
+	first := []internal.RunnerStatus{runner("a", 0.1, 100, 100, 300), runner("b", 0.3, 50, 400, 500)}
+
+	// without previous values, the runners count from the start and the rates are weighted by their requests
+	value, ok := internal.WindowMetric(nil, first, start, "http_req_failed", "")
+	require.True(t, ok)
+	require.InDelta(t, 25.0/150, value, 1e-9)
+	value, _ = internal.WindowMetric(nil, first, start, "http_reqs", "count")
+	require.Equal(t, 150.0, value)
+	value, _ = internal.WindowMetric(nil, first, start, "http_req_duration", "avg")
+	require.InDelta(t, 200, value, 1e-9)
+	_, ok = internal.WindowMetric(nil, first, start, "checks", "")
+	require.False(t, ok)
+
+	previous := map[string]internal.PolledStatus{"a": {RunnerStatus: first[0], At: start}, "b": {RunnerStatus: first[1], At: start}}
+	// runner a failed 10 of 100 requests in the window, runner b all of its 50 requests
+	second := []internal.RunnerStatus{runner("a", 0.1, 200, 100, 300), runner("b", 0.65, 100, 400, 500)}
+	now := start.Add(10 * time.Second)
+	value, _ = internal.WindowMetric(previous, second, now, "http_req_failed", "rate")
+	require.InDelta(t, 0.4, value, 1e-9)
+	value, _ = internal.WindowMetric(previous, second, now, "http_reqs", "count")
+	require.Equal(t, 150.0, value)
+	value, _ = internal.WindowMetric(previous, second, now, "http_reqs", "")
+	require.Equal(t, 15.0, value)
+	// percentiles cannot be derived for a window
+	value, _ = internal.WindowMetric(previous, second, now, "http_req_duration", "p(95)")
+	require.Equal(t, 500.0, value)
+	// without requests in the window, there is no rate
+	_, ok = internal.WindowMetric(previous, first, now, "http_req_failed", "")
+	require.False(t, ok)
+}
+
+func TestCheckMetricGuardrails(t *testing.T) {
+	g, err := internal.ParseGuardrails(map[string]interface{}{
+		"metrics": []interface{}{map[string]interface{}{"metric": "http_req_failed", "above": 0.2, "for": "30s"}},
+	}, "")
+	require.NoError(t, err)
+	check := internal.CheckMetricGuardrails(g.Metrics)
+	var failed, requests float64
+	// status adds the requests of a window to the values since the start, which the REST API of k6 returns
+	status := func(windowFailed, windowRequests float64) []internal.RunnerStatus {
+		failed += windowFailed
+		requests += windowRequests
+		return []internal.RunnerStatus{{Pod: "a", Metrics: map[string]internal.K6Metric{
+			"http_req_failed": {Type: "rate", Sample: map[string]float64{"rate": failed / requests}},
+			"http_reqs":       {Type: "counter", Sample: map[string]float64{"count": requests}},
+		}}}
+	}
+	start := time.Now()
+	require.NoError(t, check(status(0, 100_000), start))
+	// an outage late in a long test trips although the rate since the start stays low
+	require.NoError(t, check(status(500, 1000), start.Add(10*time.Second)))
+	require.NoError(t, check(status(500, 1000), start.Add(20*time.Second)))
+	// the limit must be exceeded the whole time
+	require.NoError(t, check(status(10, 1000), start.Add(30*time.Second)))
+	require.NoError(t, check(status(500, 1000), start.Add(40*time.Second)))
+	require.NoError(t, check(status(500, 1000), start.Add(60*time.Second)))
+	err = check(status(500, 1000), start.Add(70*time.Second))
+	var tripped *internal.GuardrailError
+	require.ErrorAs(t, err, &tripped)
+	require.Equal(t, "guardrail 'http_req_failed > 0.2 for 30s' tripped (http_req_failed is 0.5)", err.Error())
+
+	// a spike early in the test does not keep the guardrail tripped after the system recovered
+	check = internal.CheckMetricGuardrails(g.Metrics)
+	failed, requests = 0, 0
+	require.NoError(t, check(status(900, 1000), start))
+	for i := 1; i <= 6; i++ {
+		require.NoError(t, check(status(10, 100), start.Add(time.Duration(i)*10*time.Second)))
+	}
+}
+
+func TestRestartsSince(t *testing.T) {
+	baseline := map[types.UID]int32{"a": 2, "b": 0}
+	// pod b was replaced by pod c, which already restarted once
+	current := map[types.UID]int32{"a": 4, "c": 1}
+	require.Equal(t, int32(3), internal.RestartsSince(baseline, current))
+}
@@ -0,0 +1,180 @@
+package internal
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Outcomes of a run, as recorded in the history.
+const (
+	OutcomePassed           = "passed"
+	OutcomeThresholdsFailed = "thresholds-failed"
+	OutcomeRegression       = "regression"
+	OutcomeError            = "error"
+)
+
+// HistoryRecord describes one run in the local history.
+type HistoryRecord struct {
+	RunId      string                 `json:"runId"`
+	ScriptPath string                 `json:"scriptPath"`
+	ScriptHash string                 `json:"scriptHash,omitempty"`
+	BundlePath string                 `json:"bundlePath,omitempty"`
+	GitCommit  string                 `json:"gitCommit,omitempty"`
+	Start      time.Time              `json:"start"`
+	End        time.Time              `json:"end"`
+	Config     map[string]interface{} `json:"config"`
+	Stages     []StageTiming          `json:"stages,omitempty"`
+	Outcome    string                 `json:"outcome"`
+	Error      string                 `json:"error,omitempty"`
+	Summary    *Summary               `json:"summary,omitempty"`
+}
+
+// History is an append-only store of run records in a JSON lines file.
+type History struct {
+	dir string
+}
+
+// NewHistory opens the history in the user's config directory.
+func NewHistory() (error, History) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return err, History{}
+	}
+	return nil, History{dir: filepath.Join(configDir, "kubectl-k6")}
+}
+
+func (h *History) path() string {
+	return filepath.Join(h.dir, "history.jsonl")
+}
+
+// ClassifyOutcome maps the error a run returned to one of the outcomes.
+func ClassifyOutcome(err error) string {
+	switch {
+	case err == nil:
+		return OutcomePassed
+	case errors.Is(err, ErrRegression):
+		return OutcomeRegression
+	case errors.Is(err, ErrThresholdsCrossed):
+		return OutcomeThresholdsFailed
+	default:
+		return OutcomeError
+	}
+}
+
+// NewHistoryRecord creates the history record for a finished run.
+func NewHistoryRecord(report *RunReport, config map[string]interface{}) HistoryRecord {
+	record := HistoryRecord{
+		RunId:      report.RunId,
+		ScriptPath: report.ScriptPath,
+		GitCommit:  GitCommit(filepath.Dir(report.ScriptPath)),
+		Start:      report.Start,
+		End:        report.End,
+		Config:     config,
+		Stages:     report.Stages,
+		Outcome:    ClassifyOutcome(report.Err),
+		Summary:    report.Summary,
+	}
+	if content, err := os.ReadFile(report.ScriptPath); err == nil {
+		sum := sha256.Sum256(content)
+		record.ScriptHash = hex.EncodeToString(sum[:])
+	}
+	if report.Err != nil {
+		record.Error = report.Err.Error()
+	}
+	return record
+}
+
+// Append adds a record to the history. If the bundle is not empty, it is stored next to the history so the
+// run can be replayed.
+func (h *History) Append(record HistoryRecord, bundle []byte) error {
+	if err := os.MkdirAll(filepath.Join(h.dir, "bundles"), 0755); err != nil {
+		return err
+	}
+	if len(bundle) > 0 {
+		record.BundlePath = filepath.Join(h.dir, "bundles", record.RunId+".js")
+		if err := os.WriteFile(record.BundlePath, bundle, 0644); err != nil {
+			return err
+		}
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(h.path(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Records returns all records in the order they were added.
+func (h *History) Records() ([]HistoryRecord, error) {
+	f, err := os.Open(h.path())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var records []HistoryRecord
+	reader := bufio.NewReader(f)
+	for lineNo := 1; ; lineNo++ {
+		line, err := reader.ReadBytes('\n')
+		if len(strings.TrimSpace(string(line))) > 0 {
+			var record HistoryRecord
+			if jsonErr := json.Unmarshal(line, &record); jsonErr != nil {
+				return nil, fmt.Errorf("history line %d is invalid: %w", lineNo, jsonErr)
+			}
+			records = append(records, record)
+		}
+		if err != nil {
+			break
+		}
+	}
+	return records, nil
+}
+
+// Find returns the record with the given run ID.
+func (h *History) Find(runId string) (HistoryRecord, error) {
+	records, err := h.Records()
+	if err != nil {
+		return HistoryRecord{}, err
+	}
+	for _, record := range records {
+		if record.RunId == runId {
+			return record, nil
+		}
+	}
+	return HistoryRecord{}, fmt.Errorf("there is no run with the ID '%s' in the history", runId)
+}
+
+// ReadBundle returns the bundle that was uploaded for the run.
+func (r *HistoryRecord) ReadBundle() ([]byte, error) {
+	if r.BundlePath == "" {
+		return nil, fmt.Errorf("the bundle of run '%s' was not stored, runs in folder mode cannot be replayed", r.RunId)
+	}
+	return os.ReadFile(r.BundlePath)
+}
+
+// GitCommit returns the commit checked out in the given directory, or an empty string if it is not a git repository.
+func GitCommit(dir string) string {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,55 @@
+package internal_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/brodo/kubectl-k6/internal"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	err, history := internal.NewHistory()
+	require.NoError(t, err)
+	records, err := history.Records()
+	require.NoError(t, err)
+	require.Empty(t, records)
+
+	report := &internal.RunReport{
+		ScriptPath: "../examples/liveness/liveness.js",
+		RunId:      "l4q5ph7vsplt2pxkkv4l",
+		Start:      time.Now().Add(-time.Minute),
+		End:        time.Now(),
+		Err:        fmt.Errorf("%w: p95", internal.ErrRegression),
+	}
+	record := internal.NewHistoryRecord(report, map[string]interface{}{"parallelism": 2})
+	require.Equal(t, internal.OutcomeRegression, record.Outcome)
+	require.Len(t, record.ScriptHash, 64)
+	require.NoError(t, history.Append(record, []byte("export default function () {}")))
+	require.NoError(t, history.Append(internal.HistoryRecord{RunId: "folder-run", Outcome: internal.OutcomePassed}, nil))
+
+	found, err := history.Find("l4q5ph7vsplt2pxkkv4l")
+	require.NoError(t, err)
+	require.Equal(t, float64(2), found.Config["parallelism"])
+	bundle, err := found.ReadBundle()
+	require.NoError(t, err)
+	require.Equal(t, "export default function () {}", string(bundle))
+
+	found, err = history.Find("folder-run")
+	require.NoError(t, err)
+	_, err = found.ReadBundle()
+	require.Error(t, err)
+
+	_, err = history.Find("unknown")
+	require.Error(t, err)
+}
+
+func TestClassifyOutcome(t *testing.T) {
+	require.Equal(t, internal.OutcomePassed, internal.ClassifyOutcome(nil))
+	require.Equal(t, internal.OutcomeThresholdsFailed, internal.ClassifyOutcome(fmt.Errorf("%w: 2", internal.ErrThresholdsCrossed)))
+	require.Equal(t, internal.OutcomeRegression, internal.ClassifyOutcome(errors.Join(fmt.Errorf("%w: 2", internal.ErrThresholdsCrossed), internal.ErrRegression)))
+	require.Equal(t, internal.OutcomeError, internal.ClassifyOutcome(errors.New("job failed")))
+}
@@ -0,0 +1,263 @@
+package internal
+
+import (
+	"context"
+	errors2 "errors"
+	"fmt"
+	batch "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// k6ContainerName is the name the operator gives the k6 container of the initializer, starter and runner pods.
+const k6ContainerName = "k6"
+
+// RunnerLabelSelector selects the runner jobs and pods the operator creates for the test runs.
+func RunnerLabelSelector(resNames ...string) string {
+	if len(resNames) == 1 {
+		return fmt.Sprintf("%s=%s,runner=true", TestRunLabel, resNames[0])
+	}
+	return fmt.Sprintf("%s in (%s),runner=true", TestRunLabel, strings.Join(resNames, ","))
+}
+
+type JobState int
+
+const (
+	JobRunning JobState = iota
+	JobSucceeded
+	JobFailed
+)
+
+// JobStateOf decides the state of a job from its conditions. A job is failed as soon as the controller decided it
+// fails (FailureTarget), even if its pods are still terminating. The message explains why a job failed.
+func JobStateOf(job *batch.Job) (state JobState, message string) {
+	for _, c := range job.Status.Conditions {
+		if c.Status != v1.ConditionTrue {
+			continue
+		}
+		switch c.Type {
+		case batch.JobComplete, batch.JobSuccessCriteriaMet:
+			return JobSucceeded, ""
+		case batch.JobFailed, batch.JobFailureTarget:
+			return JobFailed, strings.TrimSuffix(fmt.Sprintf("%s: %s", c.Reason, c.Message), ": ")
+		}
+	}
+	return JobRunning, ""
+}
+
+// JobRetry is reported when a pod of a job failed and the job starts another attempt.
+type JobRetry struct {
+	Job            string
+	FailedAttempts int32
+	BackoffLimit   int32
+}
+
+func (r JobRetry) String() string {
+	return fmt.Sprintf("attempt %d of job '%s' failed, retrying (backoff limit %d)", r.FailedAttempts, r.Job, r.BackoffLimit)
+}
+
+// getJobPods returns the pods of a job, oldest first, so every retry of the job comes after the attempt before.
+func (kc *K8sClient) getJobPods(ctx context.Context, jobName string) ([]v1.Pod, error) {
+	podList, err := kc.clientSet.CoreV1().Pods(kc.namespace).List(ctx, meta.ListOptions{
+		LabelSelector: fmt.Sprintf("job-name=%s", jobName),
+	})
+	if err != nil {
+		return nil, err
+	}
+	pods := podList.Items
+	slices.SortStableFunc(pods, func(a, b v1.Pod) int {
+		if c := a.CreationTimestamp.Compare(b.CreationTimestamp.Time); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return pods, nil
+}
+
+// logContainer returns the container whose logs are shown for a pod: the k6 container if the pod has several, e.g.
+// if a service mesh injected a sidecar.
+func logContainer(pod v1.Pod) string {
+	for _, c := range pod.Spec.Containers {
+		if c.Name == k6ContainerName {
+			return c.Name
+		}
+	}
+	return ""
+}
+
+// GetRunnerJobNames returns the names of the runner jobs of the test run, sorted by their number.
+func (kc *K8sClient) GetRunnerJobNames(ctx context.Context, resName string) ([]string, error) {
+	jobs, err := kc.clientSet.BatchV1().Jobs(kc.namespace).List(ctx, meta.ListOptions{LabelSelector: RunnerLabelSelector(resName)})
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(jobs.Items))
+	for _, job := range jobs.Items {
+		names = append(names, job.Name)
+	}
+	SortJobNames(names)
+	return names, nil
+}
+
+// SortJobNames sorts job names with numeric suffixes naturally, i.e. 'run-2' comes before 'run-10'.
+func SortJobNames(names []string) {
+	slices.SortFunc(names, func(a, b string) int {
+		if len(a) != len(b) {
+			return len(a) - len(b)
+		}
+		return strings.Compare(a, b)
+	})
+}
+
+// GetJobAttemptLogs returns the logs of every attempt of a job since the given time, oldest first.
+func (kc *K8sClient) GetJobAttemptLogs(ctx context.Context, jobName string, since time.Time) (string, error) {
+	pods, err := kc.getJobPods(ctx, jobName)
+	if err != nil {
+		return "", err
+	}
+	if len(pods) == 0 {
+		return "", fmt.Errorf("job '%s' has no pods", jobName)
+	}
+	var sb strings.Builder
+	for i, pod := range pods {
+		logs, err := kc.clientSet.CoreV1().Pods(kc.namespace).GetLogs(pod.Name, &v1.PodLogOptions{
+			Container: logContainer(pod), SinceTime: &meta.Time{Time: since},
+		}).Do(ctx).Raw()
+		if len(pods) > 1 {
+			sb.WriteString(fmt.Sprintf("--- attempt %d, pod '%s' ---\n", i+1, pod.Name))
+		}
+		if err != nil {
+			sb.WriteString(fmt.Sprintf("could not get the logs: %v\n", err))
+			continue
+		}
+		sb.Write(logs)
+	}
+	return sb.String(), nil
+}
+
+// jobExitCode returns the exit code of the k6 container of the job's last attempt.
+func (kc *K8sClient) jobExitCode(ctx context.Context, jobName string) (int32, bool) {
+	last, ok := kc.lastJobPod(ctx, jobName)
+	if !ok {
+		return 0, false
+	}
+	for _, status := range last.Status.ContainerStatuses {
+		if status.Name != k6ContainerName && len(last.Status.ContainerStatuses) > 1 {
+			continue
+		}
+		if status.State.Terminated != nil {
+			return status.State.Terminated.ExitCode, true
+		}
+	}
+	return 0, false
+}
+
+// waitForJob waits until the job succeeded or failed and warns about every retry. The error of a failed job contains
+// the logs of all attempts. If the k6 container exited while sidecars keep the pod running, the job is done and its
+// result is decided by the exit code of k6; the sidecars are shut down if configured.
+func (kc *K8sClient) waitForJob(ctx context.Context, jobName string, interval time.Duration, since time.Time, warn func(string)) error {
+	var reportedFailures int32
+	return wait.PollUntilContextCancel(ctx, interval, true, func(ctx context.Context) (done bool, err error) {
+		job, err := kc.clientSet.BatchV1().Jobs(kc.namespace).Get(ctx, jobName, meta.GetOptions{})
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		state, message := JobStateOf(job)
+		switch state {
+		case JobSucceeded:
+			return true, nil
+		case JobFailed:
+			code, _ := kc.jobExitCode(ctx, jobName)
+			return true, kc.jobFailure(ctx, jobName, code, message, since)
+		}
+		if job.Status.Failed > reportedFailures {
+			reportedFailures = job.Status.Failed
+			backoffLimit := int32(6) // the default of Kubernetes
+			if job.Spec.BackoffLimit != nil {
+				backoffLimit = *job.Spec.BackoffLimit
+			}
+			warn(JobRetry{Job: jobName, FailedAttempts: job.Status.Failed, BackoffLimit: backoffLimit}.String())
+		}
+		pod, ok := kc.lastJobPod(ctx, jobName)
+		if !ok {
+			return false, nil
+		}
+		code, sidecars, exited := K6Exited(pod)
+		if !exited {
+			return false, nil
+		}
+		if kc.sidecars.Quit {
+			if err := kc.QuitSidecars(ctx, pod.Name, sidecars); err != nil {
+				warn(err.Error())
+			}
+		} else {
+			warn(fmt.Sprintf("k6 exited in pod '%s', but the sidecars %s keep running, shut them down with '--quit-sidecars'", pod.Name, strings.Join(sidecars, ", ")))
+		}
+		if code == 0 {
+			return true, nil
+		}
+		return true, kc.jobFailure(ctx, jobName, code, fmt.Sprintf("the k6 container exited with code %d", code), since)
+	})
+}
+
+// jobFailure returns the error of a failed job with the logs of all its attempts, or a ThresholdsFailedError if k6
+// exited because thresholds were crossed.
+func (kc *K8sClient) jobFailure(ctx context.Context, jobName string, exitCode int32, message string, since time.Time) error {
+	// k6 exits with a dedicated code if only thresholds were crossed
+	if exitCode == thresholdsFailedExitCode {
+		return &ThresholdsFailedError{JobName: jobName}
+	}
+	logs, err := kc.GetJobAttemptLogs(ctx, jobName, since)
+	if err != nil {
+		return fmt.Errorf("job '%s' failed (%s), could not retrieve logs:\n %w", jobName, message, err)
+	}
+	return fmt.Errorf("job '%s' failed (%s), logs:\n%s", jobName, message, logs)
+}
+
+// WaitForInitJobCompletion waits until the initializer job succeeded or failed. warn is called for every failed
+// attempt the job retries.
+func (kc *K8sClient) WaitForInitJobCompletion(ctx context.Context, sps *ScriptProperties, startTime time.Time, warn func(string)) error {
+	return kc.waitForJob(ctx, sps.InitJobName(), 2*time.Second, startTime, warn)
+}
+
+type errSync struct {
+	errs []error
+	mu   sync.Mutex
+}
+
+// WaitForRunJobCompletion waits until all runner jobs of the test run succeeded or failed. The runner jobs are found
+// by the operator's labels. warn is called for every failed attempt a job retries and for runners whose sidecars
+// keep running.
+func (kc *K8sClient) WaitForRunJobCompletion(ctx context.Context, sps *ScriptProperties, k6Conf *K6Config, startTime time.Time, warn func(string)) error {
+	var jobNames []string
+	err := wait.PollUntilContextCancel(ctx, 2*time.Second, true, func(ctx context.Context) (done bool, err error) {
+		jobNames, err = kc.GetRunnerJobNames(ctx, sps.ResourceName())
+		return err == nil && len(jobNames) >= k6Conf.Parallelism, err
+	})
+	if err != nil {
+		return fmt.Errorf("could not find the %d runner job(s) with the labels '%s': %w", k6Conf.Parallelism, RunnerLabelSelector(sps.ResourceName()), err)
+	}
+	var wg sync.WaitGroup
+	es := &errSync{}
+	for _, jobName := range jobNames {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := kc.waitForJob(ctx, jobName, 10*time.Second, startTime, warn)
+			es.mu.Lock()
+			es.errs = append(es.errs, err)
+			es.mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return errors2.Join(es.errs...)
+}
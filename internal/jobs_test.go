@@ -0,0 +1,46 @@
+package internal_test
+
+import (
+	"github.com/brodo/kubectl-k6/internal"
+	"github.com/stretchr/testify/require"
+	batch "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"testing"
+)
+
+func TestJobStateOf(t *testing.T) {
+	job := &batch.Job{Status: batch.JobStatus{Failed: 1, Active: 1}}
+	state, _ := internal.JobStateOf(job)
+	// a failed attempt does not fail a job that is retried
+	require.Equal(t, internal.JobRunning, state)
+
+	job.Status.Conditions = []batch.JobCondition{
+		{Type: batch.JobFailureTarget, Status: v1.ConditionTrue, Reason: "BackoffLimitExceeded", Message: "Job has reached the specified backoff limit"},
+	}
+	state, message := internal.JobStateOf(job)
+	require.Equal(t, internal.JobFailed, state)
+	require.Equal(t, "BackoffLimitExceeded: Job has reached the specified backoff limit", message)
+
+	job.Status.Conditions = []batch.JobCondition{
+		{Type: batch.JobSuccessCriteriaMet, Status: v1.ConditionFalse},
+		{Type: batch.JobComplete, Status: v1.ConditionTrue},
+	}
+	state, _ = internal.JobStateOf(job)
+	require.Equal(t, internal.JobSucceeded, state)
+}
+
+func TestSortJobNames(t *testing.T) {
+	names := []string{"run-abc-10", "run-abc-2", "run-abc-1"}
+	internal.SortJobNames(names)
+	require.Equal(t, []string{"run-abc-1", "run-abc-2", "run-abc-10"}, names)
+}
+
+func TestRunnerLabelSelector(t *testing.T) {
+	require.Equal(t, "k6_cr=run-abc,runner=true", internal.RunnerLabelSelector("run-abc"))
+	require.Equal(t, "k6_cr in (run-abc-a,run-abc-b),runner=true", internal.RunnerLabelSelector("run-abc-a", "run-abc-b"))
+}
+
+func TestJobRetry_String(t *testing.T) {
+	retry := internal.JobRetry{Job: "run-abc-1", FailedAttempts: 1, BackoffLimit: 3}
+	require.Equal(t, "attempt 1 of job 'run-abc-1' failed, retrying (backoff limit 3)", retry.String())
+}
@@ -2,6 +2,9 @@ package internal
 
 import (
 	"fmt"
+	v1 "k8s.io/api/core/v1"
+	"regexp"
+	"slices"
 	"strings"
 )
 
@@ -15,6 +18,13 @@ type K6Config struct {
 	ImagePullSecret string
 	Folder          string
 	FilePath        string
+	// EnvSecret is the name of a secret whose keys are added to the runners' environment.
+	EnvSecret   string
+	Runner      PodOptions
+	Initializer PodOptions
+	Starter     PodOptions
+	// Paused creates the test run without starting the runners, so they can be started together with others.
+	Paused bool
 }
 
 func NewK6Config(env K6Environment, args string, image string, parallelism int, imgPullSecret string, folder, filePath string) K6Config {
@@ -30,18 +40,46 @@ func NewK6Config(env K6Environment, args string, image string, parallelism int,
 
 }
 
-func (k6Env *K6Environment) ToMapSlice() []map[string]interface{} {
-	envNv := make([]map[string]interface{}, len(*k6Env))
-	i := 0
+// ArgsEnv returns the variables that are set with `-e` or `--env` in the k6 arguments.
+func ArgsEnv(k6args string) K6Environment {
+	env := K6Environment{}
+	args := strings.Fields(k6args)
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(args[i], "=")
+		if name != "--env" && name != "-e" {
+			continue
+		}
+		if !hasValue {
+			if i+1 == len(args) {
+				break
+			}
+			i++
+			value = args[i]
+		}
+		if key, v, ok := strings.Cut(strings.Trim(value, `'"`), "="); ok {
+			env[key] = strings.Trim(v, `'"`)
+		}
+	}
+	return env
+}
+
+// ReplaceArgsEnv replaces the value of a variable that is set in the k6 arguments.
+func ReplaceArgsEnv(k6args, key, oldValue, newValue string) string {
+	re := regexp.MustCompile(`(^|[\s'"=])` + regexp.QuoteMeta(key) + `=(['"]?)` + regexp.QuoteMeta(oldValue) + `(['"]?)(\s|$)`)
+	return re.ReplaceAllStringFunc(k6args, func(match string) string {
+		groups := re.FindStringSubmatch(match)
+		return groups[1] + key + "=" + groups[2] + newValue + groups[3] + groups[4]
+	})
+}
+
+// ToEnvVars converts the environment into the variables of a container, sorted by name.
+func (k6Env *K6Environment) ToEnvVars() []v1.EnvVar {
+	envVars := make([]v1.EnvVar, 0, len(*k6Env))
 	for k, v := range *k6Env {
-		envMap := make(map[string]interface{})
-		upperName := strings.ToUpper(k)
-		envMap["name"] = upperName
-		envMap["value"] = v
-		envNv[i] = envMap
-		i++
+		envVars = append(envVars, v1.EnvVar{Name: strings.ToUpper(k), Value: v})
 	}
-	return envNv
+	slices.SortFunc(envVars, func(a, b v1.EnvVar) int { return strings.Compare(a.Name, b.Name) })
+	return envVars
 }
 
 func (k6Env *K6Environment) String() string {
@@ -11,21 +11,23 @@ import (
 	"k8s.io/apimachinery/pkg/api/resource"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"strings"
-	"sync"
 	"time"
 )
 
 type K8sClient struct {
-	clientSet     *kubernetes.Clientset
+	clientSet     kubernetes.Interface
 	dynamicClient *dynamic.DynamicClient
+	restConfig    *rest.Config
 	namespace     string
-	k6GVR         schema.GroupVersionResource
+	k6API         K6API
+	sidecars      SidecarOptions
+	// events are shared by the copies of the client for the same namespace
+	events *eventCache
 }
 
 type LogsWithNames struct {
@@ -55,7 +57,31 @@ var stages = map[string]Stage{
 	"error":          ErrorStage,
 }
 
+// NewK8sClient connects to the cluster and negotiates the k6 operator API. It fails if the operator is not installed.
 func NewK8sClient(k8sConfig *rest.Config, namespace string) (error, K8sClient) {
+	err, kc := ConnectK8s(k8sConfig, namespace)
+	if err != nil {
+		return err, K8sClient{}
+	}
+	if err := kc.NegotiateK6API(); err != nil {
+		return err, K8sClient{}
+	}
+	return nil, kc
+}
+
+// NegotiateK6API discovers the k6 operator API the cluster serves and uses it for the custom resources.
+func (kc *K8sClient) NegotiateK6API() error {
+	k6API, err := DiscoverK6API(kc.clientSet.Discovery())
+	if err != nil {
+		return err
+	}
+	kc.k6API = k6API
+	return nil
+}
+
+// ConnectK8s connects to the cluster without negotiating the k6 operator API. Use it for commands that only
+// work with core resources, like the results stored in config maps.
+func ConnectK8s(k8sConfig *rest.Config, namespace string) (error, K8sClient) {
 	clientSet, err := kubernetes.NewForConfig(k8sConfig)
 	if err != nil {
 		return err, K8sClient{}
@@ -64,11 +90,30 @@ func NewK8sClient(k8sConfig *rest.Config, namespace string) (error, K8sClient) {
 	if err != nil {
 		return err, K8sClient{}
 	}
-	return nil, K8sClient{clientSet: clientSet, dynamicClient: dynamicClient, namespace: namespace, k6GVR: schema.GroupVersionResource{
-		Group:    "k6.io",
-		Version:  "v1alpha1",
-		Resource: "testruns",
-	}}
+	return nil, K8sClient{clientSet: clientSet, dynamicClient: dynamicClient, restConfig: k8sConfig, namespace: namespace, k6API: DefaultK6API, events: &eventCache{}}
+}
+
+// UseK6API uses the given API version and kind of the k6 operator, e.g. the ones of a pre-authored manifest.
+func (kc *K8sClient) UseK6API(apiVersion, kind string) error {
+	k6API, err := FindK6API(kc.clientSet.Discovery(), apiVersion, kind)
+	if err != nil {
+		return err
+	}
+	kc.k6API = k6API
+	return nil
+}
+
+// K6API returns the negotiated k6 operator API.
+func (kc *K8sClient) K6API() K6API {
+	return kc.k6API
+}
+
+// WithNamespace returns a client that uses the same connection but another namespace.
+func (kc *K8sClient) WithNamespace(namespace string) K8sClient {
+	c := *kc
+	c.namespace = namespace
+	c.events = &eventCache{}
+	return c
 }
 
 func (kc *K8sClient) DeleteResources(ctx context.Context, sps *ScriptProperties) error {
@@ -84,8 +129,69 @@ func (kc *K8sClient) DeleteResources(ctx context.Context, sps *ScriptProperties)
 	return eg.Wait()
 }
 
-func (kc *K8sClient) CreateConfigMap(ctx context.Context, sps *ScriptProperties, scriptContent string) error {
-	configMap := &v1.ConfigMap{
+// NewEnvSecret creates the secret with the given environment variables for the runners.
+func (kc *K8sClient) NewEnvSecret(sps *ScriptProperties, env map[string]string) *v1.Secret {
+	return &v1.Secret{
+		TypeMeta: meta.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: meta.ObjectMeta{
+			Name:      sps.EnvSecretName(),
+			Namespace: kc.namespace,
+		},
+		StringData: env,
+	}
+}
+
+// CreateEnvSecret creates a secret with the given environment variables for the runners.
+func (kc *K8sClient) CreateEnvSecret(ctx context.Context, sps *ScriptProperties, env map[string]string) error {
+	_, err := kc.clientSet.CoreV1().Secrets(kc.namespace).Create(ctx, kc.NewEnvSecret(sps, env), meta.CreateOptions{})
+	return err
+}
+
+// SetSecretOwner makes the custom resource the owner of the secret, so the secret is deleted together with it.
+func (kc *K8sClient) SetSecretOwner(ctx context.Context, secretName, resName string) error {
+	k6CR, err := kc.GetCustomResource(ctx, resName)
+	if err != nil {
+		return err
+	}
+	secret, err := kc.clientSet.CoreV1().Secrets(kc.namespace).Get(ctx, secretName, meta.GetOptions{})
+	if err != nil {
+		return err
+	}
+	secret.OwnerReferences = append(secret.OwnerReferences, meta.OwnerReference{
+		APIVersion: k6CR.GetAPIVersion(),
+		Kind:       k6CR.GetKind(),
+		Name:       k6CR.GetName(),
+		UID:        k6CR.GetUID(),
+	})
+	_, err = kc.clientSet.CoreV1().Secrets(kc.namespace).Update(ctx, secret, meta.UpdateOptions{})
+	return err
+}
+
+// GetSecretValues returns the decoded values of a secret.
+func (kc *K8sClient) GetSecretValues(ctx context.Context, secretName string) (map[string]string, error) {
+	secret, err := kc.clientSet.CoreV1().Secrets(kc.namespace).Get(ctx, secretName, meta.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(secret.Data))
+	for k, v := range secret.Data {
+		values[k] = string(v)
+	}
+	return values, nil
+}
+
+func (kc *K8sClient) DeleteSecret(ctx context.Context, secretName string) error {
+	err := kc.clientSet.CoreV1().Secrets(kc.namespace).Delete(ctx, secretName, meta.DeleteOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// NewScriptConfigMap creates the config map that contains the bundled script.
+func (kc *K8sClient) NewScriptConfigMap(sps *ScriptProperties, scriptContent string) *v1.ConfigMap {
+	return &v1.ConfigMap{
+		TypeMeta: meta.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
 		ObjectMeta: meta.ObjectMeta{
 			Name:      sps.ConfigMapName(),
 			Namespace: kc.namespace,
@@ -94,7 +200,19 @@ func (kc *K8sClient) CreateConfigMap(ctx context.Context, sps *ScriptProperties,
 			"out.js": scriptContent,
 		},
 	}
+}
+
+func (kc *K8sClient) CreateConfigMap(ctx context.Context, sps *ScriptProperties, scriptContent string) error {
+	_, err := kc.clientSet.CoreV1().ConfigMaps(kc.namespace).Create(ctx, kc.NewScriptConfigMap(sps, scriptContent), meta.CreateOptions{})
+	return err
+}
+
+// ApplyConfigMap creates the config map or replaces it if it already exists.
+func (kc *K8sClient) ApplyConfigMap(ctx context.Context, configMap *v1.ConfigMap) error {
 	_, err := kc.clientSet.CoreV1().ConfigMaps(kc.namespace).Create(ctx, configMap, meta.CreateOptions{})
+	if errors.IsAlreadyExists(err) {
+		_, err = kc.clientSet.CoreV1().ConfigMaps(kc.namespace).Update(ctx, configMap, meta.UpdateOptions{})
+	}
 	return err
 }
 
@@ -127,69 +245,57 @@ func (kc *K8sClient) DeleteConfigMap(ctx context.Context, configMapName string)
 	return nil
 }
 
-func (kc *K8sClient) CreateCustomResource(ctx context.Context, k6Conf *K6Config, tVars *TemplateVars) error {
-	var script map[string]interface{}
-
-	if k6Conf.Folder == "" {
-		script = map[string]interface{}{
-			"configMap": map[string]interface{}{
-				"name": tVars.ConfigMapName(),
-				"file": "out.js",
-			},
-		}
-	} else {
-		script = map[string]interface{}{
-			"volumeClaim": map[string]interface{}{
-				"name": tVars.ConfigMapName(),
-				"file": k6Conf.FilePath,
-			},
-		}
+// NewCustomResource creates the test run in the negotiated API version and kind.
+func (kc *K8sClient) NewCustomResource(k6Conf *K6Config, tVars *TemplateVars) (*unstructured.Unstructured, error) {
+	testRun, err := NewTestRun(k6Conf, tVars)
+	if err != nil {
+		return nil, err
 	}
+	kc.k6API.Apply(testRun)
+	testRun.Namespace = kc.namespace
+	return testRun.ToUnstructured()
+}
 
-	k6CR := &unstructured.Unstructured{
-		Object: map[string]interface{}{
-			"apiVersion": "k6.io/v1alpha1",
-			"kind":       "TestRun",
-			"metadata": map[string]interface{}{
-				"name": tVars.ResourceName(),
-			},
-			"spec": map[string]interface{}{
-				"parallelism": k6Conf.Parallelism,
-				"arguments":   k6Conf.Args,
-				"script":      script,
-				"runner":      map[string]interface{}{},
-			},
-		},
-	}
-	if len(k6Conf.Env) > 0 {
-		k6CR.Object["spec"].(map[string]interface{})["runner"].(map[string]interface{})["env"] = k6Conf.Env.ToMapSlice()
-	}
-	if k6Conf.Image != "" {
-		k6CR.Object["spec"].(map[string]interface{})["runner"].(map[string]interface{})["image"] = k6Conf.Image
-		k6CR.Object["spec"].(map[string]interface{})["runner"].(map[string]interface{})["imagePullSecrets"] = []map[string]string{
-			{"name": k6Conf.ImagePullSecret},
-		}
+func (kc *K8sClient) CreateCustomResource(ctx context.Context, k6Conf *K6Config, tVars *TemplateVars) error {
+	k6CR, err := kc.NewCustomResource(k6Conf, tVars)
+	if err != nil {
+		return err
 	}
+	_, err = kc.dynamicClient.Resource(kc.k6API.GVR).Namespace(kc.namespace).Create(ctx, k6CR, meta.CreateOptions{})
+	return err
+}
 
-	_, err := kc.dynamicClient.Resource(kc.k6GVR).Namespace(kc.namespace).Create(ctx, k6CR, meta.CreateOptions{})
+// CreateCustomResourceObject creates a custom resource from a pre-authored manifest.
+func (kc *K8sClient) CreateCustomResourceObject(ctx context.Context, k6CR *unstructured.Unstructured) error {
+	_, err := kc.dynamicClient.Resource(kc.k6API.GVR).Namespace(kc.namespace).Create(ctx, k6CR, meta.CreateOptions{})
 	return err
 }
 
 func (kc *K8sClient) GetCustomResource(ctx context.Context, resName string) (*unstructured.Unstructured, error) {
-	return kc.dynamicClient.Resource(kc.k6GVR).Namespace(kc.namespace).Get(ctx, resName, meta.GetOptions{})
+	return kc.dynamicClient.Resource(kc.k6API.GVR).Namespace(kc.namespace).Get(ctx, resName, meta.GetOptions{})
 }
 
-func (kc *K8sClient) GetCurrK6Stage(ctx context.Context, resName string) (string, error) {
+// GetTestRun returns the typed custom resource.
+func (kc *K8sClient) GetTestRun(ctx context.Context, resName string) (*TestRun, error) {
 	k6CR, err := kc.GetCustomResource(ctx, resName)
+	if err != nil {
+		return nil, err
+	}
+	return TestRunFromUnstructured(k6CR)
+}
+
+// GetCurrK6Stage returns the stage of the test run, or an empty string if the operator has not set one yet.
+func (kc *K8sClient) GetCurrK6Stage(ctx context.Context, resName string) (string, error) {
+	testRun, err := kc.GetTestRun(ctx, resName)
 	if err != nil {
 		return "", err
 	}
-	return k6CR.Object["status"].(map[string]interface{})["stage"].(string), nil
+	return testRun.Status.Stage, nil
 }
 
 func (kc *K8sClient) DeleteCustomResource(ctx context.Context, resName string) error {
 	deletePolicy := meta.DeletePropagationForeground
-	if err := kc.dynamicClient.Resource(kc.k6GVR).Namespace(kc.namespace).Delete(ctx, resName, meta.DeleteOptions{
+	if err := kc.dynamicClient.Resource(kc.k6API.GVR).Namespace(kc.namespace).Delete(ctx, resName, meta.DeleteOptions{
 		PropagationPolicy: &deletePolicy,
 	}); err != nil {
 		if !errors.IsNotFound(err) { // Ignore if CR not found
@@ -198,7 +304,7 @@ func (kc *K8sClient) DeleteCustomResource(ctx context.Context, resName string) e
 	} else {
 		// Wait for the deletion to complete
 		err = wait.PollUntilContextTimeout(ctx, time.Second, 5*time.Second, true, func(ctx context.Context) (done bool, err error) {
-			_, getErr := kc.dynamicClient.Resource(kc.k6GVR).Namespace(kc.namespace).Get(context.TODO(), resName, meta.GetOptions{})
+			_, getErr := kc.dynamicClient.Resource(kc.k6API.GVR).Namespace(kc.namespace).Get(context.TODO(), resName, meta.GetOptions{})
 			if errors.IsNotFound(getErr) {
 				return true, nil // CR is deleted
 			}
@@ -212,163 +318,97 @@ func (kc *K8sClient) DeleteCustomResource(ctx context.Context, resName string) e
 	return nil
 }
 
+// WaitForStage waits until the operator reports the stage or a later one. The context limits how long it waits.
 func (kc *K8sClient) WaitForStage(cxt context.Context, resName string, expectedStage Stage) error {
-	return wait.PollUntilContextTimeout(cxt, 2*time.Second, time.Minute*3, false, func(ctx context.Context) (done bool, err error) {
-		stage, err := kc.GetCurrK6Stage(ctx, resName)
+	return wait.PollUntilContextCancel(cxt, 2*time.Second, false, func(ctx context.Context) (done bool, err error) {
+		testRun, err := kc.GetTestRun(ctx, resName)
 		if err != nil {
 			return false, err
 		}
-		if stage == "error" {
+		if failed, reason := testRun.Status.Failed(); failed {
+			if reason != "" {
+				return true, fmt.Errorf("k6 run failed: %s", reason)
+			}
 			return true, fmt.Errorf("k6 run failed")
 		}
-		if stages[stage] >= expectedStage {
-			return true, nil
-		}
-		return false, nil
+		stage, ok := testRun.Status.CurrentStage()
+		return ok && stage >= expectedStage, nil
 	})
-
 }
 
-func (kc *K8sClient) WaitForInitJobCompletion(cxt context.Context, sps *ScriptProperties, startTime time.Time) error {
-	return wait.PollUntilContextTimeout(cxt, 2*time.Second, time.Minute*3, true, func(ctx context.Context) (done bool, err error) {
-		job, getErr := kc.clientSet.BatchV1().Jobs(kc.namespace).Get(ctx, sps.InitJobName(), meta.GetOptions{})
-		if errors.IsNotFound(getErr) {
-			return false, nil
-		}
+// thresholdsFailedExitCode is the exit code k6 uses when the test ran but some thresholds were crossed.
+const thresholdsFailedExitCode = 99
 
-		if job.Status.Failed > 0 {
-			// Get logs for the failed job
-			err = fmt.Errorf("job '%s' failed", sps.InitJobName())
-			logs, glErr := kc.GetPodLogs(ctx, sps.InitJobName(), startTime)
-			if glErr != nil {
-				err = fmt.Errorf("error getting logs for job '%s': %w", sps.InitJobName(), glErr)
-			} else {
-				err = fmt.Errorf("previous error: %w, logs: %s", err, logs)
-			}
-			return true, err
-		}
-		if job.Status.Failed+job.Status.Succeeded == 1 {
-			return true, nil
-		}
-		return false, getErr // Continue polling
-	})
+// ThresholdsFailedError is returned for runner jobs that failed only because thresholds were crossed.
+type ThresholdsFailedError struct {
+	JobName string
 }
 
-func (kc *K8sClient) getJobPods(ctx context.Context, jobName string) ([]v1.Pod, error) {
-	podList, err := kc.clientSet.CoreV1().Pods(kc.namespace).List(ctx, meta.ListOptions{
-		LabelSelector: fmt.Sprintf("job-name=%s", jobName),
-	})
-	if err != nil {
-		return nil, err
-	}
-	return podList.Items, nil
+func (e *ThresholdsFailedError) Error() string {
+	return fmt.Sprintf("job '%s' failed because some thresholds were crossed", e.JobName)
 }
 
-func (kc *K8sClient) GetJobPodLogs(ctx context.Context, jobName string) ([]LogsWithNames, error) {
-	podList, err := kc.getJobPods(ctx, jobName)
-	if err != nil {
-		return nil, err
+// OnlyThresholdsFailed reports whether err consists solely of ThresholdsFailedErrors.
+func OnlyThresholdsFailed(err error) bool {
+	if err == nil {
+		return false
 	}
-	logs := make([]LogsWithNames, len(podList))
-	for i, pod := range podList {
-		podLogs, err := kc.clientSet.CoreV1().Pods(kc.namespace).GetLogs(pod.Name, &v1.PodLogOptions{}).Do(ctx).Raw()
-		if err != nil {
-			return nil, err
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			if !OnlyThresholdsFailed(e) {
+				return false
+			}
 		}
-		logs[i] = LogsWithNames{PodName: pod.Name, Logs: string(podLogs)}
-	}
-	return logs, nil
-}
-
-type errSync struct {
-	errs []error
-	mu   sync.Mutex
-}
-
-func (kc *K8sClient) WaitForRunJobCompletion(ctx context.Context, sps *ScriptProperties, k6Conf *K6Config, startTime time.Time) error {
-	var wg sync.WaitGroup
-	es := &errSync{}
-	for i := 0; i < k6Conf.Parallelism; i++ {
-		wg.Add(1)
-		runnerJobName := sps.RunnerJobName(i)
-		go func() {
-			defer wg.Done()
-			err := func() error {
-				return wait.PollUntilContextTimeout(ctx, 10*time.Second, time.Hour, false, func(ctx context.Context) (done bool, err error) {
-					job, getErr := kc.clientSet.BatchV1().Jobs(kc.namespace).Get(ctx, runnerJobName, meta.GetOptions{})
-					if errors.IsNotFound(getErr) {
-						return false, nil
-					}
-
-					var failErr error = nil
-					if job.Status.Failed > 0 {
-						// Get logs for the failed job
-						logs, err := kc.GetPodLogs(ctx, runnerJobName, startTime)
-						if err != nil {
-							failErr = fmt.Errorf("job '%s' failed, could not retreive logs:\n %w", runnerJobName, err)
-						} else {
-							failErr = fmt.Errorf("job '%s' failed, logs:\n%s", runnerJobName, logs)
-						}
-
-					}
-
-					if job.Status.Failed+job.Status.Succeeded == 1 {
-						return true, failErr
-					}
-					return false, getErr // Continue polling
-				})
-			}()
-			es.mu.Lock()
-			es.errs = append(es.errs, err)
-			es.mu.Unlock()
-		}()
-	}
-	wg.Wait()
-	return errors2.Join(es.errs...)
-}
-
-// GetPodLogs retrieves the logs of a specific job in the given namespace.
+		return true
+	}
+	var thresholdErr *ThresholdsFailedError
+	return errors2.As(err, &thresholdErr)
+}
+
+// GetPodLogs retrieves the logs of the last attempt of a job in the given namespace.
 // It takes a context, a Kubernetes clientset, and the name of the job.
 // It returns the logs as a string and an error if any occurred.
 func (kc *K8sClient) GetPodLogs(ctx context.Context, jobName string, since time.Time) (string, error) {
-	podList, err := kc.clientSet.CoreV1().Pods(kc.namespace).List(ctx, meta.ListOptions{
-		LabelSelector: fmt.Sprintf("job-name=%s", jobName),
-	})
+	pods, err := kc.getJobPods(ctx, jobName)
 	if err != nil {
 		return "", err
 	}
-	for _, pod := range podList.Items {
-		podLogs, err := kc.clientSet.CoreV1().Pods(kc.namespace).GetLogs(pod.Name, &v1.PodLogOptions{SinceTime: &meta.Time{Time: since}}).Do(ctx).Raw()
-		if err != nil {
-			return "", err
-		}
-		return string(podLogs), nil
+	if len(pods) == 0 {
+		return "", fmt.Errorf("job '%s' does not exist", jobName)
+	}
+	pod := pods[len(pods)-1]
+	podLogs, err := kc.clientSet.CoreV1().Pods(kc.namespace).GetLogs(pod.Name, &v1.PodLogOptions{
+		Container: logContainer(pod), SinceTime: &meta.Time{Time: since},
+	}).Do(ctx).Raw()
+	if err != nil {
+		return "", err
 	}
-	return "", fmt.Errorf("job '%s' does not exist", jobName)
+	return string(podLogs), nil
 }
 
+// GetPodLogStream follows the logs of the last attempt of a job.
 func (kc *K8sClient) GetPodLogStream(ctx context.Context, jobName string, since time.Time) (io.ReadCloser, error) {
-	podList, err := kc.clientSet.CoreV1().Pods(kc.namespace).List(ctx, meta.ListOptions{
-		LabelSelector: fmt.Sprintf("job-name=%s", jobName),
-	})
+	pods, err := kc.getJobPods(ctx, jobName)
 	if err != nil {
 		return nil, err
 	}
+	if len(pods) == 0 {
+		return nil, fmt.Errorf("job '%s' does not exist", jobName)
+	}
+	pod := pods[len(pods)-1]
 	count := int64(100)
 	podLogOptions := v1.PodLogOptions{
+		Container: logContainer(pod),
 		SinceTime: &meta.Time{Time: since},
 		Follow:    true,
 		TailLines: &count,
 	}
-	for _, pod := range podList.Items {
-		return kc.clientSet.CoreV1().Pods(kc.namespace).GetLogs(pod.Name, &podLogOptions).Stream(ctx)
-	}
-	return nil, fmt.Errorf("job '%s' does not exist", jobName)
+	return kc.clientSet.CoreV1().Pods(kc.namespace).GetLogs(pod.Name, &podLogOptions).Stream(ctx)
 }
 
 func (kc *K8sClient) GetOperatorLogsSince(ctx context.Context, since time.Time) (string, error) {
 	podList, err := kc.clientSet.CoreV1().Pods(kc.namespace).List(ctx, meta.ListOptions{
-		LabelSelector: "app.kubernetes.io/name=k6-operator",
+		LabelSelector: OperatorLabelSelector,
 	})
 	if err != nil {
 		return "", err
@@ -382,17 +422,26 @@ func (kc *K8sClient) GetOperatorLogsSince(ctx context.Context, since time.Time)
 		if err != nil {
 			return "", err
 		}
-		for _, line := range strings.Split(string(podLogs), "\n") {
-			if strings.Contains(line, "ERROR") || strings.Contains(line, "WARN") {
-				logs.WriteString(line + "\n")
-			}
-		}
+		logs.WriteString(operatorErrorLines(string(podLogs)))
 	}
 	return logs.String(), nil
 }
 
-func (kc *K8sClient) UploadFolderToPV(ctx context.Context, folder, pvName, namespace string) error {
-	pv := &v1.PersistentVolume{
+// operatorErrorLines returns the errors and warnings of the operator's logs.
+func operatorErrorLines(logs string) string {
+	var sb strings.Builder
+	for _, line := range strings.Split(logs, "\n") {
+		if strings.Contains(line, "ERROR") || strings.Contains(line, "WARN") {
+			sb.WriteString(line + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// NewFolderPV creates the persistent volume for a folder on the host.
+func NewFolderPV(folder, pvName, namespace string) *v1.PersistentVolume {
+	return &v1.PersistentVolume{
+		TypeMeta: meta.TypeMeta{APIVersion: "v1", Kind: "PersistentVolume"},
 		ObjectMeta: meta.ObjectMeta{
 			Name:      pvName,
 			Namespace: namespace,
@@ -411,7 +460,10 @@ func (kc *K8sClient) UploadFolderToPV(ctx context.Context, folder, pvName, names
 			},
 		},
 	}
-	_, err := kc.clientSet.CoreV1().PersistentVolumes().Create(ctx, pv, meta.CreateOptions{})
+}
+
+func (kc *K8sClient) UploadFolderToPV(ctx context.Context, folder, pvName, namespace string) error {
+	_, err := kc.clientSet.CoreV1().PersistentVolumes().Create(ctx, NewFolderPV(folder, pvName, namespace), meta.CreateOptions{})
 	return err
 }
 
@@ -423,8 +475,10 @@ func (kc *K8sClient) DeletePV(ctx context.Context, pvName string) error {
 	return err
 }
 
-func (kc *K8sClient) CreatePVC(ctx context.Context, pvName, pvcName, namespace string) error {
-	pvc := &v1.PersistentVolumeClaim{
+// NewFolderPVC creates the claim for the persistent volume of a folder.
+func NewFolderPVC(pvName, pvcName, namespace string) *v1.PersistentVolumeClaim {
+	return &v1.PersistentVolumeClaim{
+		TypeMeta: meta.TypeMeta{APIVersion: "v1", Kind: "PersistentVolumeClaim"},
 		ObjectMeta: meta.ObjectMeta{
 			Name:      pvcName,
 			Namespace: namespace,
@@ -437,6 +491,9 @@ func (kc *K8sClient) CreatePVC(ctx context.Context, pvName, pvcName, namespace s
 			}},
 		},
 	}
-	_, err := kc.clientSet.CoreV1().PersistentVolumeClaims(namespace).Create(ctx, pvc, meta.CreateOptions{})
+}
+
+func (kc *K8sClient) CreatePVC(ctx context.Context, pvName, pvcName, namespace string) error {
+	_, err := kc.clientSet.CoreV1().PersistentVolumeClaims(namespace).Create(ctx, NewFolderPVC(pvName, pvcName, namespace), meta.CreateOptions{})
 	return err
 }
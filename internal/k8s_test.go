@@ -4,9 +4,15 @@ import (
 	"context"
 	"github.com/brodo/kubectl-k6/internal"
 	"github.com/stretchr/testify/require"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/modules/k3s"
@@ -14,16 +20,17 @@ import (
 
 func TestK8sClient_CreateConfigMap(t *testing.T) {
 	ctx := context.Background()
-	k3sContainer, err := k3s.Run(ctx, "rancher/k3s:v1.27.1-k3s1")
-	testcontainers.CleanupContainer(t, k3sContainer)
-	require.NoError(t, err)
-	kubeConfigYaml, err := k3sContainer.GetKubeConfig(ctx)
-	require.NoError(t, err)
+	restcfg := startK3s(t)
+	installTestRunCRD(t, restcfg)
 
-	restcfg, err := clientcmd.RESTConfigFromKubeConfig(kubeConfigYaml)
-	require.NoError(t, err)
-	err, k8sClient := internal.NewK8sClient(restcfg, "default")
-	require.NoError(t, err)
+	// the operator's API is served shortly after the CRD was created
+	var k8sClient internal.K8sClient
+	require.Eventually(t, func() bool {
+		var err error
+		err, k8sClient = internal.NewK8sClient(restcfg, "default")
+		return err == nil
+	}, 30*time.Second, time.Second)
+	require.Equal(t, internal.DefaultK6API, k8sClient.K6API())
 	sps := internal.NewScriptProperties("../examples/liveness/liveness.js")
 	content, err := os.ReadFile("../examples/liveness/liveness.js")
 	require.NoError(t, err)
@@ -34,3 +41,60 @@ func TestK8sClient_CreateConfigMap(t *testing.T) {
 	require.Equal(t, sps.ConfigMapName(), m.Name)
 
 }
+
+func TestConnectK8s(t *testing.T) {
+	ctx := context.Background()
+	restcfg := startK3s(t)
+
+	// without the operator, only the commands that work with core resources can connect
+	err, _ := internal.NewK8sClient(restcfg, "default")
+	require.Error(t, err)
+	err, k8sClient := internal.ConnectK8s(restcfg, "default")
+	require.NoError(t, err)
+	require.Equal(t, internal.DefaultK6API, k8sClient.K6API())
+	require.NoError(t, k8sClient.SaveResult(ctx, internal.ResultRecord{RunId: "l4q5ph7vsplt2pxkkv4l", Outcome: "passed"}))
+	record, err := k8sClient.GetResult(ctx, "l4q5ph7vsplt2pxkkv4l")
+	require.NoError(t, err)
+	require.Equal(t, "passed", record.Outcome)
+}
+
+// startK3s starts a k3s cluster for the test and returns its config.
+func startK3s(t *testing.T) *rest.Config {
+	ctx := context.Background()
+	k3sContainer, err := k3s.Run(ctx, "rancher/k3s:v1.27.1-k3s1")
+	testcontainers.CleanupContainer(t, k3sContainer)
+	require.NoError(t, err)
+	kubeConfigYaml, err := k3sContainer.GetKubeConfig(ctx)
+	require.NoError(t, err)
+	restcfg, err := clientcmd.RESTConfigFromKubeConfig(kubeConfigYaml)
+	require.NoError(t, err)
+	return restcfg
+}
+
+// installTestRunCRD installs a minimal CRD for the test runs of the k6 operator.
+func installTestRunCRD(t *testing.T, restcfg *rest.Config) {
+	client, err := dynamic.NewForConfig(restcfg)
+	require.NoError(t, err)
+	crd := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apiextensions.k8s.io/v1",
+		"kind":       "CustomResourceDefinition",
+		"metadata":   map[string]interface{}{"name": "testruns.k6.io"},
+		"spec": map[string]interface{}{
+			"group": "k6.io",
+			"names": map[string]interface{}{"kind": "TestRun", "listKind": "TestRunList", "plural": "testruns", "singular": "testrun"},
+			"scope": "Namespaced",
+			"versions": []interface{}{map[string]interface{}{
+				"name":    "v1alpha1",
+				"served":  true,
+				"storage": true,
+				"schema": map[string]interface{}{"openAPIV3Schema": map[string]interface{}{
+					"type":                                 "object",
+					"x-kubernetes-preserve-unknown-fields": true,
+				}},
+			}},
+		},
+	}}
+	gvr := schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+	_, err = client.Resource(gvr).Create(context.Background(), crd, meta.CreateOptions{})
+	require.NoError(t, err)
+}
@@ -0,0 +1,88 @@
+package internal
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+// documentSeparator separates the documents of a YAML stream.
+var documentSeparator = regexp.MustCompile(`(?m)^---\s*$`)
+
+// TestRunManifest is a pre-authored test run, e.g. one that a team keeps in its repository.
+type TestRunManifest struct {
+	// Object is submitted to the cluster as is, so fields the typed TestRun does not know are kept.
+	Object  *unstructured.Unstructured
+	TestRun *TestRun
+	// Dir is the directory of the manifest file. Scripts are resolved relative to it.
+	Dir string
+}
+
+// LoadTestRunManifest reads a manifest file with a single test run and renders the template variables into it.
+func LoadTestRunManifest(path string, tVars *TemplateVars) (*TestRunManifest, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	err, rendered := tVars.ApplyManifestTemp(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("could not render the template variables into '%s': %w", path, err)
+	}
+	var docs []string
+	for _, doc := range documentSeparator.Split(rendered, -1) {
+		if strings.TrimSpace(doc) != "" {
+			docs = append(docs, doc)
+		}
+	}
+	if len(docs) != 1 {
+		return nil, fmt.Errorf("'%s' must contain exactly one test run, found %d documents", path, len(docs))
+	}
+	data, err := yaml.YAMLToJSON([]byte(docs[0]))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse '%s': %w", path, err)
+	}
+	object := &unstructured.Unstructured{}
+	if err := object.UnmarshalJSON(data); err != nil {
+		return nil, fmt.Errorf("could not parse '%s': %w", path, err)
+	}
+	if kind := object.GetKind(); kind != TestRunKind && kind != LegacyK6Kind {
+		return nil, fmt.Errorf("'%s' contains a %s, expected a %s or a %s", path, kind, TestRunKind, LegacyK6Kind)
+	}
+	testRun, err := TestRunFromUnstructured(object)
+	if err != nil {
+		return nil, err
+	}
+	if err := testRun.Validate(); err != nil {
+		return nil, err
+	}
+	return &TestRunManifest{Object: object, TestRun: testRun, Dir: filepath.Dir(path)}, nil
+}
+
+// ScriptPath returns the local path of the script the test run reads from a config map. Scripts in volume claims
+// cannot be bundled.
+func (m *TestRunManifest) ScriptPath() (string, error) {
+	source := m.TestRun.Spec.Script.ConfigMap
+	if source == nil {
+		return "", fmt.Errorf("test run '%s' reads its script from a volume claim, only scripts in config maps can be bundled", m.TestRun.Name)
+	}
+	if strings.Contains(source.File, "/") {
+		return "", fmt.Errorf("the script file '%s' of test run '%s' must be a key of the config map, not a path", source.File, m.TestRun.Name)
+	}
+	return filepath.Join(m.Dir, filepath.FromSlash(source.File)), nil
+}
+
+// ScriptConfigMap creates the config map the test run reads the bundled script from.
+func (m *TestRunManifest) ScriptConfigMap(namespace string, bundle []byte) *v1.ConfigMap {
+	source := m.TestRun.Spec.Script.ConfigMap
+	return &v1.ConfigMap{
+		TypeMeta:   meta.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: meta.ObjectMeta{Name: source.Name, Namespace: namespace},
+		Data:       map[string]string{source.File: string(bundle)},
+	}
+}
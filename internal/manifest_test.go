@@ -0,0 +1,54 @@
+package internal_test
+
+import (
+	"github.com/brodo/kubectl-k6/internal"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadTestRunManifest(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "testrun.yaml")
+	tVars := internal.NewTemplateVars(internal.NewScriptProperties(path))
+	require.Equal(t, "testrun", tVars.ScriptWOExt)
+
+	require.NoError(t, os.WriteFile(path, []byte(`---
+apiVersion: k6.io/v1alpha1
+kind: TestRun
+metadata:
+  name: checkout-{{ .RunId }}
+spec:
+  parallelism: 2
+  cleanup: post
+  script:
+    configMap:
+      name: checkout
+      file: checkout.js
+`), 0644))
+	manifest, err := internal.LoadTestRunManifest(path, &tVars)
+	require.NoError(t, err)
+	require.Equal(t, "checkout-"+tVars.RunId, manifest.TestRun.Name)
+	require.Equal(t, 2, manifest.TestRun.Spec.Parallelism)
+	// fields the typed test run does not know are kept
+	require.Equal(t, "post", manifest.Object.Object["spec"].(map[string]interface{})["cleanup"])
+	scriptPath, err := manifest.ScriptPath()
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "checkout.js"), scriptPath)
+	cm := manifest.ScriptConfigMap("load", []byte("export default function() {}"))
+	require.Equal(t, "checkout", cm.Name)
+	require.Equal(t, "export default function() {}", cm.Data["checkout.js"])
+
+	require.NoError(t, os.WriteFile(path, []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: b\n"), 0644))
+	_, err = internal.LoadTestRunManifest(path, &tVars)
+	require.ErrorContains(t, err, "must contain exactly one test run, found 2 documents")
+
+	require.NoError(t, os.WriteFile(path, []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n"), 0644))
+	_, err = internal.LoadTestRunManifest(path, &tVars)
+	require.ErrorContains(t, err, "contains a ConfigMap")
+
+	require.NoError(t, os.WriteFile(path, []byte("apiVersion: k6.io/v1alpha1\nkind: K6\nmetadata:\n  name: a\nspec:\n  parallelism: 0\n  script:\n    configMap:\n      name: a\n      file: a.js\n"), 0644))
+	_, err = internal.LoadTestRunManifest(path, &tVars)
+	require.ErrorContains(t, err, "the parallelism must be at least 1")
+}
@@ -0,0 +1,221 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// PodOptions are the settings of the pods the k6 operator starts. They are written to the `runner`,
+// `initializer` and `starter` fields of the TestRun spec.
+type PodOptions struct {
+	Resources                *v1.ResourceRequirements `json:"resources,omitempty"`
+	NodeSelector             map[string]string        `json:"nodeSelector,omitempty"`
+	Tolerations              []v1.Toleration          `json:"tolerations,omitempty"`
+	Affinity                 *v1.Affinity             `json:"affinity,omitempty"`
+	PriorityClassName        string                   `json:"priorityClassName,omitempty"`
+	ServiceAccountName       string                   `json:"serviceAccountName,omitempty"`
+	SecurityContext          *v1.PodSecurityContext   `json:"securityContext,omitempty"`
+	ContainerSecurityContext *v1.SecurityContext      `json:"containerSecurityContext,omitempty"`
+	// Labels and Annotations are set on the pods, e.g. `sidecar.istio.io/inject: "false"`.
+	Labels       map[string]string `json:"labels,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	Volumes      []v1.Volume       `json:"volumes,omitempty"`
+	VolumeMounts []v1.VolumeMount  `json:"volumeMounts,omitempty"`
+	// Env is merged with the k6 environment. Unlike the k6 environment, it supports `valueFrom`, e.g. to
+	// read values from secrets with `secretKeyRef`.
+	Env         []v1.EnvVar        `json:"env,omitempty"`
+	EnvFrom     []v1.EnvFromSource `json:"envFrom,omitempty"`
+	HostAliases []v1.HostAlias     `json:"hostAliases,omitempty"`
+	// SpreadAcrossNodes adds a topology spread constraint, so the runners of a test run are scheduled on
+	// different nodes if possible. It is only used for runners.
+	SpreadAcrossNodes bool `json:"spreadAcrossNodes,omitempty"`
+}
+
+// ParsePodOptions reads pod options from a configuration value, e.g. the `runner` section of the config file.
+// Unknown fields are rejected.
+func ParsePodOptions(value interface{}) (PodOptions, error) {
+	var po PodOptions
+	if value == nil {
+		return po, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return po, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&po); err != nil {
+		return po, explainPodOptionsErr(err)
+	}
+	for _, volume := range po.Volumes {
+		if volume.Name == "" {
+			return po, fmt.Errorf("all volumes need a name")
+		}
+	}
+	for _, mount := range po.VolumeMounts {
+		if mount.Name == "" || mount.MountPath == "" {
+			return po, fmt.Errorf("all volume mounts need a name and a mountPath")
+		}
+		if !slices.ContainsFunc(po.Volumes, func(v v1.Volume) bool { return v.Name == mount.Name }) {
+			return po, fmt.Errorf("the volume mount '%s' refers to a volume that is not defined in 'volumes'", mount.Name)
+		}
+	}
+	return po, nil
+}
+
+// explainPodOptionsErr turns the decoder's errors into messages that name the supported fields.
+func explainPodOptionsErr(err error) error {
+	field, unknown := strings.CutPrefix(err.Error(), "json: unknown field ")
+	if !unknown {
+		return err
+	}
+	field = strings.Trim(field, `"`)
+	var supported []string
+	podOptionsType := reflect.TypeOf(PodOptions{})
+	for i := 0; i < podOptionsType.NumField(); i++ {
+		name, _, _ := strings.Cut(podOptionsType.Field(i).Tag.Get("json"), ",")
+		supported = append(supported, name)
+	}
+	if slices.ContainsFunc(supported, func(s string) bool { return strings.EqualFold(s, field) }) {
+		return fmt.Errorf("the field '%s' is only supported at the top level of the section", field)
+	}
+	msg := fmt.Sprintf("unknown field '%s'", field)
+	if suggestion := closestMatch(field, supported); suggestion != "" {
+		msg += fmt.Sprintf(", did you mean '%s'?", suggestion)
+	}
+	return fmt.Errorf("%s (supported fields are: %s)", msg, strings.Join(supported, ", "))
+}
+
+// closestMatch returns the candidate with the smallest edit distance to s, if it is close enough to be a typo.
+func closestMatch(s string, candidates []string) string {
+	best, bestDistance := "", len(s)/2+1
+	for _, c := range candidates {
+		if d := editDistance(strings.ToLower(s), strings.ToLower(c)); d < bestDistance {
+			best, bestDistance = c, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr := make([]int, len(b)+1)
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev = curr
+	}
+	return prev[len(b)]
+}
+
+// SecretNames returns the names of all secrets the environment of the pods refers to.
+func (po *PodOptions) SecretNames() []string {
+	var names []string
+	for _, env := range po.Env {
+		if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil && !slices.Contains(names, env.ValueFrom.SecretKeyRef.Name) {
+			names = append(names, env.ValueFrom.SecretKeyRef.Name)
+		}
+	}
+	for _, envFrom := range po.EnvFrom {
+		if envFrom.SecretRef != nil && !slices.Contains(names, envFrom.SecretRef.Name) {
+			names = append(names, envFrom.SecretRef.Name)
+		}
+	}
+	return names
+}
+
+// SetResources parses resource quantities like `cpu=500m` and sets them as requests or limits.
+func (po *PodOptions) SetResources(requests, limits map[string]string) error {
+	if len(requests) == 0 && len(limits) == 0 {
+		return nil
+	}
+	if po.Resources == nil {
+		po.Resources = &v1.ResourceRequirements{}
+	}
+	for target, quantities := range map[*v1.ResourceList]map[string]string{&po.Resources.Requests: requests, &po.Resources.Limits: limits} {
+		for name, quantity := range quantities {
+			q, err := resource.ParseQuantity(quantity)
+			if err != nil {
+				return fmt.Errorf("invalid quantity '%s' for resource '%s': %w", quantity, name, err)
+			}
+			if *target == nil {
+				*target = v1.ResourceList{}
+			}
+			(*target)[v1.ResourceName(name)] = q
+		}
+	}
+	return nil
+}
+
+// ParseToleration parses a toleration in the syntax of `kubectl taint`: `key[=value]:Effect`.
+// Without a value the toleration uses the `Exists` operator.
+func ParseToleration(s string) (v1.Toleration, error) {
+	keyValue, effect, found := strings.Cut(s, ":")
+	if !found || keyValue == "" {
+		return v1.Toleration{}, fmt.Errorf("invalid toleration '%s', expected key[=value]:Effect", s)
+	}
+	switch v1.TaintEffect(effect) {
+	case v1.TaintEffectNoSchedule, v1.TaintEffectPreferNoSchedule, v1.TaintEffectNoExecute:
+	default:
+		return v1.Toleration{}, fmt.Errorf("invalid toleration '%s', the effect must be NoSchedule, PreferNoSchedule or NoExecute", s)
+	}
+	key, value, hasValue := strings.Cut(keyValue, "=")
+	t := v1.Toleration{Key: key, Effect: v1.TaintEffect(effect), Operator: v1.TolerationOpExists}
+	if hasValue {
+		t.Operator = v1.TolerationOpEqual
+		t.Value = value
+	}
+	return t, nil
+}
+
+// ToPod converts the options into the pod settings of the TestRun. The resource name is used to select the
+// runners of the test run for SpreadAcrossNodes.
+func (po *PodOptions) ToPod(resName string) TestRunPod {
+	pod := TestRunPod{
+		Resources:                po.Resources,
+		NodeSelector:             po.NodeSelector,
+		Tolerations:              po.Tolerations,
+		Affinity:                 po.Affinity,
+		PriorityClassName:        po.PriorityClassName,
+		ServiceAccountName:       po.ServiceAccountName,
+		SecurityContext:          po.SecurityContext,
+		ContainerSecurityContext: po.ContainerSecurityContext,
+		Volumes:                  po.Volumes,
+		VolumeMounts:             po.VolumeMounts,
+		Env:                      slices.Clone(po.Env),
+		EnvFrom:                  slices.Clone(po.EnvFrom),
+		HostAliases:              po.HostAliases,
+	}
+	// The operator expects labels and annotations in a metadata object
+	if len(po.Labels) > 0 || len(po.Annotations) > 0 {
+		pod.Metadata = &TestRunPodMetadata{Labels: po.Labels, Annotations: po.Annotations}
+	}
+	if po.SpreadAcrossNodes {
+		pod.TopologySpreadConstraints = []v1.TopologySpreadConstraint{{
+			MaxSkew:           1,
+			TopologyKey:       "kubernetes.io/hostname",
+			WhenUnsatisfiable: v1.ScheduleAnyway,
+			LabelSelector: &meta.LabelSelector{MatchLabels: map[string]string{
+				"k6_cr":  resName,
+				"runner": "true",
+			}},
+		}}
+	}
+	return pod
+}
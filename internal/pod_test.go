@@ -0,0 +1,61 @@
+package internal_test
+
+import (
+	"github.com/brodo/kubectl-k6/internal"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"testing"
+)
+
+func TestPodOptions(t *testing.T) {
+	po, err := internal.ParsePodOptions(map[string]interface{}{
+		"resources":         map[string]interface{}{"requests": map[string]interface{}{"cpu": 1}},
+		"nodeSelector":      map[string]interface{}{"node-pool": "load-generators"},
+		"priorityClassName": "low",
+		"spreadAcrossNodes": true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, po.SetResources(nil, map[string]string{"memory": "1Gi"}))
+	toleration, err := internal.ParseToleration("dedicated=k6:NoSchedule")
+	require.NoError(t, err)
+	require.Equal(t, v1.Toleration{Key: "dedicated", Operator: v1.TolerationOpEqual, Value: "k6", Effect: v1.TaintEffectNoSchedule}, toleration)
+	po.Tolerations = append(po.Tolerations, toleration)
+
+	pod := po.ToPod("run-l4q5ph7vsplt2pxkkv4l")
+	require.Equal(t, "1", pod.Resources.Requests.Cpu().String())
+	require.Equal(t, "1Gi", pod.Resources.Limits.Memory().String())
+	require.Equal(t, map[string]string{"node-pool": "load-generators"}, pod.NodeSelector)
+	require.Equal(t, "low", pod.PriorityClassName)
+	require.Len(t, pod.TopologySpreadConstraints, 1)
+	require.Equal(t, "kubernetes.io/hostname", pod.TopologySpreadConstraints[0].TopologyKey)
+	require.Equal(t, "run-l4q5ph7vsplt2pxkkv4l", pod.TopologySpreadConstraints[0].LabelSelector.MatchLabels["k6_cr"])
+
+	require.Equal(t, internal.TestRunPod{}, (&internal.PodOptions{}).ToPod("run-l4q5ph7vsplt2pxkkv4l"))
+
+	_, err = internal.ParseToleration("dedicated=k6")
+	require.Error(t, err)
+	_, err = internal.ParseToleration("dedicated:NoExec")
+	require.Error(t, err)
+
+	// viper lower-cases the keys of the config file
+	po, err = internal.ParsePodOptions(map[string]interface{}{
+		"serviceaccountname": "k6",
+		"labels":             map[string]interface{}{"team": "checkout"},
+		"annotations":        map[string]interface{}{"sidecar.istio.io/inject": "false"},
+		"volumes":            []interface{}{map[string]interface{}{"name": "ca", "configMap": map[string]interface{}{"name": "ca-bundle"}}},
+		"volumemounts":       []interface{}{map[string]interface{}{"name": "ca", "mountPath": "/etc/ssl/custom"}},
+	})
+	require.NoError(t, err)
+	pod = po.ToPod("run-l4q5ph7vsplt2pxkkv4l")
+	require.Equal(t, "k6", pod.ServiceAccountName)
+	require.Equal(t, &internal.TestRunPodMetadata{
+		Labels:      map[string]string{"team": "checkout"},
+		Annotations: map[string]string{"sidecar.istio.io/inject": "false"},
+	}, pod.Metadata)
+	require.Len(t, pod.VolumeMounts, 1)
+
+	_, err = internal.ParsePodOptions(map[string]interface{}{"nodeSelektor": map[string]interface{}{}})
+	require.ErrorContains(t, err, "unknown field 'nodeSelektor', did you mean 'nodeSelector'?")
+	_, err = internal.ParsePodOptions(map[string]interface{}{"volumeMounts": []interface{}{map[string]interface{}{"name": "ca", "mountPath": "/ca"}}})
+	require.ErrorContains(t, err, "refers to a volume that is not defined")
+}
@@ -0,0 +1,261 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TestRunLabel is the label the operator puts on the jobs and pods of a test run; its value is the test run's name.
+const TestRunLabel = "k6_cr"
+
+// stuckWaitingReasons are the reasons of waiting containers that do not resolve without a change of the test run.
+// ErrImagePull is not one of them: the kubelet retries the pull and only backs off after repeated failures.
+var stuckWaitingReasons = []string{"ImagePullBackOff", "InvalidImageName", "CreateContainerConfigError"}
+
+// RunProblem is a condition that keeps a job or pod of a test run from ever completing.
+type RunProblem struct {
+	// Object is the kind and name of the job or pod, e.g. 'pod/run-abc-1-x2v7q'.
+	Object    string
+	Container string
+	Reason    string
+	Message   string
+}
+
+func (p RunProblem) String() string {
+	s := p.Object
+	if p.Container != "" {
+		s += fmt.Sprintf(", container '%s'", p.Container)
+	}
+	s += ": " + p.Reason
+	if p.Message != "" {
+		s += ": " + p.Message
+	}
+	return s
+}
+
+// StuckRunError is returned if a pod of the test run is stuck or a job cannot create its pods.
+type StuckRunError struct {
+	Problems []RunProblem
+	// Events are the warnings of the test run's jobs and pods, oldest first.
+	Events []v1.Event
+}
+
+func (e *StuckRunError) Error() string {
+	var sb strings.Builder
+	sb.WriteString("the test run is stuck:")
+	for _, p := range e.Problems {
+		sb.WriteString("\n  " + p.String())
+	}
+	if len(e.Events) > 0 {
+		sb.WriteString("\nevents:")
+		for _, event := range e.Events {
+			sb.WriteString("\n  " + FormatEvent(event))
+		}
+	}
+	return sb.String()
+}
+
+// FormatEvent formats an event like 'kubectl events' does.
+func FormatEvent(event v1.Event) string {
+	count := ""
+	if event.Count > 1 {
+		count = fmt.Sprintf(" (x%d)", event.Count)
+	}
+	return fmt.Sprintf("%s %s %s/%s %s: %s%s", eventTime(event).Format(time.TimeOnly), event.Type, event.InvolvedObject.Kind,
+		event.InvolvedObject.Name, event.Reason, strings.TrimSpace(event.Message), count)
+}
+
+func eventTime(event v1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+// PodProblems returns the conditions that keep the pod from ever completing: images that cannot be pulled, invalid
+// container configurations, containers killed because they ran out of memory and pods that cannot be scheduled.
+// Pods that wait for the cluster autoscaler are not stuck, so scaleUp tells if a scale-up was triggered for the pod.
+func PodProblems(pod v1.Pod, scaleUp bool) []RunProblem {
+	var problems []RunProblem
+	statuses := append(slices.Clone(pod.Status.InitContainerStatuses), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		if waiting := status.State.Waiting; waiting != nil && slices.Contains(stuckWaitingReasons, waiting.Reason) {
+			problems = append(problems, RunProblem{Object: "pod/" + pod.Name, Container: status.Name, Reason: waiting.Reason, Message: waiting.Message})
+		}
+		for _, terminated := range []*v1.ContainerStateTerminated{status.State.Terminated, status.LastTerminationState.Terminated} {
+			if terminated != nil && terminated.Reason == "OOMKilled" {
+				problems = append(problems, RunProblem{Object: "pod/" + pod.Name, Container: status.Name, Reason: terminated.Reason,
+					Message: fmt.Sprintf("the container ran out of memory, raise its memory limit (exit code %d)", terminated.ExitCode)})
+				break
+			}
+		}
+	}
+	for _, c := range pod.Status.Conditions {
+		if c.Type == v1.PodScheduled && c.Status == v1.ConditionFalse && c.Reason == v1.PodReasonUnschedulable && !scaleUp {
+			problems = append(problems, RunProblem{Object: "pod/" + pod.Name, Reason: c.Reason, Message: c.Message})
+		}
+	}
+	return problems
+}
+
+// JobEventProblems returns the problems reported by the events of jobs that are not allowed to create their pods,
+// e.g. because a resource quota is exceeded.
+func JobEventProblems(events []v1.Event) []RunProblem {
+	var problems []RunProblem
+	for _, event := range events {
+		if event.InvolvedObject.Kind != "Job" || event.Reason != "FailedCreate" || !strings.Contains(event.Message, "forbidden") {
+			continue
+		}
+		reason := event.Reason
+		if strings.Contains(event.Message, "exceeded quota") {
+			reason = "ExceededQuota"
+		}
+		problems = append(problems, RunProblem{Object: "job/" + event.InvolvedObject.Name, Reason: reason, Message: event.Message})
+	}
+	return problems
+}
+
+// isTestRunObject reports whether the name belongs to the test run or one of its jobs and pods, which are named
+// after the test run.
+func isTestRunObject(name, resName string) bool {
+	return name == resName || strings.HasPrefix(name, resName+"-")
+}
+
+// eventSyncTimeout is how long the shared informer for events may take to list the events of the namespace.
+const eventSyncTimeout = 10 * time.Second
+
+// eventCache is a shared informer for the events of a namespace. The test runs are checked every few seconds, and
+// with zones there are several of them, so the events are watched once instead of being listed on every check.
+type eventCache struct {
+	once   sync.Once
+	lister corelisters.EventNamespaceLister
+	stop   chan struct{}
+	err    error
+}
+
+// start starts the informer and waits until it listed the events. If that fails, e.g. because the events may be
+// listed but not watched, the informer is stopped and the error is kept.
+func (c *eventCache) start(clientSet kubernetes.Interface, namespace string) error {
+	c.once.Do(func() {
+		factory := informers.NewSharedInformerFactoryWithOptions(clientSet, 0, informers.WithNamespace(namespace))
+		informer := factory.Core().V1().Events()
+		c.lister = informer.Lister().Events(namespace)
+		c.stop = make(chan struct{})
+		factory.Start(c.stop)
+		ctx, cancel := context.WithTimeout(context.Background(), eventSyncTimeout)
+		defer cancel()
+		if !cache.WaitForCacheSync(ctx.Done(), informer.Informer().HasSynced) {
+			close(c.stop)
+			c.err = fmt.Errorf("could not watch the events of namespace '%s'", namespace)
+		}
+	})
+	return c.err
+}
+
+// namespaceEvents returns the events of the namespace from the shared informer, or lists them if they cannot be
+// watched.
+func (kc *K8sClient) namespaceEvents(ctx context.Context) ([]v1.Event, error) {
+	if kc.events != nil && kc.events.start(kc.clientSet, kc.namespace) == nil {
+		cached, err := kc.events.lister.List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		events := make([]v1.Event, len(cached))
+		for i, event := range cached {
+			events[i] = *event
+		}
+		return events, nil
+	}
+	list, err := kc.clientSet.CoreV1().Events(kc.namespace).List(ctx, meta.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// GetTestRunEvents returns the events of the test run, its jobs and its pods since the given time, oldest first.
+func (kc *K8sClient) GetTestRunEvents(ctx context.Context, resName string, since time.Time) ([]v1.Event, error) {
+	all, err := kc.namespaceEvents(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var events []v1.Event
+	for _, event := range all {
+		if isTestRunObject(event.InvolvedObject.Name, resName) && !eventTime(event).Before(since.Truncate(time.Second)) {
+			events = append(events, event)
+		}
+	}
+	slices.SortStableFunc(events, func(a, b v1.Event) int { return eventTime(a).Compare(eventTime(b)) })
+	return events, nil
+}
+
+// CheckTestRunPods looks for pods of the test run that will never complete. A StuckRunError with the problems and
+// the warning events is returned if there are any.
+func (kc *K8sClient) CheckTestRunPods(ctx context.Context, resName string, since time.Time) error {
+	pods, err := kc.clientSet.CoreV1().Pods(kc.namespace).List(ctx, meta.ListOptions{LabelSelector: fmt.Sprintf("%s=%s", TestRunLabel, resName)})
+	if err != nil {
+		return err
+	}
+	events, err := kc.GetTestRunEvents(ctx, resName, since)
+	if err != nil {
+		// events are not essential, e.g. if the user may not list them
+		events = nil
+	}
+	problems := JobEventProblems(events)
+	for _, pod := range pods.Items {
+		problems = append(problems, PodProblems(pod, triggeredScaleUp(events, pod.Name))...)
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	var warnings []v1.Event
+	for _, event := range events {
+		if event.Type == v1.EventTypeWarning {
+			warnings = append(warnings, event)
+		}
+	}
+	return &StuckRunError{Problems: problems, Events: warnings}
+}
+
+func triggeredScaleUp(events []v1.Event, podName string) bool {
+	for _, event := range events {
+		if event.InvolvedObject.Name == podName && event.Reason == "TriggeredScaleUp" {
+			return true
+		}
+	}
+	return false
+}
+
+// WatchTestRunPods checks the pods of the test run in the given interval until a problem is found or the context is
+// done. It returns the StuckRunError, or nil if the context is done.
+func (kc *K8sClient) WatchTestRunPods(ctx context.Context, resName string, since time.Time, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			// errors of the API server are not a reason to abort the run, the waits report them
+			var stuckErr *StuckRunError
+			if err := kc.CheckTestRunPods(ctx, resName, since); err != nil && errors.As(err, &stuckErr) {
+				return stuckErr
+			}
+		}
+	}
+}
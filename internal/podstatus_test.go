@@ -0,0 +1,104 @@
+package internal_test
+
+import (
+	"context"
+	"github.com/brodo/kubectl-k6/internal"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+	"time"
+)
+
+func TestPodProblems(t *testing.T) {
+	pod := v1.Pod{
+		ObjectMeta: meta.ObjectMeta{Name: "run-abc-1-x2v7q"},
+		Status: v1.PodStatus{
+			InitContainerStatuses: []v1.ContainerStatus{{Name: "init", State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Reason: "Completed"}}}},
+			ContainerStatuses: []v1.ContainerStatus{
+				{Name: "k6", State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: `Back-off pulling image "grafana/k6:typo"`}}},
+				{Name: "sidecar", State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ContainerCreating"}}},
+			},
+		},
+	}
+	problems := internal.PodProblems(pod, false)
+	require.Len(t, problems, 1)
+	require.Equal(t, `pod/run-abc-1-x2v7q, container 'k6': ImagePullBackOff: Back-off pulling image "grafana/k6:typo"`, problems[0].String())
+
+	// the kubelet retries failed pulls, a pod is only stuck once it backs off
+	pod.Status.ContainerStatuses[0].State.Waiting.Reason = "ErrImagePull"
+	require.Empty(t, internal.PodProblems(pod, false))
+
+	oom := v1.Pod{
+		ObjectMeta: meta.ObjectMeta{Name: "run-abc-2-p8d3k"},
+		Status: v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{{
+			Name:                 "k6",
+			State:                v1.ContainerState{Running: &v1.ContainerStateRunning{}},
+			LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137}},
+		}}},
+	}
+	problems = internal.PodProblems(oom, false)
+	require.Len(t, problems, 1)
+	require.Equal(t, "OOMKilled", problems[0].Reason)
+
+	unschedulable := v1.Pod{
+		ObjectMeta: meta.ObjectMeta{Name: "run-abc-initializer-q9z8m"},
+		Status: v1.PodStatus{Conditions: []v1.PodCondition{{
+			Type: v1.PodScheduled, Status: v1.ConditionFalse, Reason: v1.PodReasonUnschedulable, Message: "0/3 nodes are available",
+		}}},
+	}
+	require.Len(t, internal.PodProblems(unschedulable, false), 1)
+	// the cluster autoscaler adds a node for the pod
+	require.Empty(t, internal.PodProblems(unschedulable, true))
+}
+
+func TestJobEventProblems(t *testing.T) {
+	events := []v1.Event{
+		{
+			InvolvedObject: v1.ObjectReference{Kind: "Job", Name: "run-abc-1"}, Reason: "FailedCreate", Type: v1.EventTypeWarning,
+			Message: `Error creating: pods "run-abc-1-x2v7q" is forbidden: exceeded quota: compute, requested: limits.cpu=2, used: limits.cpu=8, limited: limits.cpu=8`,
+		},
+		{InvolvedObject: v1.ObjectReference{Kind: "Job", Name: "run-abc-2"}, Reason: "SuccessfulCreate", Message: "Created pod: run-abc-2-p8d3k"},
+	}
+	problems := internal.JobEventProblems(events)
+	require.Len(t, problems, 1)
+	require.Equal(t, "job/run-abc-1", problems[0].Object)
+	require.Equal(t, "ExceededQuota", problems[0].Reason)
+}
+
+func TestGetTestRunEvents(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 5, 3, 10, 0, 0, 0, time.UTC)
+	event := func(name, object string, at time.Time) *v1.Event {
+		return &v1.Event{
+			ObjectMeta:     meta.ObjectMeta{Name: name, Namespace: "load"},
+			InvolvedObject: v1.ObjectReference{Kind: "Pod", Name: object},
+			LastTimestamp:  meta.NewTime(at),
+		}
+	}
+	kc := internal.NewFakeK8sClient("load",
+		event("b", "run-abc-1-x2v7q", start.Add(2*time.Second)),
+		event("a", "run-abc-initializer-q9z8m", start.Add(time.Second)),
+		event("old", "run-abc-1-x2v7q", start.Add(-time.Minute)),
+		event("other", "run-abcd-1-p8d3k", start.Add(time.Second)),
+	)
+	events, err := kc.GetTestRunEvents(ctx, "run-abc", start)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, "a", events[0].Name)
+	require.Equal(t, "b", events[1].Name)
+}
+
+func TestStuckRunError(t *testing.T) {
+	err := &internal.StuckRunError{
+		Problems: []internal.RunProblem{{Object: "pod/run-abc-1-x2v7q", Container: "k6", Reason: "ErrImagePull", Message: "not found"}},
+		Events: []v1.Event{{
+			InvolvedObject: v1.ObjectReference{Kind: "Pod", Name: "run-abc-1-x2v7q"}, Type: v1.EventTypeWarning, Reason: "Failed", Count: 3,
+			Message: "Failed to pull image \"grafana/k6:typo\": not found\n", LastTimestamp: meta.NewTime(time.Date(2024, 5, 1, 12, 30, 5, 0, time.UTC)),
+		}},
+	}
+	require.Equal(t, `the test run is stuck:
+  pod/run-abc-1-x2v7q, container 'k6': ErrImagePull: not found
+events:
+  12:30:05 Warning Pod/run-abc-1-x2v7q Failed: Failed to pull image "grafana/k6:typo": not found (x3)`, err.Error())
+}
@@ -0,0 +1,349 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// K6APIPort is the port of the REST API of k6 in the runner pods.
+const K6APIPort = 6565
+
+// K6Status is the status of a k6 instance as reported by its REST API.
+type K6Status struct {
+	Paused  bool  `json:"paused"`
+	VUs     int64 `json:"vus"`
+	VUsMax  int64 `json:"vus-max"`
+	Stopped bool  `json:"stopped"`
+	Running bool  `json:"running"`
+	Tainted bool  `json:"tainted"`
+}
+
+// K6Metric is a metric as reported by the REST API of k6. Type is 'counter', 'gauge', 'rate' or 'trend', the sample
+// holds the values since the start of the test, e.g. 'count' and 'rate' of counters or 'p(95)' of trends. Tainted is
+// nil if the metric has no thresholds.
+type K6Metric struct {
+	Type    string             `json:"type"`
+	Tainted *bool              `json:"tainted"`
+	Sample  map[string]float64 `json:"sample"`
+}
+
+// K6APIClient talks to the REST API of a k6 instance.
+type K6APIClient struct {
+	BaseURL string
+	client  *http.Client
+}
+
+func NewK6APIClient(baseURL string) *K6APIClient {
+	return &K6APIClient{BaseURL: strings.TrimSuffix(baseURL, "/"), client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+// do sends a request to the API and decodes the `data` of the response into data.
+func (c *K6APIClient) do(ctx context.Context, method, path string, body interface{}, data interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(raw)))
+	}
+	envelope := struct {
+		Data interface{} `json:"data"`
+	}{Data: data}
+	return json.Unmarshal(raw, &envelope)
+}
+
+// Status returns the status of the k6 instance.
+func (c *K6APIClient) Status(ctx context.Context) (K6Status, error) {
+	var data struct {
+		Attributes K6Status `json:"attributes"`
+	}
+	err := c.do(ctx, http.MethodGet, "/v1/status", nil, &data)
+	return data.Attributes, err
+}
+
+// RunnerStatus is the status of one runner. Err is set if the runner could not be reached.
+type RunnerStatus struct {
+	Pod        string
+	Status     K6Status
+	Iterations int64
+	// Thresholds is true if any metric has thresholds, FailedThresholds are the metrics whose thresholds were crossed.
+	Thresholds       bool
+	FailedThresholds []string
+	Metrics          map[string]K6Metric
+	Err              error
+}
+
+// RunnerStatus returns the status of the k6 instance with its iterations and thresholds.
+func (c *K6APIClient) RunnerStatus(ctx context.Context) (RunnerStatus, error) {
+	status, err := c.Status(ctx)
+	if err != nil {
+		return RunnerStatus{}, err
+	}
+	var metrics []struct {
+		Id         string   `json:"id"`
+		Attributes K6Metric `json:"attributes"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/v1/metrics", nil, &metrics); err != nil {
+		return RunnerStatus{}, err
+	}
+	rs := RunnerStatus{Status: status, Metrics: make(map[string]K6Metric, len(metrics))}
+	for _, m := range metrics {
+		rs.Metrics[m.Id] = m.Attributes
+		if m.Id == "iterations" {
+			rs.Iterations = int64(m.Attributes.Sample["count"])
+		}
+		if m.Attributes.Tainted != nil {
+			rs.Thresholds = true
+			if *m.Attributes.Tainted {
+				rs.FailedThresholds = append(rs.FailedThresholds, m.Id)
+			}
+		}
+	}
+	slices.Sort(rs.FailedThresholds)
+	return rs, nil
+}
+
+// Progress is the aggregated status of the runners of a test run. Elapsed is the time since the runners started,
+// Duration is the duration declared by the script, 0 if it is unknown.
+type Progress struct {
+	Runners  []RunnerStatus
+	Elapsed  time.Duration
+	Duration time.Duration
+}
+
+// String formats the progress as one status line.
+func (p Progress) String() string {
+	var running, paused, done, unreachable int
+	var vus, vusMax, iterations int64
+	var thresholds bool
+	var failed []string
+	for _, r := range p.Runners {
+		switch {
+		case r.Err != nil:
+			unreachable++
+			continue
+		case r.Status.Stopped || !r.Status.Running:
+			done++
+		case r.Status.Paused:
+			paused++
+		default:
+			running++
+		}
+		vus += r.Status.VUs
+		vusMax += r.Status.VUsMax
+		iterations += r.Iterations
+		thresholds = thresholds || r.Thresholds
+		for _, f := range r.FailedThresholds {
+			if !slices.Contains(failed, f) {
+				failed = append(failed, f)
+			}
+		}
+	}
+	parts := []string{fmt.Sprintf("%d/%d runners running", running, len(p.Runners))}
+	if paused > 0 {
+		parts = append(parts, fmt.Sprintf("%d paused", paused))
+	}
+	if done > 0 {
+		parts = append(parts, fmt.Sprintf("%d done", done))
+	}
+	if unreachable > 0 {
+		parts = append(parts, fmt.Sprintf("%d unreachable", unreachable))
+	}
+	parts = append(parts, fmt.Sprintf("%d/%d VUs", vus, vusMax), fmt.Sprintf("%d iterations", iterations))
+	elapsed := p.Elapsed.Truncate(time.Second)
+	if p.Duration > 0 {
+		remaining := max(p.Duration-elapsed, 0)
+		parts = append(parts, fmt.Sprintf("%s elapsed, %s remaining", elapsed, remaining.Truncate(time.Second)))
+	} else {
+		parts = append(parts, fmt.Sprintf("%s elapsed", elapsed))
+	}
+	switch {
+	case len(failed) > 0:
+		slices.Sort(failed)
+		parts = append(parts, "thresholds crossed: "+strings.Join(failed, ", "))
+	case thresholds:
+		parts = append(parts, "thresholds ok")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ForwardK6API forwards a local port to the REST API of k6 in the pod until ctx is done and returns a client for it.
+func (kc *K8sClient) ForwardK6API(ctx context.Context, pod string) (*K6APIClient, error) {
+	transport, upgrader, err := spdy.RoundTripperFor(kc.restConfig)
+	if err != nil {
+		return nil, err
+	}
+	url := kc.clientSet.CoreV1().RESTClient().Post().Namespace(kc.namespace).Resource("pods").Name(pod).SubResource("portforward").URL()
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, url)
+	stop, ready := make(chan struct{}), make(chan struct{})
+	forwarder, err := portforward.NewOnAddresses(dialer, []string{"127.0.0.1"}, []string{fmt.Sprintf("0:%d", K6APIPort)}, stop, ready, io.Discard, io.Discard)
+	if err != nil {
+		return nil, err
+	}
+	forwardErr := make(chan error, 1)
+	go func() {
+		forwardErr <- forwarder.ForwardPorts()
+	}()
+	select {
+	case <-ready:
+	case err := <-forwardErr:
+		return nil, fmt.Errorf("could not forward to pod '%s': %w", pod, err)
+	case <-ctx.Done():
+		close(stop)
+		return nil, ctx.Err()
+	}
+	go func() {
+		<-ctx.Done()
+		close(stop)
+	}()
+	ports, err := forwarder.GetPorts()
+	if err != nil {
+		return nil, err
+	}
+	return NewK6APIClient(fmt.Sprintf("http://127.0.0.1:%d", ports[0].Local)), nil
+}
+
+// forwardForbidden reports whether a port-forward failed because it is not allowed. The port-forward loses the type
+// of the API's errors.
+func forwardForbidden(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "forbidden")
+}
+
+// runnerForward is a port-forward to the REST API of a runner pod.
+type runnerForward struct {
+	client *K6APIClient
+	cancel context.CancelFunc
+}
+
+// runnerForwards keeps port-forwards to the REST API of the runner pods of test runs across polls.
+type runnerForwards struct {
+	kc       *K8sClient
+	resNames []string
+	forwards map[string]*runnerForward
+}
+
+func (kc *K8sClient) newRunnerForwards(resNames []string) *runnerForwards {
+	return &runnerForwards{kc: kc, resNames: resNames, forwards: make(map[string]*runnerForward)}
+}
+
+// close stops all port-forwards.
+func (rf *runnerForwards) close() {
+	for pod, f := range rf.forwards {
+		f.cancel()
+		delete(rf.forwards, pod)
+	}
+}
+
+// poll returns the status of every runner pod; runners that cannot be reached have an Err. If the pods cannot be
+// listed, no status is returned. An error is returned if the port-forward is not allowed.
+func (rf *runnerForwards) poll(ctx context.Context) ([]RunnerStatus, error) {
+	pods, err := rf.kc.clientSet.CoreV1().Pods(rf.kc.namespace).List(ctx, meta.ListOptions{LabelSelector: RunnerLabelSelector(rf.resNames...)})
+	if err != nil {
+		return nil, nil
+	}
+	var statuses []RunnerStatus
+	for _, pod := range pods.Items {
+		status, err := rf.runnerStatus(ctx, pod)
+		if forwardForbidden(err) {
+			return nil, err
+		}
+		status.Pod, status.Err = pod.Name, err
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// WatchProgress reports the aggregated progress of the runners of the test runs every interval until ctx is done.
+// The REST API of k6 in every running runner pod is reached through a port-forward. Nothing is reported until the
+// runners started. An error is returned if the port-forward is not allowed.
+func (kc *K8sClient) WatchProgress(ctx context.Context, resNames []string, duration, interval time.Duration, report func(Progress)) error {
+	forwards := kc.newRunnerForwards(resNames)
+	defer forwards.close()
+	var started time.Time
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		statuses, err := forwards.poll(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			if started.IsZero() && status.Status.Running && !status.Status.Paused {
+				started = time.Now()
+			}
+		}
+		if started.IsZero() || len(statuses) == 0 {
+			continue
+		}
+		report(Progress{Runners: statuses, Elapsed: time.Since(started), Duration: duration})
+	}
+}
+
+// runnerStatus returns the status of the runner pod, forwarding to its API if necessary. Pods whose k6 container
+// terminated are reported as stopped.
+func (rf *runnerForwards) runnerStatus(ctx context.Context, pod v1.Pod) (RunnerStatus, error) {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == k6ContainerName && status.State.Terminated != nil {
+			if f, ok := rf.forwards[pod.Name]; ok {
+				f.cancel()
+				delete(rf.forwards, pod.Name)
+			}
+			return RunnerStatus{Status: K6Status{Stopped: true}}, nil
+		}
+	}
+	if pod.Status.Phase != v1.PodRunning {
+		return RunnerStatus{}, fmt.Errorf("pod is %s", strings.ToLower(string(pod.Status.Phase)))
+	}
+	f, ok := rf.forwards[pod.Name]
+	if !ok {
+		forwardCtx, cancel := context.WithCancel(ctx)
+		client, err := rf.kc.ForwardK6API(forwardCtx, pod.Name)
+		if err != nil {
+			cancel()
+			return RunnerStatus{}, err
+		}
+		f = &runnerForward{client: client, cancel: cancel}
+		rf.forwards[pod.Name] = f
+	}
+	status, err := f.client.RunnerStatus(ctx)
+	if err != nil {
+		// the forward is recreated in the next poll, e.g. if the connection to the pod was lost
+		f.cancel()
+		delete(rf.forwards, pod.Name)
+	}
+	return status, err
+}
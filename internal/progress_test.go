@@ -0,0 +1,53 @@
+package internal_test
+
+import (
+	"context"
+	"github.com/brodo/kubectl-k6/internal"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestK6APIClient_RunnerStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/status":
+			w.Write([]byte(`{"data":{"type":"status","id":"default","attributes":{"paused":false,"vus":10,"vus-max":20,"stopped":false,"running":true,"tainted":true}}}`))
+		case "/v1/metrics":
+			w.Write([]byte(`{"data":[
+				{"type":"metrics","id":"iterations","attributes":{"type":"counter","contains":"default","tainted":null,"sample":{"count":1234,"rate":20.5}}},
+				{"type":"metrics","id":"http_req_failed","attributes":{"type":"rate","contains":"default","tainted":false,"sample":{"rate":0}}},
+				{"type":"metrics","id":"http_req_duration","attributes":{"type":"trend","contains":"time","tainted":true,"sample":{"p(95)":812}}}
+			]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	status, err := internal.NewK6APIClient(server.URL).RunnerStatus(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, internal.K6Status{VUs: 10, VUsMax: 20, Running: true, Tainted: true}, status.Status)
+	require.Equal(t, int64(1234), status.Iterations)
+	require.True(t, status.Thresholds)
+	require.Equal(t, []string{"http_req_duration"}, status.FailedThresholds)
+}
+
+func TestProgress_String(t *testing.T) {
+	running := internal.K6Status{Running: true, VUs: 10, VUsMax: 10}
+	p := internal.Progress{
+		Runners: []internal.RunnerStatus{
+			{Pod: "run-1", Status: running, Iterations: 100, Thresholds: true},
+			{Pod: "run-2", Status: running, Iterations: 50, Thresholds: true, FailedThresholds: []string{"http_req_duration"}},
+		},
+		Elapsed:  90*time.Second + 300*time.Millisecond,
+		Duration: 5 * time.Minute,
+	}
+	require.Equal(t, "2/2 runners running, 20/20 VUs, 150 iterations, 1m30s elapsed, 3m30s remaining, thresholds crossed: http_req_duration", p.String())
+
+	p.Runners[1] = internal.RunnerStatus{Pod: "run-2", Status: internal.K6Status{Stopped: true}}
+	p.Duration = 0
+	require.Equal(t, "1/2 runners running, 1 done, 10/10 VUs, 100 iterations, 1m30s elapsed, thresholds ok", p.String())
+}
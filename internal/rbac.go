@@ -0,0 +1,195 @@
+package internal
+
+import (
+	rbac "k8s.io/api/rbac/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"slices"
+)
+
+// Features are the configured features of the run command that determine which permissions the plugin needs.
+type Features struct {
+	// Folder is true if the script folder is uploaded to a persistent volume.
+	Folder bool
+	// EnvFile is true if variables are uploaded as a short-lived secret.
+	EnvFile bool
+	// SecretRefs is true if the runners' environment refers to secrets, whose values are read for the redaction.
+	SecretRefs bool
+	// BundleScript is true if the script of a manifest is bundled and applied to the config map the manifest refers to.
+	BundleScript bool
+	// SaveBaseline is true if the summary is saved as a baseline config map.
+	SaveBaseline bool
+	// SaveResults is true if the results record of runs is saved in the cluster.
+	SaveResults bool
+	// PruneResults is true if old results records are deleted.
+	PruneResults bool
+	// ReadResults is true if the baseline is the results record of another run.
+	ReadResults bool
+	// QuitSidecars is true if the sidecars of the runner pods are shut down by running a command in them.
+	QuitSidecars bool
+	// Progress is true if the progress of runs is read from the REST API of k6 through port-forwards.
+	Progress bool
+	// Zones is true if the runners of several test runs are started together through the REST API of k6.
+	Zones bool
+	// Guardrails is true if guardrails are configured. They check the metrics of the runners and stop them when one
+	// trips through port-forwards.
+	Guardrails bool
+	// GuardrailNamespaces are the namespaces of the deployments whose restarts guardrails watch; "" is the namespace
+	// the tests run in.
+	GuardrailNamespaces []string
+	// ResultsNamespace is the namespace of the results records, if it differs from the namespace the tests run in.
+	ResultsNamespace string
+}
+
+// Permission is an access to the API the plugin needs.
+type Permission struct {
+	Group       string
+	Resource    string
+	Subresource string
+	Verbs       []string
+	// Namespace is set if the permission is needed in another namespace than the one the tests run in.
+	Namespace string
+	// ClusterScoped is true for resources that do not belong to a namespace.
+	ClusterScoped bool
+}
+
+func (p Permission) String() string {
+	resource := p.Resource
+	if p.Subresource != "" {
+		resource += "/" + p.Subresource
+	}
+	if p.Group != "" {
+		resource += "." + p.Group
+	}
+	return resource
+}
+
+// RequiredPermissions returns the permissions the plugin needs to run tests with the given k6 operator API and
+// features. This is the only place that lists them: the doctor and rbac commands are derived from it, so add
+// the permissions of new features here.
+func RequiredPermissions(k6API K6API, features Features) []Permission {
+	var perms []Permission
+	// the custom resource is created, polled and deleted in the clean-up
+	perms = addPermission(perms, Permission{Group: k6API.GVR.Group, Resource: k6API.GVR.Resource, Verbs: []string{"create", "get", "delete"}})
+	// the script's config map is created and deleted, the deletion is awaited with get
+	perms = addPermission(perms, Permission{Resource: "configmaps", Verbs: []string{"create", "get", "delete"}})
+	// the jobs are polled, the logs of their pods and of the operator are shown, failed runs are diagnosed
+	perms = addPermission(perms, Permission{Group: "batch", Resource: "jobs", Verbs: []string{"get", "list"}})
+	perms = addPermission(perms, Permission{Resource: "pods", Verbs: []string{"list"}})
+	perms = addPermission(perms, Permission{Resource: "pods", Subresource: "log", Verbs: []string{"get"}})
+	// the events of stuck and failed runs are shown
+	perms = addPermission(perms, Permission{Resource: "events", Verbs: []string{"list", "watch"}})
+	if features.Folder {
+		perms = addPermission(perms, Permission{Resource: "persistentvolumeclaims", Verbs: []string{"create"}})
+		perms = addPermission(perms, Permission{Resource: "persistentvolumes", Verbs: []string{"create"}, ClusterScoped: true})
+	}
+	if features.EnvFile {
+		// the owner of the secret is set after the custom resource is created
+		perms = addPermission(perms, Permission{Resource: "secrets", Verbs: []string{"create", "get", "update", "delete"}})
+	}
+	if features.SecretRefs {
+		perms = addPermission(perms, Permission{Resource: "secrets", Verbs: []string{"get"}})
+	}
+	if features.BundleScript {
+		// the config map of the manifest may exist from an earlier run, it is updated then
+		perms = addPermission(perms, Permission{Resource: "configmaps", Verbs: []string{"update"}})
+	}
+	if features.SaveBaseline {
+		perms = addPermission(perms, Permission{Resource: "configmaps", Verbs: []string{"create", "update"}})
+	}
+	if features.SaveResults {
+		perms = addPermission(perms, Permission{Resource: "configmaps", Verbs: []string{"create", "update"}, Namespace: features.ResultsNamespace})
+	}
+	if features.PruneResults {
+		perms = addPermission(perms, Permission{Resource: "configmaps", Verbs: []string{"list", "delete"}, Namespace: features.ResultsNamespace})
+	}
+	if features.QuitSidecars {
+		perms = addPermission(perms, Permission{Resource: "pods", Subresource: "exec", Verbs: []string{"create"}})
+	}
+	if features.Progress || features.Guardrails || features.Zones {
+		perms = addPermission(perms, Permission{Resource: "pods", Subresource: "portforward", Verbs: []string{"create"}})
+	}
+	for _, ns := range features.GuardrailNamespaces {
+		perms = addPermission(perms, Permission{Group: "apps", Resource: "deployments", Verbs: []string{"get"}, Namespace: ns})
+		perms = addPermission(perms, Permission{Resource: "pods", Verbs: []string{"list"}, Namespace: ns})
+	}
+	if features.ReadResults {
+		perms = addPermission(perms, Permission{Resource: "configmaps", Verbs: []string{"get"}, Namespace: features.ResultsNamespace})
+	}
+	return perms
+}
+
+// addPermission adds the permission or merges its verbs into an existing permission for the same resource.
+func addPermission(perms []Permission, p Permission) []Permission {
+	for i, existing := range perms {
+		if existing.Group == p.Group && existing.Resource == p.Resource && existing.Subresource == p.Subresource &&
+			existing.Namespace == p.Namespace && existing.ClusterScoped == p.ClusterScoped {
+			verbs := slices.Clone(existing.Verbs)
+			for _, verb := range p.Verbs {
+				if !slices.Contains(verbs, verb) {
+					verbs = append(verbs, verb)
+				}
+			}
+			perms[i].Verbs = verbs
+			return perms
+		}
+	}
+	return append(perms, p)
+}
+
+// RBACManifests creates the roles and bindings that grant the permissions to a service account. Namespaced
+// permissions are granted with a Role per namespace, cluster-scoped ones with a ClusterRole.
+func RBACManifests(perms []Permission, name, namespace string, subject rbac.Subject) []runtime.Object {
+	rules := map[string][]rbac.PolicyRule{}
+	var namespaces []string
+	for _, p := range perms {
+		ns := namespace
+		switch {
+		case p.ClusterScoped:
+			ns = ""
+		case p.Namespace != "":
+			ns = p.Namespace
+		}
+		if _, ok := rules[ns]; !ok {
+			namespaces = append(namespaces, ns)
+		}
+		resource := p.Resource
+		if p.Subresource != "" {
+			resource += "/" + p.Subresource
+		}
+		rules[ns] = append(rules[ns], rbac.PolicyRule{APIGroups: []string{p.Group}, Resources: []string{resource}, Verbs: p.Verbs})
+	}
+	var manifests []runtime.Object
+	for _, ns := range namespaces {
+		if ns == "" {
+			roleRef := rbac.RoleRef{APIGroup: rbac.GroupName, Kind: "ClusterRole", Name: name}
+			manifests = append(manifests,
+				&rbac.ClusterRole{
+					TypeMeta:   meta.TypeMeta{APIVersion: rbac.SchemeGroupVersion.String(), Kind: "ClusterRole"},
+					ObjectMeta: meta.ObjectMeta{Name: name},
+					Rules:      rules[ns],
+				},
+				&rbac.ClusterRoleBinding{
+					TypeMeta:   meta.TypeMeta{APIVersion: rbac.SchemeGroupVersion.String(), Kind: "ClusterRoleBinding"},
+					ObjectMeta: meta.ObjectMeta{Name: name},
+					Subjects:   []rbac.Subject{subject},
+					RoleRef:    roleRef,
+				})
+			continue
+		}
+		roleRef := rbac.RoleRef{APIGroup: rbac.GroupName, Kind: "Role", Name: name}
+		manifests = append(manifests,
+			&rbac.Role{
+				TypeMeta:   meta.TypeMeta{APIVersion: rbac.SchemeGroupVersion.String(), Kind: "Role"},
+				ObjectMeta: meta.ObjectMeta{Name: name, Namespace: ns},
+				Rules:      rules[ns],
+			},
+			&rbac.RoleBinding{
+				TypeMeta:   meta.TypeMeta{APIVersion: rbac.SchemeGroupVersion.String(), Kind: "RoleBinding"},
+				ObjectMeta: meta.ObjectMeta{Name: name, Namespace: ns},
+				Subjects:   []rbac.Subject{subject},
+				RoleRef:    roleRef,
+			})
+	}
+	return manifests
+}
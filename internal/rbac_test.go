@@ -0,0 +1,84 @@
+package internal_test
+
+import (
+	"github.com/brodo/kubectl-k6/internal"
+	"github.com/stretchr/testify/require"
+	rbac "k8s.io/api/rbac/v1"
+	"testing"
+)
+
+func TestRequiredPermissions(t *testing.T) {
+	legacy := internal.DefaultK6API
+	legacy.GVR.Resource = "k6s"
+	names := func(perms []internal.Permission) []string {
+		var names []string
+		for _, p := range perms {
+			names = append(names, p.String())
+		}
+		return names
+	}
+
+	minimal := internal.RequiredPermissions(legacy, internal.Features{})
+	require.Equal(t, []string{"k6s.k6.io", "configmaps", "jobs.batch", "pods", "pods/log", "events"}, names(minimal))
+
+	all := internal.RequiredPermissions(internal.DefaultK6API, internal.Features{
+		Folder: true, EnvFile: true, SecretRefs: true, SaveBaseline: true, SaveResults: true, ReadResults: true, QuitSidecars: true, Progress: true, ResultsNamespace: "results",
+	})
+	require.Equal(t, []string{"testruns.k6.io", "configmaps", "jobs.batch", "pods", "pods/log", "events", "persistentvolumeclaims", "persistentvolumes", "secrets", "configmaps", "pods/exec", "pods/portforward"}, names(all))
+	require.Equal(t, []string{"create", "get", "delete", "update"}, all[1].Verbs)
+	require.Equal(t, []string{"create", "get", "update", "delete"}, all[8].Verbs)
+	require.Equal(t, "results", all[9].Namespace)
+	require.Equal(t, []string{"create", "update", "get"}, all[9].Verbs)
+	// merging must not modify the permissions of other calls
+	require.Equal(t, []string{"create", "get", "delete"}, internal.RequiredPermissions(legacy, internal.Features{})[1].Verbs)
+}
+
+func TestRequiredPermissions_BundleScript(t *testing.T) {
+	perms := internal.RequiredPermissions(internal.DefaultK6API, internal.Features{BundleScript: true})
+	require.Equal(t, internal.Permission{Resource: "configmaps", Verbs: []string{"create", "get", "delete", "update"}}, perms[1])
+}
+
+func TestRequiredPermissions_Guardrails(t *testing.T) {
+	perms := internal.RequiredPermissions(internal.DefaultK6API, internal.Features{Guardrails: true, GuardrailNamespaces: []string{"", "staging"}})
+	require.Contains(t, perms, internal.Permission{Resource: "pods", Subresource: "portforward", Verbs: []string{"create"}})
+	require.Contains(t, perms, internal.Permission{Group: "apps", Resource: "deployments", Verbs: []string{"get"}})
+	require.Contains(t, perms, internal.Permission{Group: "apps", Resource: "deployments", Verbs: []string{"get"}, Namespace: "staging"})
+	require.Contains(t, perms, internal.Permission{Resource: "pods", Verbs: []string{"list"}, Namespace: "staging"})
+}
+
+func TestRequiredPermissions_RestartGuardrails(t *testing.T) {
+	// a tripped restart guardrail stops the runners through their REST API as well
+	perms := internal.RequiredPermissions(internal.DefaultK6API, internal.Features{Guardrails: true, GuardrailNamespaces: []string{""}})
+	require.Contains(t, perms, internal.Permission{Resource: "pods", Subresource: "portforward", Verbs: []string{"create"}})
+}
+
+func TestRequiredPermissions_PruneResults(t *testing.T) {
+	perms := internal.RequiredPermissions(internal.DefaultK6API, internal.Features{SaveResults: true, PruneResults: true, ResultsNamespace: "results"})
+	require.Contains(t, perms, internal.Permission{Resource: "configmaps", Verbs: []string{"create", "update", "list", "delete"}, Namespace: "results"})
+}
+
+func TestRequiredPermissions_Zones(t *testing.T) {
+	perms := internal.RequiredPermissions(internal.DefaultK6API, internal.Features{Zones: true})
+	require.Contains(t, perms, internal.Permission{Resource: "pods", Subresource: "portforward", Verbs: []string{"create"}})
+}
+
+func TestRBACManifests(t *testing.T) {
+	perms := internal.RequiredPermissions(internal.DefaultK6API, internal.Features{Folder: true, SaveResults: true, ResultsNamespace: "results"})
+	subject := rbac.Subject{Kind: rbac.ServiceAccountKind, Name: "ci", Namespace: "load"}
+	manifests := internal.RBACManifests(perms, "kubectl-k6", "load", subject)
+	require.Len(t, manifests, 6)
+	role := manifests[0].(*rbac.Role)
+	require.Equal(t, "load", role.Namespace)
+	require.Contains(t, role.Rules, rbac.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods/log"}, Verbs: []string{"get"}})
+	clusterRole := manifests[2].(*rbac.ClusterRole)
+	require.Equal(t, []rbac.PolicyRule{{APIGroups: []string{""}, Resources: []string{"persistentvolumes"}, Verbs: []string{"create"}}}, clusterRole.Rules)
+	resultsBinding := manifests[5].(*rbac.RoleBinding)
+	require.Equal(t, "results", resultsBinding.Namespace)
+	require.Equal(t, []rbac.Subject{subject}, resultsBinding.Subjects)
+
+	out, err := internal.ManifestsToYAML(manifests)
+	require.NoError(t, err)
+	require.Contains(t, out, "kind: ClusterRoleBinding\n")
+	require.Contains(t, out, "---\n")
+	require.NotContains(t, out, "creationTimestamp")
+}
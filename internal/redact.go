@@ -0,0 +1,150 @@
+package internal
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// RedactedPlaceholder replaces all redacted values.
+const RedactedPlaceholder = "[REDACTED]"
+
+// minRedactedValueLength avoids redacting short values like `1` or `true` everywhere in the logs.
+const minRedactedValueLength = 4
+
+// DefaultRedactedKeys are the patterns of environment variable names whose values are always redacted.
+var DefaultRedactedKeys = []string{"*SECRET*", "*TOKEN*", "*PASSWORD*", "*PASSWD*", "*KEY*", "*CREDENTIAL*", "*AUTH*"}
+
+// DefaultRedactedPatterns are regular expressions for values that are always redacted from logs.
+var DefaultRedactedPatterns = []string{
+	`(?i)bearer\s+[A-Za-z0-9._~+/=-]+`,
+	`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`,
+}
+
+// Redactor removes sensitive values from text before it is shown on the console or written to reports and
+// the history.
+type Redactor struct {
+	keys     []string
+	values   []string
+	patterns []*regexp.Regexp
+}
+
+// NewRedactor creates a redactor for environment variables matching the key patterns (globs like `*SECRET*`,
+// matched case-insensitively) and for log content matching the regular expressions. The defaults are always included.
+func NewRedactor(keys []string, patterns []string) (*Redactor, error) {
+	r := &Redactor{}
+	for _, key := range append(slices.Clone(DefaultRedactedKeys), keys...) {
+		if _, err := path.Match(key, ""); err != nil {
+			return nil, fmt.Errorf("invalid redaction key pattern '%s': %w", key, err)
+		}
+		r.keys = append(r.keys, strings.ToUpper(key))
+	}
+	for _, pattern := range append(slices.Clone(DefaultRedactedPatterns), patterns...) {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern '%s': %w", pattern, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// AddValues registers values, e.g. from Secrets, that are redacted wherever they appear.
+func (r *Redactor) AddValues(values ...string) {
+	for _, v := range values {
+		if len(v) >= minRedactedValueLength && !slices.Contains(r.values, v) {
+			r.values = append(r.values, v)
+		}
+	}
+	// replace longer values first, so values containing other values are redacted completely
+	slices.SortFunc(r.values, func(a, b string) int { return len(b) - len(a) })
+}
+
+// IsSensitiveKey reports whether the environment variable's value must be redacted.
+func (r *Redactor) IsSensitiveKey(key string) bool {
+	upper := strings.ToUpper(key)
+	for _, pattern := range r.keys {
+		if matched, _ := path.Match(pattern, upper); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactEnv returns a copy of the environment with the values of sensitive variables replaced. These values are
+// also registered, so they are redacted from logs.
+func (r *Redactor) RedactEnv(env K6Environment) K6Environment {
+	redacted := make(K6Environment, len(env))
+	for k, v := range env {
+		if r.IsSensitiveKey(k) {
+			r.AddValues(v)
+			redacted[k] = RedactedPlaceholder
+		} else {
+			redacted[k] = v
+		}
+	}
+	return redacted
+}
+
+// RedactPodOptions returns a copy of the pod options with the literal values of sensitive environment variables
+// replaced. These values are also registered, so they are redacted from logs and manifests.
+func (r *Redactor) RedactPodOptions(po PodOptions) PodOptions {
+	po.Env = slices.Clone(po.Env)
+	for i, env := range po.Env {
+		if env.Value != "" && env.Value != RedactedPlaceholder && r.IsSensitiveKey(env.Name) {
+			r.AddValues(env.Value)
+			po.Env[i].Value = RedactedPlaceholder
+		}
+	}
+	return po
+}
+
+// RedactArgs returns the k6 arguments with the values of sensitive variables that are set with `-e` or `--env`
+// replaced. These values are also registered.
+func (r *Redactor) RedactArgs(k6args string) string {
+	for k, v := range ArgsEnv(k6args) {
+		if v != "" && v != RedactedPlaceholder && r.IsSensitiveKey(k) {
+			r.AddValues(v)
+			k6args = ReplaceArgsEnv(k6args, k, v, RedactedPlaceholder)
+		}
+	}
+	return k6args
+}
+
+// Redact replaces all registered values and all matches of the patterns.
+func (r *Redactor) Redact(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, v := range r.values {
+		s = strings.ReplaceAll(s, v, RedactedPlaceholder)
+	}
+	for _, re := range r.patterns {
+		s = re.ReplaceAllString(s, RedactedPlaceholder)
+	}
+	return s
+}
+
+// RedactError returns an error with a redacted message that still wraps the original error, so `errors.Is`
+// keeps working.
+func (r *Redactor) RedactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &redactedError{err: err, msg: r.Redact(err.Error())}
+}
+
+type redactedError struct {
+	err error
+	msg string
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,210 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+type DryRunMode string
+
+const (
+	// DryRunNone runs the test.
+	DryRunNone DryRunMode = "none"
+	// DryRunClient prints the objects without contacting the cluster.
+	DryRunClient DryRunMode = "client"
+	// DryRunServer submits the objects with `dryRun=All`, so admission webhooks and quotas are checked.
+	DryRunServer DryRunMode = "server"
+)
+
+func ParseDryRunMode(s string) (DryRunMode, error) {
+	switch mode := DryRunMode(s); mode {
+	case DryRunNone, DryRunClient, DryRunServer:
+		return mode, nil
+	case "":
+		return DryRunNone, nil
+	}
+	return DryRunNone, fmt.Errorf("invalid dry run mode '%s', use 'none', 'client' or 'server'", s)
+}
+
+// Manifests are the objects the plugin creates for a run. Only the objects the configuration needs are set.
+type Manifests struct {
+	ConfigMap *v1.ConfigMap
+	PV        *v1.PersistentVolume
+	PVC       *v1.PersistentVolumeClaim
+	Secret    *v1.Secret
+	TestRun   *unstructured.Unstructured
+	// ZoneTestRuns are the test runs of the zones, they are set instead of TestRun if zones are configured.
+	ZoneTestRuns []*unstructured.Unstructured
+}
+
+// Objects returns the objects in the order they are created.
+func (m *Manifests) Objects() []runtime.Object {
+	var objects []runtime.Object
+	if m.ConfigMap != nil {
+		objects = append(objects, m.ConfigMap)
+	}
+	if m.PV != nil {
+		objects = append(objects, m.PV)
+	}
+	if m.PVC != nil {
+		objects = append(objects, m.PVC)
+	}
+	if m.Secret != nil {
+		objects = append(objects, m.Secret)
+	}
+	if m.TestRun != nil {
+		objects = append(objects, m.TestRun)
+	}
+	for _, testRun := range m.ZoneTestRuns {
+		objects = append(objects, testRun)
+	}
+	return objects
+}
+
+// DryRunCreate submits the objects with `dryRun=All`. Nothing is persisted, but the objects pass validation,
+// admission webhooks and quotas. The objects the server returned are returned.
+func (kc *K8sClient) DryRunCreate(ctx context.Context, m *Manifests) (*Manifests, error) {
+	opts := metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}}
+	result := &Manifests{}
+	var err error
+	if m.ConfigMap != nil {
+		if result.ConfigMap, err = kc.clientSet.CoreV1().ConfigMaps(kc.namespace).Create(ctx, m.ConfigMap, opts); err != nil {
+			return nil, fmt.Errorf("config map '%s': %w", m.ConfigMap.Name, err)
+		}
+	}
+	if m.PV != nil {
+		if result.PV, err = kc.clientSet.CoreV1().PersistentVolumes().Create(ctx, m.PV, opts); err != nil {
+			return nil, fmt.Errorf("persistent volume '%s': %w", m.PV.Name, err)
+		}
+	}
+	if m.PVC != nil {
+		if result.PVC, err = kc.clientSet.CoreV1().PersistentVolumeClaims(kc.namespace).Create(ctx, m.PVC, opts); err != nil {
+			return nil, fmt.Errorf("persistent volume claim '%s': %w", m.PVC.Name, err)
+		}
+	}
+	if m.Secret != nil {
+		if result.Secret, err = kc.clientSet.CoreV1().Secrets(kc.namespace).Create(ctx, m.Secret, opts); err != nil {
+			return nil, fmt.Errorf("secret '%s': %w", m.Secret.Name, err)
+		}
+	}
+	if m.TestRun != nil {
+		if result.TestRun, err = kc.dynamicClient.Resource(kc.k6API.GVR).Namespace(kc.namespace).Create(ctx, m.TestRun, opts); err != nil {
+			return nil, fmt.Errorf("%s '%s': %w", kc.k6API.Kind, m.TestRun.GetName(), err)
+		}
+	}
+	for _, testRun := range m.ZoneTestRuns {
+		created, err := kc.dynamicClient.Resource(kc.k6API.GVR).Namespace(kc.namespace).Create(ctx, testRun, opts)
+		if err != nil {
+			return nil, fmt.Errorf("%s '%s': %w", kc.k6API.Kind, testRun.GetName(), err)
+		}
+		result.ZoneTestRuns = append(result.ZoneTestRuns, created)
+	}
+	// the typed clients drop the type meta of the returned objects
+	for i, obj := range result.Objects() {
+		obj.GetObjectKind().SetGroupVersionKind(m.Objects()[i].GetObjectKind().GroupVersionKind())
+	}
+	return result, nil
+}
+
+// ManifestsToYAML joins the manifests to a multi-document YAML stream.
+func ManifestsToYAML(manifests []runtime.Object) (string, error) {
+	return FormatManifests(manifests, "yaml", nil)
+}
+
+// FormatManifests formats the manifests as a multi-document YAML stream or as JSON, where several manifests are
+// wrapped into a list like kubectl does. If redact is not nil, it is applied to the JSON of every manifest, so
+// replaced values are quoted properly in YAML.
+func FormatManifests(manifests []runtime.Object, format string, redact func(string) string) (string, error) {
+	if format != "yaml" && format != "json" {
+		return "", fmt.Errorf("invalid output format '%s', use 'yaml' or 'json'", format)
+	}
+	docs := make([]json.RawMessage, 0, len(manifests))
+	for _, m := range manifests {
+		object, err := manifestObject(m)
+		if err != nil {
+			return "", err
+		}
+		data, err := json.MarshalIndent(object, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		if redact != nil {
+			data = []byte(redact(string(data)))
+		}
+		docs = append(docs, data)
+	}
+	var buf bytes.Buffer
+	switch {
+	case format == "yaml":
+		for i, doc := range docs {
+			data, err := yaml.JSONToYAML(doc)
+			if err != nil {
+				return "", fmt.Errorf("could not convert manifest to YAML: %w", err)
+			}
+			if i > 0 {
+				buf.WriteString("---\n")
+			}
+			buf.Write(data)
+		}
+	case len(docs) == 1:
+		buf.Write(docs[0])
+		buf.WriteString("\n")
+	default:
+		data, err := json.MarshalIndent(map[string]interface{}{"apiVersion": "v1", "kind": "List", "items": docs}, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		buf.Write(data)
+		buf.WriteString("\n")
+	}
+	return buf.String(), nil
+}
+
+// WriteManifests writes every manifest into its own file in the directory, e.g. for GitOps. The files are named
+// after the order of creation, the kind and the name of the objects. See FormatManifests for redact.
+func WriteManifests(manifests []runtime.Object, format, dir string, redact func(string) string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	var paths []string
+	for i, m := range manifests {
+		content, err := FormatManifests([]runtime.Object{m}, format, redact)
+		if err != nil {
+			return nil, err
+		}
+		accessor, err := meta.Accessor(m)
+		if err != nil {
+			return nil, err
+		}
+		kind := strings.ToLower(m.GetObjectKind().GroupVersionKind().Kind)
+		path := filepath.Join(dir, fmt.Sprintf("%02d-%s-%s.%s", i+1, kind, accessor.GetName(), format))
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// manifestObject converts the manifest into a map. The creation timestamp is left out if the server did not set it.
+func manifestObject(m runtime.Object) (map[string]interface{}, error) {
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(m)
+	if err != nil {
+		return nil, err
+	}
+	if ts, found, _ := unstructured.NestedFieldNoCopy(object, "metadata", "creationTimestamp"); found && ts == nil {
+		unstructured.RemoveNestedField(object, "metadata", "creationTimestamp")
+	}
+	return object, nil
+}
@@ -0,0 +1,65 @@
+package internal_test
+
+import (
+	"encoding/json"
+	"github.com/brodo/kubectl-k6/internal"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/rest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderManifests(t *testing.T) {
+	_, err := internal.ParseDryRunMode("local")
+	require.ErrorContains(t, err, "invalid dry run mode 'local'")
+	mode, err := internal.ParseDryRunMode("server")
+	require.NoError(t, err)
+	require.Equal(t, internal.DryRunServer, mode)
+
+	// nothing is sent to the cluster
+	err, kc := internal.ConnectK8s(&rest.Config{Host: "https://127.0.0.1:1"}, "load")
+	require.NoError(t, err)
+	sps := internal.NewScriptProperties("liveness.js")
+	tVars := internal.NewTemplateVars(sps)
+	k6Conf := internal.NewK6Config(internal.K6Environment{}, "", "", 1, "", "", "liveness.js")
+	k6Conf.EnvSecret = sps.EnvSecretName()
+	testRun, err := kc.NewCustomResource(&k6Conf, &tVars)
+	require.NoError(t, err)
+	require.Equal(t, "load", testRun.GetNamespace())
+	manifests := &internal.Manifests{
+		ConfigMap: kc.NewScriptConfigMap(&sps, "export default function() {}"),
+		Secret:    kc.NewEnvSecret(&sps, map[string]string{"TOKEN": "secret"}),
+		TestRun:   testRun,
+	}
+
+	out, err := internal.FormatManifests(manifests.Objects(), "yaml", nil)
+	require.NoError(t, err)
+	docs := strings.Split(out, "---\n")
+	require.Len(t, docs, 3)
+	require.Contains(t, docs[0], "kind: ConfigMap\n")
+	require.Contains(t, docs[1], "TOKEN: secret\n")
+	require.Contains(t, docs[2], "kind: TestRun\n")
+	require.NotContains(t, out, "creationTimestamp")
+
+	out, err = internal.FormatManifests(manifests.Objects(), "json", nil)
+	require.NoError(t, err)
+	var list struct {
+		Kind  string
+		Items []map[string]interface{}
+	}
+	require.NoError(t, json.Unmarshal([]byte(out), &list))
+	require.Equal(t, "List", list.Kind)
+	require.Len(t, list.Items, 3)
+
+	dir := filepath.Join(t.TempDir(), "manifests")
+	paths, err := internal.WriteManifests(manifests.Objects(), "yaml", dir, func(s string) string {
+		return strings.ReplaceAll(s, "secret", "[REDACTED]")
+	})
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "02-secret-"+sps.EnvSecretName()+".yaml"), paths[1])
+	content, err := os.ReadFile(paths[1])
+	require.NoError(t, err)
+	require.Contains(t, string(content), "TOKEN: '[REDACTED]'")
+}
@@ -0,0 +1,285 @@
+package internal
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// logExcerptLines is the number of log lines attached to failed test cases.
+const logExcerptLines = 50
+
+// RunReport holds everything the plugin knows about a finished run. It is the input for all reports.
+type RunReport struct {
+	ScriptPath   string
+	RunId        string
+	Image        string
+	Parallelism  int
+	Arguments    string
+	Env          K6Environment
+	Start        time.Time
+	End          time.Time
+	Stages       []StageTiming
+	Summary      *Summary
+	Bundle       []byte
+	SourceMap    []byte
+	RunnerLogs   []LogsWithNames
+	OperatorLogs string
+	Err          error
+}
+
+// StageTiming records how long one stage of a run took.
+type StageTiming struct {
+	Name     string        `json:"name"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+}
+
+// StartStage ends the current stage and starts a new one.
+func (r *RunReport) StartStage(name string) {
+	r.EndStage()
+	r.Stages = append(r.Stages, StageTiming{Name: name, Start: time.Now()})
+}
+
+// EndStage ends the current stage, if there is one.
+func (r *RunReport) EndStage() {
+	if len(r.Stages) == 0 {
+		return
+	}
+	last := &r.Stages[len(r.Stages)-1]
+	if last.Duration == 0 {
+		last.Duration = time.Since(last.Start)
+	}
+}
+
+// ReportTarget is a report format and the file it should be written to, e.g. `junit=report.xml`.
+type ReportTarget struct {
+	Format string
+	Path   string
+}
+
+var reportWriters = map[string]func(path string, reports []*RunReport) error{
+	"html":     WriteHTMLReport,
+	"junit":    WriteJUnitReport,
+	"markdown": WriteMarkdownReport,
+}
+
+// ParseReportTarget parses a `<format>=<path>` report argument.
+func ParseReportTarget(arg string) (ReportTarget, error) {
+	format, path, found := strings.Cut(arg, "=")
+	if !found || path == "" {
+		return ReportTarget{}, fmt.Errorf("invalid report '%s', expected <format>=<path>", arg)
+	}
+	if _, ok := reportWriters[format]; !ok {
+		return ReportTarget{}, fmt.Errorf("unknown report format '%s', supported formats are: %s", format, strings.Join(sortedKeys(reportWriters), ", "))
+	}
+	return ReportTarget{Format: format, Path: os.ExpandEnv(path)}, nil
+}
+
+// Write writes the reports in the target's format.
+func (rt ReportTarget) Write(reports []*RunReport) error {
+	return reportWriters[rt.Format](rt.Path, reports)
+}
+
+// Failed reports whether the run failed, either because of an error or because thresholds were crossed.
+func (r *RunReport) Failed() bool {
+	return r.Err != nil || (r.Summary != nil && len(r.Summary.FailedThresholds()) > 0)
+}
+
+// ErrorMessage returns the first line of the error. The errors of failed jobs continue with the logs of all
+// attempts, which the reports show separately.
+func (r *RunReport) ErrorMessage() string {
+	if r.Err == nil {
+		return ""
+	}
+	return errorHeadline(r.Err.Error())
+}
+
+func errorHeadline(msg string) string {
+	line, _, _ := strings.Cut(msg, "\n")
+	return strings.TrimSuffix(strings.TrimSpace(line), ", logs:")
+}
+
+// LogExcerpt returns the last lines of all runner logs.
+func (r *RunReport) LogExcerpt() string {
+	var sb strings.Builder
+	for _, l := range r.RunnerLogs {
+		lines := strings.Split(strings.TrimRight(StripSummary(l.Logs), "\n"), "\n")
+		if len(lines) > logExcerptLines {
+			lines = lines[len(lines)-logExcerptLines:]
+		}
+		sb.WriteString(fmt.Sprintf("--- %s ---\n%s\n", l.PodName, strings.Join(lines, "\n")))
+	}
+	return sb.String()
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Time      float64         `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+	SystemOut string          `xml:"system-out,omitempty"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure `xml:"error,omitempty"`
+}
+
+type junitFailure struct {
+	Message  string `xml:"message,attr"`
+	Type     string `xml:"type,attr"`
+	Contents string `xml:",chardata"`
+}
+
+// WriteJUnitReport writes one test suite per script. Every threshold and every check is a test case.
+func WriteJUnitReport(path string, reports []*RunReport) error {
+	suites := junitTestSuites{Name: "kubectl-k6"}
+	for _, r := range reports {
+		suite := junitTestSuite{
+			Name:      r.ScriptPath,
+			Time:      r.End.Sub(r.Start).Seconds(),
+			Timestamp: r.Start.Format(time.RFC3339),
+		}
+		if r.Err != nil && !errors.Is(r.Err, ErrThresholdsCrossed) {
+			suite.Cases = append(suite.Cases, junitTestCase{
+				ClassName: "run",
+				Name:      r.RunId,
+				Error:     &junitFailure{Message: r.ErrorMessage(), Type: "error", Contents: r.LogExcerpt()},
+			})
+			suite.Errors++
+		}
+		if r.Summary != nil {
+			for _, name := range r.Summary.MetricNames() {
+				m := r.Summary.Metrics[name]
+				for _, expr := range sortedKeys(m.Thresholds) {
+					tc := junitTestCase{ClassName: "thresholds." + name, Name: expr}
+					if !m.Thresholds[expr].OK {
+						tc.Failure = &junitFailure{
+							Message:  fmt.Sprintf("threshold '%s' crossed: %s", expr, m.FormatValues()),
+							Type:     "threshold",
+							Contents: r.LogExcerpt(),
+						}
+						suite.Failures++
+					}
+					suite.Cases = append(suite.Cases, tc)
+				}
+			}
+			for _, check := range r.Summary.Checks() {
+				tc := junitTestCase{ClassName: "checks", Name: strings.TrimPrefix(check.Path, "::")}
+				if check.Fails > 0 {
+					tc.Failure = &junitFailure{
+						Message:  fmt.Sprintf("%d of %d checks failed", check.Fails, check.Passes+check.Fails),
+						Type:     "check",
+						Contents: r.LogExcerpt(),
+					}
+					suite.Failures++
+				}
+				suite.Cases = append(suite.Cases, tc)
+			}
+		}
+		suite.Tests = len(suite.Cases)
+		if r.Failed() {
+			suite.SystemOut = r.LogExcerpt()
+		}
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+		suites.Suites = append(suites.Suites, suite)
+	}
+	out, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append([]byte(xml.Header), append(out, '\n')...), 0644)
+}
+
+// WriteMarkdownReport appends a Markdown report to the file, so it can be used with `$GITHUB_STEP_SUMMARY`.
+func WriteMarkdownReport(path string, reports []*RunReport) error {
+	var sb strings.Builder
+	for _, r := range reports {
+		status := "✅ passed"
+		if r.Failed() {
+			status = "❌ failed"
+		}
+		sb.WriteString(fmt.Sprintf("## k6: `%s` %s\n\n", r.ScriptPath, status))
+		sb.WriteString(fmt.Sprintf("Run ID `%s`, started %s, took %s.\n\n", r.RunId, r.Start.Format(time.RFC3339), r.End.Sub(r.Start).Round(time.Second)))
+		if r.Err != nil {
+			sb.WriteString(fmt.Sprintf("**Error:** %s\n\n", markdownEscape(r.ErrorMessage())))
+		}
+		if r.Summary != nil {
+			writeMarkdownSummary(&sb, r.Summary)
+		}
+		if r.Failed() && len(r.RunnerLogs) > 0 {
+			sb.WriteString("<details><summary>Logs</summary>\n\n```\n")
+			sb.WriteString(r.LogExcerpt())
+			sb.WriteString("```\n\n</details>\n\n")
+		}
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(sb.String())
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func writeMarkdownSummary(sb *strings.Builder, s *Summary) {
+	var thresholds []string
+	for _, name := range s.MetricNames() {
+		m := s.Metrics[name]
+		for _, expr := range sortedKeys(m.Thresholds) {
+			mark := "✅"
+			if !m.Thresholds[expr].OK {
+				mark = "❌"
+			}
+			thresholds = append(thresholds, fmt.Sprintf("| %s | `%s` | `%s` | %s |\n", mark, name, markdownEscape(expr), markdownEscape(m.FormatValues())))
+		}
+	}
+	if len(thresholds) > 0 {
+		sb.WriteString("### Thresholds\n\n|  | Metric | Threshold | Values |\n|---|---|---|---|\n")
+		sb.WriteString(strings.Join(thresholds, ""))
+		sb.WriteString("\n")
+	}
+	if checks := s.Checks(); len(checks) > 0 {
+		sb.WriteString("### Checks\n\n|  | Check | Passes | Fails |\n|---|---|---|---|\n")
+		for _, check := range checks {
+			mark := "✅"
+			if check.Fails > 0 {
+				mark = "❌"
+			}
+			sb.WriteString(fmt.Sprintf("| %s | %s | %d | %d |\n", mark, markdownEscape(strings.TrimPrefix(check.Path, "::")), check.Passes, check.Fails))
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("### Metrics\n\n| Metric | Values |\n|---|---|\n")
+	for _, name := range s.MetricNames() {
+		sb.WriteString(fmt.Sprintf("| `%s` | %s |\n", name, markdownEscape(s.Metrics[name].FormatValues())))
+	}
+	sb.WriteString("\n")
+}
+
+func markdownEscape(s string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(s)
+}
@@ -0,0 +1,116 @@
+package internal
+
+import (
+	"html/template"
+	"os"
+	"strings"
+	"time"
+)
+
+var htmlReportTemplate = template.Must(template.New("htmlReport").Funcs(template.FuncMap{
+	"duration": func(d time.Duration) string {
+		return d.Round(time.Millisecond).String()
+	},
+	"time": func(t time.Time) string {
+		return t.Format(time.RFC3339)
+	},
+	"stripSummary": StripSummary,
+	"sortedEnv": func(env K6Environment) []string {
+		return sortedKeys(env)
+	},
+	"upper": strings.ToUpper,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>k6 report: {{.ScriptPath}} ({{.RunId}})</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+th { background: #f3f3f3; }
+pre { background: #f7f7f7; padding: 1em; overflow-x: auto; max-height: 40em; }
+.ok { color: #1a7f37; }
+.fail { color: #cf222e; }
+</style>
+</head>
+<body>
+<h1>{{.ScriptPath}} {{if .Failed}}<span class="fail">failed</span>{{else}}<span class="ok">passed</span>{{end}}</h1>
+{{if .Err}}<p class="fail"><strong>Error:</strong> {{.ErrorMessage}}</p>{{end}}
+
+<h2>Run</h2>
+<table>
+<tr><th>Script</th><td>{{.ScriptPath}}</td></tr>
+<tr><th>Run ID</th><td>{{.RunId}}</td></tr>
+<tr><th>Image</th><td>{{if .Image}}{{.Image}}{{else}}operator default{{end}}</td></tr>
+<tr><th>Parallelism</th><td>{{.Parallelism}}</td></tr>
+<tr><th>Arguments</th><td><code>{{.Arguments}}</code></td></tr>
+<tr><th>Started</th><td>{{time .Start}}</td></tr>
+<tr><th>Finished</th><td>{{time .End}}</td></tr>
+</table>
+
+{{if .Env}}
+<h2>Environment</h2>
+<table>
+{{range $k := sortedEnv .Env}}<tr><th>{{upper $k}}</th><td><code>{{index $.Env $k}}</code></td></tr>
+{{end}}</table>
+{{end}}
+
+{{if .Stages}}
+<h2>Stages</h2>
+<table>
+<tr><th>Stage</th><th>Started</th><th>Duration</th></tr>
+{{range .Stages}}<tr><td>{{.Name}}</td><td>{{time .Start}}</td><td>{{duration .Duration}}</td></tr>
+{{end}}</table>
+{{end}}
+
+{{with .Summary}}
+<h2>Summary of {{.Runners}} runner(s)</h2>
+{{with .Checks}}
+<h3>Checks</h3>
+<table>
+<tr><th></th><th>Check</th><th>Passes</th><th>Fails</th></tr>
+{{range .}}<tr><td>{{if .Fails}}<span class="fail">✗</span>{{else}}<span class="ok">✓</span>{{end}}</td><td>{{.Path}}</td><td>{{.Passes}}</td><td>{{.Fails}}</td></tr>
+{{end}}</table>
+{{end}}
+<h3>Metrics</h3>
+<table>
+<tr><th>Metric</th><th>Values</th><th>Thresholds</th></tr>
+{{range $name := .MetricNames}}{{with index $.Summary.Metrics $name}}<tr><td>{{$name}}</td><td>{{.FormatValues}}</td><td>{{range $expr, $result := .Thresholds}}{{if $result.OK}}<span class="ok">✓ {{$expr}}</span>{{else}}<span class="fail">✗ {{$expr}}</span>{{end}}<br>{{end}}</td></tr>
+{{end}}{{end}}</table>
+{{end}}
+
+{{if .OperatorLogs}}
+<h2>Operator Warnings</h2>
+<pre>{{.OperatorLogs}}</pre>
+{{end}}
+
+{{if .RunnerLogs}}
+<h2>Runner Logs</h2>
+{{range .RunnerLogs}}
+<details{{if $.Failed}} open{{end}}>
+<summary>{{.PodName}}</summary>
+<pre>{{stripSummary .Logs}}</pre>
+</details>
+{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+// WriteHTMLReport writes a self-contained HTML file with the metadata, summary and logs of the run.
+// Only the first report is rendered, since one HTML file describes one run.
+func WriteHTMLReport(path string, reports []*RunReport) error {
+	if len(reports) == 0 {
+		return nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = htmlReportTemplate.Execute(f, reports[0])
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
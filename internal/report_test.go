@@ -0,0 +1,96 @@
+package internal_test
+
+import (
+	"fmt"
+	"github.com/brodo/kubectl-k6/internal"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReports(t *testing.T) {
+	summary := internal.MergeSummaries([]*internal.Summary{runnerSummary(t, 600, 100, 200, 20, 900, 400, 5)})
+	report := &internal.RunReport{
+		ScriptPath: "liveness.js",
+		RunId:      "l4q5ph7vsplt2pxkkv4l",
+		Start:      time.Now().Add(-time.Minute),
+		End:        time.Now(),
+		Summary:    summary,
+		RunnerLogs: []internal.LogsWithNames{{PodName: "run-l4q5ph7vsplt2pxkkv4l-1", Logs: fmt.Sprintf(runnerLog, 1, 1, 0, 1, true, 1, 1, 1, 1, true, 1, 0)}},
+		Err:        fmt.Errorf("%w: 1 threshold(s) failed across all runners", internal.ErrThresholdsCrossed),
+	}
+	dir := t.TempDir()
+
+	target, err := internal.ParseReportTarget("junit=" + filepath.Join(dir, "report.xml"))
+	require.NoError(t, err)
+	require.NoError(t, target.Write([]*internal.RunReport{report}))
+	junit, err := os.ReadFile(target.Path)
+	require.NoError(t, err)
+	require.Contains(t, string(junit), `<testsuite name="liveness.js" tests="3" failures="3" errors="0"`)
+	require.Contains(t, string(junit), `<testcase classname="thresholds.http_req_duration" name="p(95)&lt;300"`)
+	require.Contains(t, string(junit), `level=info msg=&#34;running&#34;`)
+	require.NotContains(t, string(junit), "K6K8S_SUMMARY")
+
+	target, err = internal.ParseReportTarget("markdown=" + filepath.Join(dir, "report.md"))
+	require.NoError(t, err)
+	require.NoError(t, target.Write([]*internal.RunReport{report}))
+	require.NoError(t, target.Write([]*internal.RunReport{report}))
+	markdown, err := os.ReadFile(target.Path)
+	require.NoError(t, err)
+	require.Contains(t, string(markdown), "## k6: `liveness.js` ❌ failed")
+	require.Contains(t, string(markdown), "| ❌ | `http_req_duration` | `p(95)<300` |")
+
+	redactor, err := internal.NewRedactor(nil, nil)
+	require.NoError(t, err)
+	report.Env = redactor.RedactEnv(internal.K6Environment{"TARGET": "http://example.com", "CLIENT_SECRET": "hunter2", "base_url": "http://example.com/api"})
+	report.StartStage("run")
+	report.EndStage()
+	target, err = internal.ParseReportTarget("html=" + filepath.Join(dir, "report.html"))
+	require.NoError(t, err)
+	require.NoError(t, target.Write([]*internal.RunReport{report}))
+	html, err := os.ReadFile(target.Path)
+	require.NoError(t, err)
+	require.Contains(t, string(html), "<td>l4q5ph7vsplt2pxkkv4l</td>")
+	require.Contains(t, string(html), "<td>run</td>")
+	// the names are shown as the runners receive them
+	require.Contains(t, string(html), "<th>BASE_URL</th>")
+	require.Contains(t, string(html), "[REDACTED]")
+	require.NotContains(t, string(html), "hunter2")
+	require.NotContains(t, string(html), "K6K8S_SUMMARY")
+
+	_, err = internal.ParseReportTarget("pdf=report.pdf")
+	require.Error(t, err)
+}
+
+func TestReportsOfFailedJobs(t *testing.T) {
+	report := &internal.RunReport{
+		ScriptPath: "liveness.js",
+		RunId:      "l4q5ph7vsplt2pxkkv4l",
+		Start:      time.Now().Add(-time.Minute),
+		End:        time.Now(),
+		RunnerLogs: []internal.LogsWithNames{{PodName: "run-l4q5ph7vsplt2pxkkv4l-1-x7k2p/k6", Logs: "level=error msg=\"script error\"\n"}},
+		Err:        fmt.Errorf("job 'run-l4q5ph7vsplt2pxkkv4l-1' failed (the k6 container exited with code 107), logs:\n--- attempt 1 ---\nlevel=error msg=\"script error\"\n"),
+	}
+	require.Equal(t, "job 'run-l4q5ph7vsplt2pxkkv4l-1' failed (the k6 container exited with code 107)", report.ErrorMessage())
+	dir := t.TempDir()
+
+	target, err := internal.ParseReportTarget("junit=" + filepath.Join(dir, "report.xml"))
+	require.NoError(t, err)
+	require.NoError(t, target.Write([]*internal.RunReport{report}))
+	junit, err := os.ReadFile(target.Path)
+	require.NoError(t, err)
+	require.Contains(t, string(junit), `<error message="job &#39;run-l4q5ph7vsplt2pxkkv4l-1&#39; failed (the k6 container exited with code 107)" type="error">`)
+	require.Contains(t, string(junit), "--- run-l4q5ph7vsplt2pxkkv4l-1-x7k2p/k6 ---")
+	require.NotContains(t, string(junit), "attempt 1")
+
+	target, err = internal.ParseReportTarget("markdown=" + filepath.Join(dir, "report.md"))
+	require.NoError(t, err)
+	require.NoError(t, target.Write([]*internal.RunReport{report}))
+	markdown, err := os.ReadFile(target.Path)
+	require.NoError(t, err)
+	require.Contains(t, string(markdown), "**Error:** job 'run-l4q5ph7vsplt2pxkkv4l-1' failed (the k6 container exited with code 107)\n\n")
+	require.Contains(t, string(markdown), "<details><summary>Logs</summary>")
+	require.NotContains(t, string(markdown), "attempt 1")
+}
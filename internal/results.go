@@ -0,0 +1,187 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gobeam/stringy"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// ResultLabel is set on all ConfigMaps that contain the results record of a run.
+	ResultLabel      = "k6k8s.io/result"
+	runIdLabel       = "k6k8s.io/run-id"
+	outcomeLabel     = "k6k8s.io/outcome"
+	scriptLabel      = "k6k8s.io/script"
+	resultConfigKey  = "result.json"
+	resultNamePrefix = "k6-result-"
+	// maxResultErrorLength keeps the error of a record short, the outcome classifies it already.
+	maxResultErrorLength = 500
+)
+
+// ResultRecord is the compact record of a completed run that is stored in the cluster, so teammates can
+// list and compare runs started from other machines. Unlike the local history it contains no configuration,
+// since that may contain secrets.
+type ResultRecord struct {
+	RunId      string    `json:"runId"`
+	ScriptPath string    `json:"scriptPath"`
+	ScriptHash string    `json:"scriptHash,omitempty"`
+	GitCommit  string    `json:"gitCommit,omitempty"`
+	User       string    `json:"user"`
+	Namespace  string    `json:"namespace"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	Outcome    string    `json:"outcome"`
+	Error      string    `json:"error,omitempty"`
+	Summary    *Summary  `json:"summary,omitempty"`
+}
+
+// NewResultRecord creates the results record from the history record of a run.
+func NewResultRecord(record HistoryRecord, namespace string) ResultRecord {
+	return ResultRecord{
+		RunId:      record.RunId,
+		ScriptPath: record.ScriptPath,
+		ScriptHash: record.ScriptHash,
+		GitCommit:  record.GitCommit,
+		User:       currentUser(),
+		Namespace:  namespace,
+		Start:      record.Start,
+		End:        record.End,
+		Outcome:    record.Outcome,
+		Error:      resultError(record.Error),
+		Summary:    record.Summary,
+	}
+}
+
+// resultError returns the first line of the error, truncated. The errors of failed jobs continue with the logs of
+// all attempts, which would make the record too large for a ConfigMap.
+func resultError(msg string) string {
+	msg = errorHeadline(msg)
+	if len(msg) > maxResultErrorLength {
+		msg = strings.ToValidUTF8(msg[:maxResultErrorLength-3], "") + "..."
+	}
+	return msg
+}
+
+func currentUser() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		name += "@" + host
+	}
+	return name
+}
+
+var invalidLabelChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// labelValue turns an arbitrary string into a valid label value.
+func labelValue(s string) string {
+	s = invalidLabelChars.ReplaceAllString(s, "-")
+	if len(s) > 63 {
+		s = s[len(s)-63:]
+	}
+	return strings.Trim(s, "-_.")
+}
+
+// SaveResult stores the results record of a run in a labeled ConfigMap.
+func (kc *K8sClient) SaveResult(ctx context.Context, record ResultRecord) error {
+	script := filepath.Base(record.ScriptPath)
+	script = stringy.New(strings.TrimSuffix(script, filepath.Ext(script))).KebabCase().Get()
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return kc.ApplyConfigMap(ctx, &v1.ConfigMap{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      resultNamePrefix + record.RunId,
+			Namespace: kc.namespace,
+			Labels: map[string]string{
+				ResultLabel:  "true",
+				runIdLabel:   record.RunId,
+				outcomeLabel: record.Outcome,
+				scriptLabel:  labelValue(script),
+			},
+		},
+		Data: map[string]string{resultConfigKey: string(data)},
+	})
+}
+
+// GetResult loads the results record of a run.
+func (kc *K8sClient) GetResult(ctx context.Context, runId string) (ResultRecord, error) {
+	cm, err := kc.GetConfigMap(ctx, resultNamePrefix+runId)
+	if errors.IsNotFound(err) {
+		return ResultRecord{}, fmt.Errorf("there are no results for run '%s' in namespace '%s'", runId, kc.namespace)
+	}
+	if err != nil {
+		return ResultRecord{}, err
+	}
+	return parseResult(cm)
+}
+
+// ListResults returns the results records matching the filters, most recent first. The script filter is the name
+// of the script without its extension. Empty filters match all runs. Config maps that do not contain a valid record
+// are skipped and passed to warn.
+func (kc *K8sClient) ListResults(ctx context.Context, script, outcome string, warn func(string)) ([]ResultRecord, error) {
+	selector := ResultLabel + "=true"
+	if script != "" {
+		selector += fmt.Sprintf(",%s=%s", scriptLabel, labelValue(stringy.New(script).KebabCase().Get()))
+	}
+	if outcome != "" {
+		selector += fmt.Sprintf(",%s=%s", outcomeLabel, outcome)
+	}
+	cms, err := kc.clientSet.CoreV1().ConfigMaps(kc.namespace).List(ctx, meta.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	records := make([]ResultRecord, 0, len(cms.Items))
+	for i := range cms.Items {
+		record, err := parseResult(&cms.Items[i])
+		if err != nil {
+			warn(fmt.Sprintf("skipping %v", err))
+			continue
+		}
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Start.After(records[j].Start)
+	})
+	return records, nil
+}
+
+// PruneResults deletes the oldest results records, so at most keep records are left, and returns how many it
+// deleted. Config maps that do not contain a valid record are kept and passed to warn.
+func (kc *K8sClient) PruneResults(ctx context.Context, keep int, warn func(string)) (int, error) {
+	records, err := kc.ListResults(ctx, "", "", warn)
+	if err != nil || len(records) <= keep {
+		return 0, err
+	}
+	deleted := 0
+	for _, r := range records[keep:] {
+		err := kc.clientSet.CoreV1().ConfigMaps(kc.namespace).Delete(ctx, resultNamePrefix+r.RunId, meta.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+func parseResult(cm *v1.ConfigMap) (ResultRecord, error) {
+	var record ResultRecord
+	if err := json.Unmarshal([]byte(cm.Data[resultConfigKey]), &record); err != nil {
+		return ResultRecord{}, fmt.Errorf("the config map '%s' does not contain a valid results record: %w", cm.Name, err)
+	}
+	return record, nil
+}
@@ -0,0 +1,116 @@
+package internal_test
+
+import (
+	"context"
+	"github.com/brodo/kubectl-k6/internal"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSaveAndListResults(t *testing.T) {
+	ctx := context.Background()
+	malformed := &v1.ConfigMap{
+		ObjectMeta: meta.ObjectMeta{Name: "k6-result-broken", Namespace: "results", Labels: map[string]string{internal.ResultLabel: "true"}},
+		Data:       map[string]string{"result.json": "{"},
+	}
+	kc := internal.NewFakeK8sClient("results", malformed)
+	start := time.Date(2024, 5, 3, 10, 0, 0, 0, time.UTC)
+	records := []internal.ResultRecord{
+		{RunId: "a", ScriptPath: "tests/checkout.js", Outcome: "passed", Start: start, End: start.Add(time.Minute)},
+		{RunId: "b", ScriptPath: "tests/checkout.js", Outcome: "thresholds-failed", Start: start.Add(time.Hour)},
+		{RunId: "c", ScriptPath: "tests/SearchProducts.ts", Outcome: "passed", Start: start.Add(2 * time.Hour)},
+	}
+	for _, r := range records {
+		require.NoError(t, kc.SaveResult(ctx, r))
+	}
+	// saving a record again replaces it
+	require.NoError(t, kc.SaveResult(ctx, records[0]))
+
+	var warnings []string
+	warn := func(msg string) { warnings = append(warnings, msg) }
+	all, err := kc.ListResults(ctx, "", "", warn)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c", "b", "a"}, runIds(all))
+	require.Equal(t, records[0], all[2])
+	require.Len(t, warnings, 1)
+	require.Contains(t, warnings[0], "k6-result-broken")
+
+	checkout, err := kc.ListResults(ctx, "checkout", "", warn)
+	require.NoError(t, err)
+	require.Equal(t, []string{"b", "a"}, runIds(checkout))
+	search, err := kc.ListResults(ctx, "SearchProducts", "passed", warn)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c"}, runIds(search))
+	passed, err := kc.ListResults(ctx, "checkout", "passed", warn)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a"}, runIds(passed))
+
+	record, err := kc.GetResult(ctx, "b")
+	require.NoError(t, err)
+	require.Equal(t, records[1], record)
+	_, err = kc.GetResult(ctx, "missing")
+	require.ErrorContains(t, err, "there are no results for run 'missing' in namespace 'results'")
+	_, err = kc.GetResult(ctx, "broken")
+	require.ErrorContains(t, err, "does not contain a valid results record")
+}
+
+func TestPruneResults(t *testing.T) {
+	ctx := context.Background()
+	kc := internal.NewFakeK8sClient("results")
+	start := time.Date(2024, 5, 3, 10, 0, 0, 0, time.UTC)
+	for i, id := range []string{"a", "b", "c", "d"} {
+		require.NoError(t, kc.SaveResult(ctx, internal.ResultRecord{RunId: id, Start: start.Add(time.Duration(i) * time.Hour)}))
+	}
+	warn := func(msg string) { t.Errorf("unexpected warning: %s", msg) }
+	deleted, err := kc.PruneResults(ctx, 5, warn)
+	require.NoError(t, err)
+	require.Zero(t, deleted)
+	deleted, err = kc.PruneResults(ctx, 2, warn)
+	require.NoError(t, err)
+	require.Equal(t, 2, deleted)
+	kept, err := kc.ListResults(ctx, "", "", warn)
+	require.NoError(t, err)
+	require.Equal(t, []string{"d", "c"}, runIds(kept))
+}
+
+func TestNewResultRecord(t *testing.T) {
+	logs := strings.Repeat("level=info msg=\"iteration\"\n", 50000)
+	history := internal.HistoryRecord{
+		RunId:   "l4q5ph7vsplt2pxkkv4l",
+		Outcome: internal.OutcomeError,
+		Error:   "job 'run-l4q5ph7vsplt2pxkkv4l-1' failed (the k6 container exited with code 107), logs:\n" + logs,
+	}
+	record := internal.NewResultRecord(history, "k6-operator-system")
+	require.Equal(t, internal.OutcomeError, record.Outcome)
+	require.Equal(t, "job 'run-l4q5ph7vsplt2pxkkv4l-1' failed (the k6 container exited with code 107)", record.Error)
+
+	history.Error = strings.Repeat("x", 2000)
+	record = internal.NewResultRecord(history, "k6-operator-system")
+	require.Len(t, record.Error, 500)
+	require.True(t, strings.HasSuffix(record.Error, "..."))
+	kc := internal.NewFakeK8sClient("results")
+	require.NoError(t, kc.SaveResult(context.Background(), record))
+}
+
+func TestLabelValue(t *testing.T) {
+	require.Equal(t, "my-script", internal.LabelValue("my script"))
+	require.Equal(t, "a-b", internal.LabelValue("_a/b."))
+	long := strings.Repeat("x", 60) + "-end-of-name"
+	value := internal.LabelValue(long)
+	require.Len(t, value, 63)
+	// the end of long names is kept, since it differs more often than the beginning
+	require.True(t, strings.HasSuffix(value, "-end-of-name"))
+	require.Equal(t, "end", internal.LabelValue(strings.Repeat("-", 61)+"end"))
+}
+
+func runIds(records []internal.ResultRecord) []string {
+	ids := make([]string, len(records))
+	for i, r := range records {
+		ids[i] = r.RunId
+	}
+	return ids
+}
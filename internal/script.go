@@ -7,6 +7,7 @@ import (
 	"github.com/spf13/cobra"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 type ScriptProperties struct {
@@ -17,9 +18,14 @@ type ScriptProperties struct {
 	ScriptWOExt      string
 	ScriptWOExtKebab string
 	RunId            string
+	// Name is the name of the custom resource if it is set by a pre-authored manifest.
+	Name string
 }
 
 func (sp *ScriptProperties) ResourceName() string {
+	if sp.Name != "" {
+		return sp.Name
+	}
 	return "run-" + sp.RunId
 }
 
@@ -27,13 +33,17 @@ func (sp *ScriptProperties) ConfigMapName() string {
 	return sp.RunId
 }
 
-func (sp *ScriptProperties) RunnerJobName(idx int) string {
-	return fmt.Sprintf("%s-%d", sp.ResourceName(), idx+1)
-}
 func (sp *ScriptProperties) InitJobName() string {
 	return fmt.Sprintf("%s-initializer", sp.ResourceName())
 }
 
+func (sp *ScriptProperties) EnvSecretName() string {
+	return fmt.Sprintf("%s-env", sp.ResourceName())
+}
+
+// runIdLength is the length of the generated run ids.
+const runIdLength = 20
+
 func NewScriptProperties(scriptPath string) ScriptProperties {
 	dir, script := filepath.Split(scriptPath)
 	if dir == "" {
@@ -46,7 +56,7 @@ func NewScriptProperties(scriptPath string) ScriptProperties {
 	cwd, err := os.Getwd()
 	cobra.CheckErr(err)
 	cwd = filepath.Base(cwd)
-	scriptWoExt := script[0 : len(script)-3]
+	scriptWoExt := strings.TrimSuffix(script, filepath.Ext(script))
 	scriptWoExtKebab := stringy.New(scriptWoExt).KebabCase().Get()
 	return ScriptProperties{
 		Cwd:              cwd,
@@ -55,6 +65,6 @@ func NewScriptProperties(scriptPath string) ScriptProperties {
 		ScriptPath:       scriptPath,
 		ScriptWOExt:      scriptWoExt,
 		ScriptWOExtKebab: scriptWoExtKebab,
-		RunId:            utils.RandomString(20),
+		RunId:            utils.RandomString(runIdLength),
 	}
 }
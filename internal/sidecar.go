@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SidecarOptions configure how runner pods with sidecars, e.g. the proxy of a service mesh, are handled.
+type SidecarOptions struct {
+	// Quit shuts the sidecars down after k6 exited, so the jobs complete.
+	Quit bool
+	// QuitCommands are the commands that shut down the sidecars, by container name.
+	QuitCommands map[string][]string
+}
+
+// DefaultSidecarQuitCommands shut down the sidecars of common service meshes through their quit endpoints.
+var DefaultSidecarQuitCommands = map[string][]string{
+	"istio-proxy": {"pilot-agent", "request", "POST", "quitquitquit"},
+}
+
+// SetSidecarOptions configures how the waits handle runner pods with sidecars.
+func (kc *K8sClient) SetSidecarOptions(opts SidecarOptions) {
+	kc.sidecars = opts
+}
+
+// K6Exited reports whether the k6 container of the pod terminated while other containers, the sidecars, are still
+// running and keep the pod and its job from completing. It returns the exit code of k6 and the running sidecars.
+func K6Exited(pod v1.Pod) (exitCode int32, sidecars []string, ok bool) {
+	var k6 *v1.ContainerStateTerminated
+	for _, status := range pod.Status.ContainerStatuses {
+		switch {
+		case status.Name == k6ContainerName:
+			k6 = status.State.Terminated
+		case status.State.Running != nil:
+			sidecars = append(sidecars, status.Name)
+		}
+	}
+	if k6 == nil || len(sidecars) == 0 {
+		return 0, nil, false
+	}
+	return k6.ExitCode, sidecars, true
+}
+
+// lastJobPod returns the pod of the job's last attempt.
+func (kc *K8sClient) lastJobPod(ctx context.Context, jobName string) (v1.Pod, bool) {
+	pods, err := kc.getJobPods(ctx, jobName)
+	if err != nil || len(pods) == 0 {
+		return v1.Pod{}, false
+	}
+	return pods[len(pods)-1], true
+}
+
+// QuitSidecars runs the quit commands of the sidecars in the pod. Sidecars without a quit command are reported in
+// the error.
+func (kc *K8sClient) QuitSidecars(ctx context.Context, pod string, sidecars []string) error {
+	var problems []string
+	for _, sidecar := range sidecars {
+		command, ok := kc.sidecars.QuitCommands[sidecar]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("no quit command for sidecar '%s'", sidecar))
+			continue
+		}
+		if output, err := kc.Exec(ctx, pod, sidecar, command); err != nil {
+			problems = append(problems, fmt.Sprintf("'%s' in sidecar '%s' failed: %v %s", strings.Join(command, " "), sidecar, err, output))
+		}
+	}
+	if len(problems) > 0 {
+		slices.Sort(problems)
+		return fmt.Errorf("could not shut down the sidecars of pod '%s': %s", pod, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Exec runs a command in a container and returns its combined output.
+func (kc *K8sClient) Exec(ctx context.Context, pod, container string, command []string) (string, error) {
+	req := kc.clientSet.CoreV1().RESTClient().Post().Namespace(kc.namespace).Resource("pods").Name(pod).SubResource("exec").
+		VersionedParams(&v1.PodExecOptions{Container: container, Command: command, Stdout: true, Stderr: true}, scheme.ParameterCodec)
+	executor, err := remotecommand.NewSPDYExecutor(kc.restConfig, "POST", req.URL())
+	if err != nil {
+		return "", err
+	}
+	execCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	var output bytes.Buffer
+	err = executor.StreamWithContext(execCtx, remotecommand.StreamOptions{Stdout: &output, Stderr: &output})
+	return strings.TrimSpace(output.String()), err
+}
@@ -0,0 +1,30 @@
+package internal_test
+
+import (
+	"github.com/brodo/kubectl-k6/internal"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"testing"
+)
+
+func TestK6Exited(t *testing.T) {
+	running := v1.ContainerState{Running: &v1.ContainerStateRunning{}}
+	exited := v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: 99}}
+	pod := v1.Pod{Status: v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{
+		{Name: "k6", State: running},
+		{Name: "istio-proxy", State: running},
+	}}}
+	_, _, ok := internal.K6Exited(pod)
+	require.False(t, ok)
+
+	pod.Status.ContainerStatuses[0].State = exited
+	code, sidecars, ok := internal.K6Exited(pod)
+	require.True(t, ok)
+	require.Equal(t, int32(99), code)
+	require.Equal(t, []string{"istio-proxy"}, sidecars)
+
+	// without running sidecars the job completes by itself
+	pod.Status.ContainerStatuses[1].State = v1.ContainerState{Terminated: &v1.ContainerStateTerminated{}}
+	_, _, ok = internal.K6Exited(pod)
+	require.False(t, ok)
+}
@@ -0,0 +1,460 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	summaryBeginMarker = "K6K8S_SUMMARY_BEGIN"
+	summaryEndMarker   = "K6K8S_SUMMARY_END"
+)
+
+// ErrThresholdsCrossed is returned when the merged summary contains failed thresholds.
+var ErrThresholdsCrossed = errors.New("thresholds crossed")
+
+// Summary is the machine-readable end-of-test summary k6 passes to `handleSummary`.
+// Only the parts the plugin aggregates are modeled.
+type Summary struct {
+	Metrics   map[string]*SummaryMetric `json:"metrics"`
+	RootGroup *SummaryGroup             `json:"root_group,omitempty"`
+	State     SummaryState              `json:"state"`
+	// Runners is the number of runner summaries that were merged into this one.
+	Runners int `json:"runners,omitempty"`
+}
+
+type SummaryMetric struct {
+	Type       string                     `json:"type"`
+	Contains   string                     `json:"contains"`
+	Values     map[string]float64         `json:"values"`
+	Thresholds map[string]ThresholdResult `json:"thresholds,omitempty"`
+}
+
+type ThresholdResult struct {
+	OK bool `json:"ok"`
+}
+
+type SummaryGroup struct {
+	Name   string          `json:"name"`
+	Path   string          `json:"path"`
+	ID     string          `json:"id"`
+	Groups []*SummaryGroup `json:"groups"`
+	Checks []*SummaryCheck `json:"checks"`
+}
+
+type SummaryCheck struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	ID     string `json:"id"`
+	Passes int64  `json:"passes"`
+	Fails  int64  `json:"fails"`
+}
+
+type SummaryState struct {
+	TestRunDurationMs float64 `json:"testRunDurationMs"`
+}
+
+// FailedThreshold identifies a threshold that did not pass.
+type FailedThreshold struct {
+	Metric     string
+	Expression string
+}
+
+// ParseSummaryFromLogs extracts the summary the injected `handleSummary` printed to a runner's log.
+func ParseSummaryFromLogs(logs string) (*Summary, error) {
+	begin := strings.LastIndex(logs, summaryBeginMarker)
+	if begin == -1 {
+		return nil, fmt.Errorf("the logs do not contain a k6 summary")
+	}
+	rest := logs[begin+len(summaryBeginMarker):]
+	end := strings.Index(rest, summaryEndMarker)
+	if end == -1 {
+		return nil, fmt.Errorf("the k6 summary in the logs is truncated")
+	}
+	var summary Summary
+	if err := json.Unmarshal([]byte(rest[:end]), &summary); err != nil {
+		return nil, fmt.Errorf("could not parse the k6 summary: %w", err)
+	}
+	summary.Runners = 1
+	return &summary, nil
+}
+
+// StripSummary removes the injected summary from runner logs so they can be shown to the user.
+func StripSummary(logs string) string {
+	var sb strings.Builder
+	for _, line := range strings.SplitAfter(logs, "\n") {
+		if !IsSummaryLine(line) {
+			sb.WriteString(line)
+		}
+	}
+	return sb.String()
+}
+
+// IsSummaryLine reports whether a log line is part of the injected summary.
+func IsSummaryLine(line string) bool {
+	return strings.Contains(line, summaryBeginMarker) || strings.Contains(line, summaryEndMarker)
+}
+
+// MergeSummaries combines the summaries of several runners of the same test run.
+// Counters and rates are merged exactly. Trend medians and percentiles cannot be reconstructed from
+// per-runner values, so the highest value of any runner is used, an upper bound of the combined value that
+// a single slow runner cannot be averaged away from. Trend averages are approximated by their mean across
+// runners. Thresholds are re-evaluated against the merged values; trend thresholds on other statistics than
+// percentiles, the median or the maximum only pass if they passed on every runner.
+func MergeSummaries(summaries []*Summary) *Summary {
+	merged := &Summary{Metrics: map[string]*SummaryMetric{}}
+	metricRunners := map[string]int{}
+	// the highest value of every trend statistic across runners
+	trendMax := map[string]map[string]float64{}
+	for _, s := range summaries {
+		if s == nil {
+			continue
+		}
+		merged.Runners += max(s.Runners, 1)
+		merged.State.TestRunDurationMs = math.Max(merged.State.TestRunDurationMs, s.State.TestRunDurationMs)
+		merged.RootGroup = mergeGroups(merged.RootGroup, s.RootGroup)
+		for name, m := range s.Metrics {
+			metricRunners[name]++
+			if m.Type == "trend" {
+				if trendMax[name] == nil {
+					trendMax[name] = map[string]float64{}
+				}
+				for k, v := range m.Values {
+					if prev, ok := trendMax[name][k]; !ok || v > prev {
+						trendMax[name][k] = v
+					}
+				}
+			}
+			target, ok := merged.Metrics[name]
+			if !ok {
+				target = &SummaryMetric{Type: m.Type, Contains: m.Contains, Values: map[string]float64{}, Thresholds: map[string]ThresholdResult{}}
+				for k, v := range m.Values {
+					target.Values[k] = v
+				}
+				for k, v := range m.Thresholds {
+					target.Thresholds[k] = v
+				}
+				merged.Metrics[name] = target
+				continue
+			}
+			mergeMetricValues(target, m)
+			for k, v := range m.Thresholds {
+				prev, seen := target.Thresholds[k]
+				target.Thresholds[k] = ThresholdResult{OK: v.OK && (!seen || prev.OK)}
+			}
+		}
+	}
+	for name, m := range merged.Metrics {
+		if m.Type == "trend" {
+			n := float64(metricRunners[name])
+			for k, v := range m.Values {
+				switch {
+				case upperBoundStat(k):
+					m.Values[k] = trendMax[name][k]
+				case k != "min":
+					m.Values[k] = v / n
+				}
+			}
+		}
+		if m.Type == "rate" {
+			total := m.Values["passes"] + m.Values["fails"]
+			if total > 0 {
+				m.Values["rate"] = m.Values["passes"] / total
+			}
+		}
+		for expr, prev := range m.Thresholds {
+			if m.Type == "trend" {
+				m.Thresholds[expr] = recheckTrendThreshold(expr, prev, m.Values)
+			} else if ok, err := EvaluateThreshold(expr, m.Values); err == nil {
+				m.Thresholds[expr] = ThresholdResult{OK: ok}
+			}
+		}
+	}
+	return merged
+}
+
+// upperBoundStat reports whether the merged value of a trend statistic is the highest value of any runner.
+func upperBoundStat(stat string) bool {
+	return stat == "max" || stat == "med" || strings.HasPrefix(stat, "p(")
+}
+
+// recheckTrendThreshold re-evaluates a threshold of a merged trend. The percentiles, the median and the maximum
+// are upper bounds, so their thresholds are decided by the merged values. The merged averages are means across
+// runners, which can hide a runner that crossed the threshold, so these thresholds fail if any runner failed.
+func recheckTrendThreshold(expr string, prev ThresholdResult, values map[string]float64) ThresholdResult {
+	match := thresholdRegex.FindStringSubmatch(expr)
+	if match == nil {
+		return prev
+	}
+	ok, err := EvaluateThreshold(expr, values)
+	if err != nil {
+		return prev
+	}
+	if upperBoundStat(strings.ReplaceAll(match[1], " ", "")) {
+		return ThresholdResult{OK: ok}
+	}
+	return ThresholdResult{OK: ok && prev.OK}
+}
+
+func mergeMetricValues(target, m *SummaryMetric) {
+	for k, v := range m.Values {
+		switch {
+		case k == "min" && target.Type != "gauge":
+			target.Values[k] = math.Min(target.Values[k], v)
+		case k == "max" && target.Type != "gauge":
+			target.Values[k] = math.Max(target.Values[k], v)
+		case target.Type == "rate" && k == "rate":
+			// recomputed from passes and fails
+		default:
+			// Counters, gauges and the rate's passes and fails are summed. Trend values are
+			// summed here and divided by the number of runners afterward.
+			target.Values[k] += v
+		}
+	}
+}
+
+func mergeGroups(a, b *SummaryGroup) *SummaryGroup {
+	if a == nil {
+		return cloneGroup(b)
+	}
+	if b == nil {
+		return a
+	}
+	for _, check := range b.Checks {
+		found := false
+		for _, existing := range a.Checks {
+			if existing.Path == check.Path {
+				existing.Passes += check.Passes
+				existing.Fails += check.Fails
+				found = true
+				break
+			}
+		}
+		if !found {
+			c := *check
+			a.Checks = append(a.Checks, &c)
+		}
+	}
+	for _, group := range b.Groups {
+		found := false
+		for i, existing := range a.Groups {
+			if existing.Path == group.Path {
+				a.Groups[i] = mergeGroups(existing, group)
+				found = true
+				break
+			}
+		}
+		if !found {
+			a.Groups = append(a.Groups, cloneGroup(group))
+		}
+	}
+	return a
+}
+
+func cloneGroup(g *SummaryGroup) *SummaryGroup {
+	if g == nil {
+		return nil
+	}
+	c := &SummaryGroup{Name: g.Name, Path: g.Path, ID: g.ID}
+	for _, check := range g.Checks {
+		cc := *check
+		c.Checks = append(c.Checks, &cc)
+	}
+	for _, group := range g.Groups {
+		c.Groups = append(c.Groups, cloneGroup(group))
+	}
+	return c
+}
+
+var thresholdRegex = regexp.MustCompile(`^\s*([a-z]+(?:\(\s*[\d.]+\s*\))?)\s*(<=|>=|===|==|!=|<|>)\s*(-?[\d.]+(?:e-?\d+)?)\s*$`)
+
+// EvaluateThreshold checks a k6 threshold expression like `p(95)<500` against metric values.
+func EvaluateThreshold(expr string, values map[string]float64) (bool, error) {
+	match := thresholdRegex.FindStringSubmatch(expr)
+	if match == nil {
+		return false, fmt.Errorf("unsupported threshold expression '%s'", expr)
+	}
+	aggregation := strings.ReplaceAll(match[1], " ", "")
+	actual, ok := values[aggregation]
+	if !ok {
+		return false, fmt.Errorf("the summary does not contain '%s'", aggregation)
+	}
+	expected, err := strconv.ParseFloat(match[3], 64)
+	if err != nil {
+		return false, err
+	}
+	switch match[2] {
+	case "<":
+		return actual < expected, nil
+	case "<=":
+		return actual <= expected, nil
+	case ">":
+		return actual > expected, nil
+	case ">=":
+		return actual >= expected, nil
+	case "==", "===":
+		return actual == expected, nil
+	default:
+		return actual != expected, nil
+	}
+}
+
+// FailedThresholds lists all thresholds that did not pass, sorted by metric name.
+func (s *Summary) FailedThresholds() []FailedThreshold {
+	var failed []FailedThreshold
+	for _, name := range s.MetricNames() {
+		m := s.Metrics[name]
+		for _, expr := range sortedKeys(m.Thresholds) {
+			if !m.Thresholds[expr].OK {
+				failed = append(failed, FailedThreshold{Metric: name, Expression: expr})
+			}
+		}
+	}
+	return failed
+}
+
+// MetricNames returns the names of all metrics in alphabetical order.
+func (s *Summary) MetricNames() []string {
+	return sortedKeys(s.Metrics)
+}
+
+// Checks returns all checks of all groups, depth first.
+func (s *Summary) Checks() []*SummaryCheck {
+	var checks []*SummaryCheck
+	var walk func(g *SummaryGroup)
+	walk = func(g *SummaryGroup) {
+		if g == nil {
+			return
+		}
+		checks = append(checks, g.Checks...)
+		for _, child := range g.Groups {
+			walk(child)
+		}
+	}
+	walk(s.RootGroup)
+	return checks
+}
+
+// String renders the summary in a format similar to k6's own end-of-test summary.
+func (s *Summary) String() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Summary of %d runner(s), duration %s:\n", s.Runners, time.Duration(s.State.TestRunDurationMs*float64(time.Millisecond)).Round(time.Millisecond)))
+	for _, check := range s.Checks() {
+		mark := "✓"
+		if check.Fails > 0 {
+			mark = "✗"
+		}
+		sb.WriteString(fmt.Sprintf("     %s %s (%d passed, %d failed)\n", mark, check.Name, check.Passes, check.Fails))
+	}
+	sb.WriteString("\n")
+	for _, name := range s.MetricNames() {
+		m := s.Metrics[name]
+		mark := " "
+		if len(m.Thresholds) > 0 {
+			mark = "✓"
+			for _, t := range m.Thresholds {
+				if !t.OK {
+					mark = "✗"
+				}
+			}
+		}
+		sb.WriteString(fmt.Sprintf("   %s %s: %s\n", mark, padRight(name, 30, '.'), m.FormatValues()))
+	}
+	if failed := s.FailedThresholds(); len(failed) > 0 {
+		sb.WriteString("\nFailed thresholds:\n")
+		for _, f := range failed {
+			sb.WriteString(fmt.Sprintf("   %s: %s\n", f.Metric, f.Expression))
+		}
+	}
+	return sb.String()
+}
+
+// FormatValues renders the values of a metric in k6's order and units.
+func (m *SummaryMetric) FormatValues() string {
+	var order []string
+	switch m.Type {
+	case "counter":
+		order = []string{"count", "rate"}
+	case "rate":
+		order = []string{"rate", "passes", "fails"}
+	case "gauge":
+		order = []string{"value", "min", "max"}
+	default:
+		order = []string{"avg", "min", "med", "max"}
+		var percentiles []string
+		for k := range m.Values {
+			if strings.HasPrefix(k, "p(") {
+				percentiles = append(percentiles, k)
+			}
+		}
+		sort.Slice(percentiles, func(i, j int) bool {
+			return percentileOf(percentiles[i]) < percentileOf(percentiles[j])
+		})
+		order = append(order, percentiles...)
+	}
+	var parts []string
+	for _, k := range order {
+		v, ok := m.Values[k]
+		if !ok {
+			continue
+		}
+		switch {
+		case m.Type == "rate" && k == "rate":
+			parts = append(parts, fmt.Sprintf("%.2f%%", v*100))
+		case m.Type == "counter" && k == "rate":
+			parts = append(parts, fmt.Sprintf("%s/s", m.formatValue(v)))
+		case k == "passes" || k == "fails":
+			parts = append(parts, fmt.Sprintf("%s=%d", k, int64(v)))
+		case m.Type == "trend" || m.Type == "gauge":
+			parts = append(parts, fmt.Sprintf("%s=%s", k, m.formatValue(v)))
+		default:
+			parts = append(parts, m.formatValue(v))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func (m *SummaryMetric) formatValue(v float64) string {
+	switch m.Contains {
+	case "time":
+		return time.Duration(v * float64(time.Millisecond)).Round(time.Microsecond).String()
+	case "data":
+		units := []string{"B", "kB", "MB", "GB", "TB"}
+		i := 0
+		for v >= 1000 && i < len(units)-1 {
+			v /= 1000
+			i++
+		}
+		return fmt.Sprintf("%.1f %s", v, units[i])
+	default:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+}
+
+func percentileOf(key string) float64 {
+	p, _ := strconv.ParseFloat(strings.TrimSuffix(strings.TrimPrefix(key, "p("), ")"), 64)
+	return p
+}
+
+func padRight(s string, width int, pad rune) string {
+	if len(s) >= width {
+		return s
+	}
+	return s + strings.Repeat(string(pad), width-len(s))
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,108 @@
+package internal_test
+
+import (
+	"fmt"
+	"github.com/brodo/kubectl-k6/internal"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+const runnerLog = `time="2024-05-03T10:00:00Z" level=info msg="running"
+K6K8S_SUMMARY_BEGIN{"metrics":{"http_reqs":{"type":"counter","contains":"default","values":{"count":%d,"rate":%d}},"http_req_failed":{"type":"rate","contains":"default","values":{"rate":0,"passes":%d,"fails":%d},"thresholds":{"rate<0.1":{"ok":%t}}},"http_req_duration":{"type":"trend","contains":"time","values":{"avg":%d,"min":%d,"max":%d,"p(95)":%d},"thresholds":{"p(95)<300":{"ok":%t}}}},"root_group":{"name":"","path":"","checks":[{"name":"is ok","path":"::is ok","passes":%d,"fails":%d}]},"state":{"testRunDurationMs":60000}}K6K8S_SUMMARY_END
+`
+
+func runnerSummary(t *testing.T, count, failed, avg, min, max, p95, checkFails int) *internal.Summary {
+	logs := fmt.Sprintf(runnerLog, count, count/60, failed, count-failed, float64(failed)/float64(count) < 0.1,
+		avg, min, max, p95, p95 < 300, count-checkFails, checkFails)
+	summary, err := internal.ParseSummaryFromLogs(logs)
+	require.NoError(t, err)
+	return summary
+}
+
+func TestMergeSummaries(t *testing.T) {
+	merged := internal.MergeSummaries([]*internal.Summary{
+		runnerSummary(t, 600, 0, 100, 10, 400, 350, 0),
+		runnerSummary(t, 600, 100, 200, 20, 900, 400, 5),
+	})
+	require.Equal(t, 2, merged.Runners)
+	require.Equal(t, float64(1200), merged.Metrics["http_reqs"].Values["count"])
+	require.Equal(t, float64(20), merged.Metrics["http_reqs"].Values["rate"])
+	require.InDelta(t, 0.0833, merged.Metrics["http_req_failed"].Values["rate"], 0.0001)
+	require.Equal(t, float64(10), merged.Metrics["http_req_duration"].Values["min"])
+	require.Equal(t, float64(900), merged.Metrics["http_req_duration"].Values["max"])
+	// one slow runner is not averaged away
+	require.Equal(t, float64(400), merged.Metrics["http_req_duration"].Values["p(95)"])
+	require.Equal(t, float64(150), merged.Metrics["http_req_duration"].Values["avg"])
+	require.Equal(t, []internal.FailedThreshold{{Metric: "http_req_duration", Expression: "p(95)<300"}}, merged.FailedThresholds())
+	require.Len(t, merged.Checks(), 1)
+	require.Equal(t, int64(1195), merged.Checks()[0].Passes)
+	require.Equal(t, int64(5), merged.Checks()[0].Fails)
+}
+
+func TestMergeSummariesRecheckThresholds(t *testing.T) {
+	// The first runner crossed the failure rate threshold on its own, but the combined requests do not.
+	merged := internal.MergeSummaries([]*internal.Summary{
+		runnerSummary(t, 600, 70, 100, 10, 400, 250, 0),
+		runnerSummary(t, 600, 20, 100, 10, 400, 250, 0),
+	})
+	require.Empty(t, merged.FailedThresholds())
+
+	// The second runner crossed the p(95) threshold. The mean of the percentiles would be below it, but the
+	// combined percentile may not be, so the highest percentile is used and the threshold stays failed.
+	merged = internal.MergeSummaries([]*internal.Summary{
+		runnerSummary(t, 600, 0, 100, 10, 400, 250, 0),
+		runnerSummary(t, 600, 0, 100, 10, 400, 310, 0),
+	})
+	require.Equal(t, float64(310), merged.Metrics["http_req_duration"].Values["p(95)"])
+	require.Equal(t, []internal.FailedThreshold{{Metric: "http_req_duration", Expression: "p(95)<300"}}, merged.FailedThresholds())
+}
+
+func TestMergeSummariesTrendThresholds(t *testing.T) {
+	trend := func(avg float64, ok bool) *internal.Summary {
+		return &internal.Summary{Runners: 1, Metrics: map[string]*internal.SummaryMetric{"http_req_duration": {
+			Type:       "trend",
+			Values:     map[string]float64{"avg": avg},
+			Thresholds: map[string]internal.ThresholdResult{"avg<200": {OK: ok}},
+		}}}
+	}
+	// the mean of the averages passes, but the averages of the runners cannot be weighted, so the failure stays
+	merged := internal.MergeSummaries([]*internal.Summary{trend(150, true), trend(210, false)})
+	require.Len(t, merged.FailedThresholds(), 1)
+	merged = internal.MergeSummaries([]*internal.Summary{trend(150, true), trend(190, true)})
+	require.Empty(t, merged.FailedThresholds())
+}
+
+func TestEvaluateThreshold(t *testing.T) {
+	values := map[string]float64{"p(99.9)": 120, "rate": 0.05, "count": 10}
+	for expr, expected := range map[string]bool{
+		"p(99.9)<150":   true,
+		"p(99.9) > 150": false,
+		"rate<=0.05":    true,
+		"count==10":     true,
+		"count!=10":     false,
+	} {
+		ok, err := internal.EvaluateThreshold(expr, values)
+		require.NoError(t, err, expr)
+		require.Equal(t, expected, ok, expr)
+	}
+	_, err := internal.EvaluateThreshold("p(90)<100", values)
+	require.Error(t, err)
+}
+
+func TestBundleInjectsSummary(t *testing.T) {
+	sps := internal.NewScriptProperties("../examples/liveness/liveness.js")
+	err, bundle := internal.Bundle(&sps, false, true)
+	require.NoError(t, err)
+	js := string(bundle)
+	require.Contains(t, js, "K6K8S_SUMMARY_BEGIN")
+	require.Contains(t, js, "handleSummary")
+	require.Contains(t, js, "setup")
+	require.Contains(t, js, "options")
+	// the p99 is compared with baselines, but not in the summary by default
+	require.Contains(t, js, `"p(99)"`)
+}
+
+func TestStripSummary(t *testing.T) {
+	logs := fmt.Sprintf(runnerLog, 1, 1, 0, 1, true, 1, 1, 1, 1, true, 1, 0)
+	require.Equal(t, "time=\"2024-05-03T10:00:00Z\" level=info msg=\"running\"\n", internal.StripSummary(logs))
+}
@@ -25,16 +25,25 @@ func NewTemplateVars(properties ScriptProperties) TemplateVars {
 }
 
 func (tVars *TemplateVars) ApplyArgTemp(args string) (error, string) {
-	argTmpl, err := template.New("argTemplate").Parse(args)
+	return tVars.applyTemp("argTemplate", args)
+}
+
+// ApplyManifestTemp renders the template variables into a pre-authored manifest.
+func (tVars *TemplateVars) ApplyManifestTemp(manifest string) (error, string) {
+	return tVars.applyTemp("manifestTemplate", manifest)
+}
+
+func (tVars *TemplateVars) applyTemp(name, text string) (error, string) {
+	tmpl, err := template.New(name).Parse(text)
 	if err != nil {
 		return err, ""
 	}
-	k6Command := new(bytes.Buffer)
-	err = argTmpl.Execute(k6Command, tVars)
+	out := new(bytes.Buffer)
+	err = tmpl.Execute(out, tVars)
 	if err != nil {
 		return err, ""
 	}
-	return nil, k6Command.String()
+	return nil, out.String()
 }
 
 func (tVars *TemplateVars) ApplyEnvTemp(k6Env *K6Environment) error {
@@ -0,0 +1,226 @@
+package internal
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"strings"
+)
+
+const (
+	TestRunAPIVersion = "k6.io/v1alpha1"
+	TestRunKind       = "TestRun"
+)
+
+// TestRunRunningCondition is the status condition the operator sets while the test is running.
+const TestRunRunningCondition = "TestRunRunning"
+
+// TestRun is the custom resource of the k6 operator. It only contains the fields this plugin uses.
+type TestRun struct {
+	meta.TypeMeta   `json:",inline"`
+	meta.ObjectMeta `json:"metadata,omitempty"`
+	Spec            TestRunSpec   `json:"spec"`
+	Status          TestRunStatus `json:"status,omitempty"`
+}
+
+type TestRunSpec struct {
+	Parallelism int           `json:"parallelism"`
+	Script      TestRunScript `json:"script"`
+	Arguments   string        `json:"arguments,omitempty"`
+	Runner      TestRunPod    `json:"runner,omitempty"`
+	Initializer *TestRunPod   `json:"initializer,omitempty"`
+	Starter     *TestRunPod   `json:"starter,omitempty"`
+	// Paused is "true" if the operator creates the runners, but does not start them.
+	Paused string `json:"paused,omitempty"`
+}
+
+// TestRunScript refers to the script in a config map or a persistent volume claim. Exactly one of both must be set.
+type TestRunScript struct {
+	ConfigMap   *TestRunScriptSource `json:"configMap,omitempty"`
+	VolumeClaim *TestRunScriptSource `json:"volumeClaim,omitempty"`
+}
+
+type TestRunScriptSource struct {
+	Name string `json:"name"`
+	File string `json:"file,omitempty"`
+}
+
+// TestRunPod are the settings of the pods the operator starts.
+type TestRunPod struct {
+	Metadata                  *TestRunPodMetadata           `json:"metadata,omitempty"`
+	Image                     string                        `json:"image,omitempty"`
+	ImagePullSecrets          []v1.LocalObjectReference     `json:"imagePullSecrets,omitempty"`
+	Resources                 *v1.ResourceRequirements      `json:"resources,omitempty"`
+	NodeSelector              map[string]string             `json:"nodeSelector,omitempty"`
+	Tolerations               []v1.Toleration               `json:"tolerations,omitempty"`
+	Affinity                  *v1.Affinity                  `json:"affinity,omitempty"`
+	TopologySpreadConstraints []v1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+	PriorityClassName         string                        `json:"priorityClassName,omitempty"`
+	ServiceAccountName        string                        `json:"serviceAccountName,omitempty"`
+	SecurityContext           *v1.PodSecurityContext        `json:"securityContext,omitempty"`
+	ContainerSecurityContext  *v1.SecurityContext           `json:"containerSecurityContext,omitempty"`
+	Volumes                   []v1.Volume                   `json:"volumes,omitempty"`
+	VolumeMounts              []v1.VolumeMount              `json:"volumeMounts,omitempty"`
+	Env                       []v1.EnvVar                   `json:"env,omitempty"`
+	EnvFrom                   []v1.EnvFromSource            `json:"envFrom,omitempty"`
+	HostAliases               []v1.HostAlias                `json:"hostAliases,omitempty"`
+}
+
+type TestRunPodMetadata struct {
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type TestRunStatus struct {
+	Stage      string           `json:"stage,omitempty"`
+	TestRunID  string           `json:"testRunId,omitempty"`
+	Conditions []meta.Condition `json:"conditions,omitempty"`
+}
+
+// NewTestRun creates the custom resource for a script.
+func NewTestRun(k6Conf *K6Config, tVars *TemplateVars) (*TestRun, error) {
+	tr := &TestRun{
+		TypeMeta:   meta.TypeMeta{APIVersion: TestRunAPIVersion, Kind: TestRunKind},
+		ObjectMeta: meta.ObjectMeta{Name: tVars.ResourceName()},
+		Spec: TestRunSpec{
+			Parallelism: k6Conf.Parallelism,
+			Arguments:   k6Conf.Args,
+			Runner:      k6Conf.Runner.ToPod(tVars.ResourceName()),
+		},
+	}
+	if k6Conf.Folder == "" {
+		tr.Spec.Script.ConfigMap = &TestRunScriptSource{Name: tVars.ConfigMapName(), File: "out.js"}
+	} else {
+		tr.Spec.Script.VolumeClaim = &TestRunScriptSource{Name: tVars.ConfigMapName(), File: k6Conf.FilePath}
+	}
+	if pod := k6Conf.Initializer.ToPod(tVars.ResourceName()); !pod.isEmpty() {
+		tr.Spec.Initializer = &pod
+	}
+	if pod := k6Conf.Starter.ToPod(tVars.ResourceName()); !pod.isEmpty() {
+		tr.Spec.Starter = &pod
+	}
+	if k6Conf.Paused {
+		tr.Spec.Paused = "true"
+	}
+	runner := &tr.Spec.Runner
+	runner.Env = append(runner.Env, k6Conf.Env.ToEnvVars()...)
+	if k6Conf.EnvSecret != "" {
+		runner.EnvFrom = append(runner.EnvFrom, v1.EnvFromSource{SecretRef: &v1.SecretEnvSource{
+			LocalObjectReference: v1.LocalObjectReference{Name: k6Conf.EnvSecret},
+		}})
+	}
+	if k6Conf.Image != "" {
+		runner.Image = k6Conf.Image
+	}
+	if k6Conf.ImagePullSecret != "" {
+		runner.ImagePullSecrets = append(runner.ImagePullSecrets, v1.LocalObjectReference{Name: k6Conf.ImagePullSecret})
+	}
+	return tr, tr.Validate()
+}
+
+// Validate checks the fields the API server does not validate before the operator picks up the test run.
+func (tr *TestRun) Validate() error {
+	var problems []string
+	for _, msg := range validation.IsDNS1123Subdomain(tr.Name) {
+		problems = append(problems, fmt.Sprintf("invalid name '%s': %s", tr.Name, msg))
+	}
+	if tr.Spec.Parallelism < 1 {
+		problems = append(problems, fmt.Sprintf("the parallelism must be at least 1, got %d", tr.Spec.Parallelism))
+	}
+	script := tr.Spec.Script
+	switch {
+	case (script.ConfigMap == nil) == (script.VolumeClaim == nil):
+		problems = append(problems, "the script must be read from either a config map or a volume claim")
+	case script.ConfigMap != nil && (script.ConfigMap.Name == "" || script.ConfigMap.File == ""):
+		problems = append(problems, "the script's config map needs a name and a file")
+	case script.VolumeClaim != nil && (script.VolumeClaim.Name == "" || script.VolumeClaim.File == ""):
+		problems = append(problems, "the script's volume claim needs a name and a file")
+	}
+	pods := map[string]*TestRunPod{"runner": &tr.Spec.Runner, "initializer": tr.Spec.Initializer, "starter": tr.Spec.Starter}
+	for _, field := range []string{"runner", "initializer", "starter"} {
+		if pods[field] != nil {
+			problems = append(problems, pods[field].validate(field)...)
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid test run '%s': %s", tr.Name, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func (pod *TestRunPod) validate(field string) []string {
+	var problems []string
+	for _, env := range pod.Env {
+		for _, msg := range validation.IsEnvVarName(env.Name) {
+			problems = append(problems, fmt.Sprintf("%s: invalid environment variable name '%s': %s", field, env.Name, msg))
+		}
+	}
+	for _, secret := range pod.ImagePullSecrets {
+		if secret.Name == "" {
+			problems = append(problems, fmt.Sprintf("%s: image pull secrets need a name", field))
+		}
+	}
+	return problems
+}
+
+func (pod *TestRunPod) isEmpty() bool {
+	empty, _ := runtime.DefaultUnstructuredConverter.ToUnstructured(pod)
+	return len(empty) == 0
+}
+
+// ToUnstructured converts the test run into the object the dynamic client submits. The status is left out.
+func (tr *TestRun) ToUnstructured() (*unstructured.Unstructured, error) {
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(tr)
+	if err != nil {
+		return nil, err
+	}
+	delete(object, "status")
+	if metadata, ok := object["metadata"].(map[string]interface{}); ok {
+		delete(metadata, "creationTimestamp")
+	}
+	return &unstructured.Unstructured{Object: object}, nil
+}
+
+// TestRunFromUnstructured converts an object returned by the dynamic client. Missing fields, like the status
+// right after the test run was created, are left empty.
+func TestRunFromUnstructured(u *unstructured.Unstructured) (*TestRun, error) {
+	tr := &TestRun{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, tr); err != nil {
+		return nil, fmt.Errorf("unexpected content in test run '%s': %w", u.GetName(), err)
+	}
+	return tr, nil
+}
+
+// CurrentStage returns the stage the operator reported. The stage is read from the status and, if the operator
+// has not set it, from the TestRunRunning condition. ok is false if the operator has not reported anything yet.
+func (s *TestRunStatus) CurrentStage() (stage Stage, ok bool) {
+	if stage, ok := stages[s.Stage]; ok {
+		return stage, true
+	}
+	if s.Stage != "" {
+		return InitializationStage, false
+	}
+	for _, c := range s.Conditions {
+		if c.Type == TestRunRunningCondition && c.Status == meta.ConditionTrue {
+			return StartedStage, true
+		}
+	}
+	return InitializationStage, false
+}
+
+// Failed reports whether the operator marked the test run as failed. The message describes the reason if the
+// operator provided one.
+func (s *TestRunStatus) Failed() (bool, string) {
+	if s.Stage != "error" {
+		return false, ""
+	}
+	for _, c := range s.Conditions {
+		if c.Status == meta.ConditionFalse && c.Message != "" {
+			return true, fmt.Sprintf("%s: %s", c.Reason, c.Message)
+		}
+	}
+	return true, ""
+}
@@ -0,0 +1,62 @@
+package internal_test
+
+import (
+	"github.com/brodo/kubectl-k6/internal"
+	"github.com/stretchr/testify/require"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"testing"
+)
+
+func TestTestRun(t *testing.T) {
+	tVars := internal.NewTemplateVars(internal.NewScriptProperties("liveness.js"))
+	k6Conf := internal.NewK6Config(internal.K6Environment{"target": "http://example.com"}, "--tag a=b", "grafana/k6", 2, "", "", "liveness.js")
+	k6Conf.EnvSecret = "k6-env"
+	tr, err := internal.NewTestRun(&k6Conf, &tVars)
+	require.NoError(t, err)
+	require.Nil(t, tr.Spec.Initializer)
+	require.Empty(t, tr.Spec.Runner.ImagePullSecrets)
+
+	u, err := tr.ToUnstructured()
+	require.NoError(t, err)
+	require.Equal(t, "k6.io/v1alpha1", u.GetAPIVersion())
+	require.NotContains(t, u.Object, "status")
+	spec := u.Object["spec"].(map[string]interface{})
+	require.Equal(t, map[string]interface{}{"name": tVars.ConfigMapName(), "file": "out.js"}, spec["script"].(map[string]interface{})["configMap"])
+	runner := spec["runner"].(map[string]interface{})
+	require.Equal(t, []interface{}{map[string]interface{}{"name": "TARGET", "value": "http://example.com"}}, runner["env"])
+	require.Equal(t, "grafana/k6", runner["image"])
+
+	// right after creation the operator has not set a status yet
+	created, err := internal.TestRunFromUnstructured(u)
+	require.NoError(t, err)
+	_, ok := created.Status.CurrentStage()
+	require.False(t, ok)
+	failed, _ := created.Status.Failed()
+	require.False(t, failed)
+
+	running, err := internal.TestRunFromUnstructured(&unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "k6.io/v1alpha1",
+		"kind":       "TestRun",
+		"metadata":   map[string]interface{}{"name": "run-liveness"},
+		"status": map[string]interface{}{"conditions": []interface{}{map[string]interface{}{
+			"type": "TestRunRunning", "status": "True", "reason": "TestRunRunningTrue", "message": "", "lastTransitionTime": "2024-05-01T10:00:00Z",
+		}}},
+	}})
+	require.NoError(t, err)
+	stage, ok := running.Status.CurrentStage()
+	require.True(t, ok)
+	require.Equal(t, internal.StartedStage, stage)
+
+	running.Status.Stage = "error"
+	running.Status.Conditions = append(running.Status.Conditions, meta.Condition{Type: "TestRunRunning", Status: meta.ConditionFalse, Reason: "Error", Message: "runner failed"})
+	failed, reason := running.Status.Failed()
+	require.True(t, failed)
+	require.Equal(t, "Error: runner failed", reason)
+
+	k6Conf.Parallelism = 0
+	k6Conf.Env = internal.K6Environment{"1st": "x"}
+	_, err = internal.NewTestRun(&k6Conf, &tVars)
+	require.ErrorContains(t, err, "the parallelism must be at least 1")
+	require.ErrorContains(t, err, "invalid environment variable name '1ST'")
+}
@@ -0,0 +1,341 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StageTimeouts limit how long the plugin waits for each stage of a run.
+type StageTimeouts struct {
+	Initialization time.Duration `mapstructure:"initialization"`
+	InitJob        time.Duration `mapstructure:"initJob"`
+	JobCreation    time.Duration `mapstructure:"jobCreation"`
+	// Run is derived from the script's options if it is zero, see RunTimeout.
+	Run time.Duration `mapstructure:"run"`
+}
+
+// DefaultRunTimeout is used if the duration of a script cannot be determined.
+const DefaultRunTimeout = time.Hour
+
+const (
+	// minRunTimeoutMargin is added to the declared duration of a script to cover scheduling and the teardown.
+	minRunTimeoutMargin = 5 * time.Minute
+	// defaults of k6, see https://grafana.com/docs/k6/latest/using-k6/scenarios/
+	defaultGracefulStop     = 30 * time.Second
+	defaultGracefulRampDown = 30 * time.Second
+	defaultMaxDuration      = 10 * time.Minute
+)
+
+// RunTimeout returns the timeout of the run stage for a script that runs for the declared duration. The margin is
+// 10 percent of the duration, but at least five minutes.
+func RunTimeout(declared time.Duration) time.Duration {
+	return declared + max(minRunTimeoutMargin, declared/10)
+}
+
+// optionsDeclaration matches the start of `export const options = {`, with an optional TypeScript type.
+var optionsDeclaration = regexp.MustCompile(`(?:^|[\s;])(?:export\s+)?(?:const|let|var)\s+options\s*(?::\s*[\w.]+\s*)?=\s*\{`)
+
+// ScriptDuration determines how long a script runs at most from the options it declares, including the graceful
+// stops of k6. The options are read statically, so they must be declared as a literal in the script; k6args
+// override them like on the command line of k6. An error is returned if the duration cannot be determined.
+func ScriptDuration(source string, k6args string) (time.Duration, error) {
+	if d, ok, err := argsDuration(k6args); ok || err != nil {
+		return d, err
+	}
+	loc := optionsDeclaration.FindStringIndex(source)
+	if loc == nil {
+		return 0, fmt.Errorf("the script does not declare its options as 'export const options = {...}'")
+	}
+	literal, err := objectLiteral(source[loc[1]-1:])
+	if err != nil {
+		return 0, err
+	}
+	jsonOptions, err := literalToJSON(literal)
+	if err != nil {
+		return 0, fmt.Errorf("the options of the script are not a literal: %w", err)
+	}
+	var options k6Options
+	if err := json.Unmarshal([]byte(jsonOptions), &options); err != nil {
+		return 0, fmt.Errorf("unexpected options in the script: %w", err)
+	}
+	return options.duration()
+}
+
+// argsDuration returns the duration set by the `--duration` or `--stage` arguments of k6. ok is false if neither
+// is set.
+func argsDuration(k6args string) (d time.Duration, ok bool, err error) {
+	args := strings.Fields(k6args)
+	var stages []k6Stage
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(args[i], "=")
+		if name != "--duration" && name != "-d" && name != "--stage" && name != "-s" {
+			continue
+		}
+		if !hasValue {
+			if i+1 == len(args) {
+				return 0, false, fmt.Errorf("the argument '%s' has no value", name)
+			}
+			i++
+			value = args[i]
+		}
+		value = strings.Trim(value, `'"`)
+		switch name {
+		case "--duration", "-d":
+			duration, err := parseK6Duration(value)
+			if err != nil {
+				return 0, false, err
+			}
+			return duration + defaultGracefulStop, true, nil
+		default:
+			for _, stage := range strings.Split(value, ",") {
+				target, _, _ := strings.Cut(stage, ":")
+				duration, err := parseK6Duration(target)
+				if err != nil {
+					return 0, false, err
+				}
+				stages = append(stages, k6Stage{Duration: k6Duration(duration)})
+			}
+		}
+	}
+	if len(stages) == 0 {
+		return 0, false, nil
+	}
+	return sumStages(stages) + defaultGracefulRampDown + defaultGracefulStop, true, nil
+}
+
+type k6Options struct {
+	Duration   *k6Duration           `json:"duration"`
+	Iterations int                   `json:"iterations"`
+	Stages     []k6Stage             `json:"stages"`
+	Scenarios  map[string]k6Scenario `json:"scenarios"`
+}
+
+type k6Stage struct {
+	Duration k6Duration `json:"duration"`
+}
+
+type k6Scenario struct {
+	Executor         string      `json:"executor"`
+	StartTime        k6Duration  `json:"startTime"`
+	Duration         *k6Duration `json:"duration"`
+	MaxDuration      *k6Duration `json:"maxDuration"`
+	GracefulStop     *k6Duration `json:"gracefulStop"`
+	GracefulRampDown *k6Duration `json:"gracefulRampDown"`
+	Stages           []k6Stage   `json:"stages"`
+}
+
+func (o k6Options) duration() (time.Duration, error) {
+	if len(o.Scenarios) == 0 {
+		// k6 turns the shortcut options into a single scenario
+		switch {
+		case len(o.Stages) > 0:
+			return k6Scenario{Executor: "ramping-vus", Stages: o.Stages}.duration()
+		case o.Duration != nil:
+			return k6Scenario{Executor: "constant-vus", Duration: o.Duration}.duration()
+		}
+		return k6Scenario{Executor: "shared-iterations"}.duration()
+	}
+	var longest time.Duration
+	for name, scenario := range o.Scenarios {
+		d, err := scenario.duration()
+		if err != nil {
+			return 0, fmt.Errorf("scenario '%s': %w", name, err)
+		}
+		longest = max(longest, d)
+	}
+	return longest, nil
+}
+
+func (s k6Scenario) duration() (time.Duration, error) {
+	gracefulStop := durationOr(s.GracefulStop, defaultGracefulStop)
+	var d time.Duration
+	switch s.Executor {
+	case "constant-vus", "constant-arrival-rate":
+		if s.Duration == nil {
+			return 0, fmt.Errorf("the executor '%s' needs a duration", s.Executor)
+		}
+		d = time.Duration(*s.Duration) + gracefulStop
+	case "ramping-vus":
+		d = sumStages(s.Stages) + durationOr(s.GracefulRampDown, defaultGracefulRampDown) + gracefulStop
+	case "ramping-arrival-rate":
+		d = sumStages(s.Stages) + gracefulStop
+	case "per-vu-iterations", "shared-iterations":
+		d = durationOr(s.MaxDuration, defaultMaxDuration) + gracefulStop
+	case "externally-controlled":
+		if s.Duration == nil || *s.Duration == 0 {
+			return 0, fmt.Errorf("the executor '%s' runs until it is stopped", s.Executor)
+		}
+		d = time.Duration(*s.Duration)
+	default:
+		return 0, fmt.Errorf("unknown executor '%s'", s.Executor)
+	}
+	return time.Duration(s.StartTime) + d, nil
+}
+
+func sumStages(stages []k6Stage) time.Duration {
+	var sum time.Duration
+	for _, stage := range stages {
+		sum += time.Duration(stage.Duration)
+	}
+	return sum
+}
+
+func durationOr(d *k6Duration, fallback time.Duration) time.Duration {
+	if d == nil {
+		return fallback
+	}
+	return time.Duration(*d)
+}
+
+// k6Duration is a duration in the options of k6: a string like '1m30s' or a number of milliseconds.
+type k6Duration time.Duration
+
+func (d *k6Duration) UnmarshalJSON(data []byte) error {
+	var ms float64
+	if err := json.Unmarshal(data, &ms); err == nil {
+		*d = k6Duration(ms * float64(time.Millisecond))
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid duration %s", data)
+	}
+	parsed, err := parseK6Duration(s)
+	*d = k6Duration(parsed)
+	return err
+}
+
+// MarshalJSON writes the duration as a string, so it is read back in the same unit.
+func (d k6Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func parseK6Duration(s string) (time.Duration, error) {
+	if ms, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(ms * float64(time.Millisecond)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration '%s'", s)
+	}
+	return d, nil
+}
+
+// objectLiteral returns the object literal at the start of source, up to its closing brace.
+func objectLiteral(source string) (string, error) {
+	depth := 0
+	for i := 0; i < len(source); i++ {
+		switch c := source[i]; {
+		case c == '"' || c == '\'' || c == '`':
+			end, err := stringEnd(source, i)
+			if err != nil {
+				return "", err
+			}
+			i = end
+		case c == '/' && i+1 < len(source) && (source[i+1] == '/' || source[i+1] == '*'):
+			i = commentEnd(source, i)
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			depth--
+			if depth == 0 {
+				return source[:i+1], nil
+			}
+		}
+	}
+	return "", fmt.Errorf("the options of the script are not closed")
+}
+
+// stringEnd returns the index of the quote that closes the string starting at start.
+func stringEnd(source string, start int) (int, error) {
+	quote := source[start]
+	for i := start + 1; i < len(source); i++ {
+		switch source[i] {
+		case '\\':
+			i++
+		case quote:
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated string in the options of the script")
+}
+
+// commentEnd returns the index of the last character of the comment starting at start.
+func commentEnd(source string, start int) int {
+	if source[start+1] == '/' {
+		if end := strings.IndexByte(source[start:], '\n'); end >= 0 {
+			return start + end
+		}
+		return len(source) - 1
+	}
+	if end := strings.Index(source[start+2:], "*/"); end >= 0 {
+		return start + 2 + end + 1
+	}
+	return len(source) - 1
+}
+
+var (
+	identifier = regexp.MustCompile(`^[A-Za-z_$][\w$]*`)
+	number     = regexp.MustCompile(`^-?(?:\d+\.?\d*|\.\d+)(?:[eE][+-]?\d+)?`)
+)
+
+// literalToJSON converts a JavaScript object literal without expressions into JSON.
+func literalToJSON(literal string) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(literal); i++ {
+		c := literal[i]
+		rest := literal[i:]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+		case c == '/' && i+1 < len(literal) && (literal[i+1] == '/' || literal[i+1] == '*'):
+			i = commentEnd(literal, i)
+		case c == '"' || c == '\'' || c == '`':
+			end, err := stringEnd(literal, i)
+			if err != nil {
+				return "", err
+			}
+			content := literal[i+1 : end]
+			if c == '`' && strings.Contains(content, "${") {
+				return "", fmt.Errorf("template literal %s", literal[i:end+1])
+			}
+			if c == '"' {
+				content = strings.ReplaceAll(content, `\'`, `'`)
+			} else {
+				content = strings.ReplaceAll(strings.ReplaceAll(content, `\'`, `'`), `"`, `\"`)
+			}
+			sb.WriteString(`"` + content + `"`)
+			i = end
+		case c == '{' || c == '[' || c == ':' || c == ',':
+			sb.WriteByte(c)
+		case c == '}' || c == ']':
+			// JSON does not allow trailing commas
+			s := strings.TrimSuffix(sb.String(), ",")
+			sb.Reset()
+			sb.WriteString(s)
+			sb.WriteByte(c)
+		case number.MatchString(rest):
+			n := number.FindString(rest)
+			sb.WriteString(n)
+			i += len(n) - 1
+		case identifier.MatchString(rest):
+			name := identifier.FindString(rest)
+			next := strings.TrimLeft(literal[i+len(name):], " \t\r\n")
+			switch {
+			case strings.HasPrefix(next, ":"):
+				sb.WriteString(`"` + name + `"`)
+			case name == "true" || name == "false" || name == "null":
+				sb.WriteString(name)
+			default:
+				return "", fmt.Errorf("'%s' is not a literal value", name)
+			}
+			i += len(name) - 1
+		default:
+			return "", fmt.Errorf("unexpected '%c'", c)
+		}
+	}
+	return sb.String(), nil
+}
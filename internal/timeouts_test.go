@@ -0,0 +1,86 @@
+package internal_test
+
+import (
+	"github.com/brodo/kubectl-k6/internal"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestScriptDuration(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		args     string
+		expected time.Duration
+	}{
+		{
+			name:     "constant VUs",
+			source:   `export const options = { vus: 10, duration: '5m' };`,
+			expected: 5*time.Minute + 30*time.Second,
+		},
+		{
+			name: "stages in TypeScript",
+			source: `import { Options } from 'k6/options';
+// the options are read statically
+export const options: Options = {
+  stages: [
+    { duration: "1m", target: 20 }, // ramp up
+    { duration: "3m30s", target: 20 },
+    { duration: 30000, target: 0 },
+  ],
+};`,
+			expected: 5*time.Minute + time.Minute,
+		},
+		{
+			name: "scenarios",
+			source: `export let options = {
+  scenarios: {
+    "browse": { executor: 'constant-arrival-rate', duration: '10m', rate: 5, gracefulStop: '0s' },
+    checkout: { executor: 'per-vu-iterations', startTime: '8m', maxDuration: '5m', exec: "checkout" },
+  },
+  thresholds: { 'http_req_duration{scenario:browse}': ['p(95)<500'] },
+}`,
+			expected: 13*time.Minute + 30*time.Second,
+		},
+		{
+			name:     "no duration",
+			source:   `export const options = { iterations: 10 };`,
+			expected: 10*time.Minute + 30*time.Second,
+		},
+		{
+			name:     "duration argument",
+			source:   `export const options = { vus: 10, duration: '5m' };`,
+			args:     "--vus 5 --duration=20m",
+			expected: 20*time.Minute + 30*time.Second,
+		},
+		{
+			name:     "stage argument",
+			args:     "-s 1m:10,2m:0",
+			expected: 4 * time.Minute,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d, err := internal.ScriptDuration(test.source, test.args)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, d)
+		})
+	}
+}
+
+func TestScriptDuration_Unknown(t *testing.T) {
+	_, err := internal.ScriptDuration(`export default function () {}`, "")
+	require.ErrorContains(t, err, "does not declare its options")
+
+	_, err = internal.ScriptDuration(`export const options = { duration: __ENV.DURATION };`, "")
+	require.ErrorContains(t, err, "'__ENV' is not a literal value")
+
+	_, err = internal.ScriptDuration(`export const options = { scenarios: { manual: { executor: 'externally-controlled' } } };`, "")
+	require.ErrorContains(t, err, "runs until it is stopped")
+}
+
+func TestRunTimeout(t *testing.T) {
+	require.Equal(t, 15*time.Minute, internal.RunTimeout(10*time.Minute))
+	require.Equal(t, 110*time.Minute, internal.RunTimeout(100*time.Minute))
+}
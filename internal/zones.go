@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"maps"
+	"slices"
+)
+
+// Zone is a group of runners, e.g. in a node pool or an availability zone. Every zone gets its own test run.
+type Zone struct {
+	Name         string            `json:"name"`
+	Parallelism  int               `json:"parallelism"`
+	NodeSelector map[string]string `json:"nodeSelector"`
+	Tolerations  []v1.Toleration   `json:"tolerations"`
+}
+
+// maxZoneNameLength keeps the names of the zones' test runs short enough for the operator. The name of a test run,
+// `run-<run id>-<zone>`, is the value of a label and so are the names of its jobs, of which `<name>-initializer` is
+// the longest. Label values are limited to 63 characters.
+const maxZoneNameLength = 63 - len("run-") - runIdLength - len("-") - len("-initializer")
+
+// ParseZones decodes the `zones` section of the configuration. Zones need unique names that are valid in the names
+// of Kubernetes objects; the parallelism defaults to 1.
+func ParseZones(value interface{}) ([]Zone, error) {
+	var zones []Zone
+	if value == nil {
+		return zones, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return zones, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&zones); err != nil {
+		return zones, err
+	}
+	var names []string
+	for i, zone := range zones {
+		if msgs := validation.IsDNS1123Label(zone.Name); len(msgs) > 0 {
+			return zones, fmt.Errorf("invalid zone name '%s': %s", zone.Name, msgs[0])
+		}
+		if len(zone.Name) > maxZoneNameLength {
+			return zones, fmt.Errorf("the zone name '%s' is too long, the names of its test run and jobs must fit into labels: at most %d characters are allowed", zone.Name, maxZoneNameLength)
+		}
+		if slices.Contains(names, zone.Name) {
+			return zones, fmt.Errorf("the zone '%s' is defined twice", zone.Name)
+		}
+		names = append(names, zone.Name)
+		if zone.Parallelism == 0 {
+			zones[i].Parallelism = 1
+		}
+		if zones[i].Parallelism < 0 {
+			return zones, fmt.Errorf("the parallelism of zone '%s' must be at least 1", zone.Name)
+		}
+	}
+	return zones, nil
+}
+
+// ScriptProperties returns the properties of the zone's test run, which is named after the run and the zone.
+func (z Zone) ScriptProperties(sps ScriptProperties) ScriptProperties {
+	sps.Name = fmt.Sprintf("%s-%s", sps.ResourceName(), z.Name)
+	return sps
+}
+
+// K6Config returns the configuration of the zone's test run: the zone's parallelism, its node selector and
+// tolerations added to the runner's, and paused, so all zones can be started together.
+func (z Zone) K6Config(base K6Config) K6Config {
+	k6Conf := base
+	k6Conf.Parallelism = z.Parallelism
+	k6Conf.Paused = true
+	k6Conf.Runner.NodeSelector = maps.Clone(base.Runner.NodeSelector)
+	if k6Conf.Runner.NodeSelector == nil && len(z.NodeSelector) > 0 {
+		k6Conf.Runner.NodeSelector = make(map[string]string, len(z.NodeSelector))
+	}
+	maps.Copy(k6Conf.Runner.NodeSelector, z.NodeSelector)
+	k6Conf.Runner.Tolerations = append(slices.Clone(base.Runner.Tolerations), z.Tolerations...)
+	return k6Conf
+}
@@ -0,0 +1,76 @@
+package internal_test
+
+import (
+	"github.com/brodo/kubectl-k6/internal"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"strings"
+	"testing"
+)
+
+func TestParseZones(t *testing.T) {
+	zones, err := internal.ParseZones([]interface{}{
+		map[string]interface{}{"name": "eu-west-1a", "parallelism": 2, "nodeSelector": map[string]interface{}{"topology.kubernetes.io/zone": "eu-west-1a"}},
+		map[string]interface{}{"name": "spot", "tolerations": []interface{}{map[string]interface{}{"key": "spot", "operator": "Exists", "effect": "NoSchedule"}}},
+	})
+	require.NoError(t, err)
+	require.Len(t, zones, 2)
+	require.Equal(t, 2, zones[0].Parallelism)
+	require.Equal(t, 1, zones[1].Parallelism)
+	require.Equal(t, v1.TaintEffectNoSchedule, zones[1].Tolerations[0].Effect)
+
+	zones, err = internal.ParseZones(nil)
+	require.NoError(t, err)
+	require.Empty(t, zones)
+
+	_, err = internal.ParseZones([]interface{}{map[string]interface{}{"name": "a", "nodeSelctor": map[string]interface{}{}}})
+	require.ErrorContains(t, err, "nodeSelctor")
+	_, err = internal.ParseZones([]interface{}{map[string]interface{}{"name": "EU West"}})
+	require.ErrorContains(t, err, "invalid zone name 'EU West'")
+	// the initializer job of the zone's test run is named run-<run id>-<zone>-initializer, which must fit into a label
+	longest := strings.Repeat("z", 26)
+	_, err = internal.ParseZones([]interface{}{map[string]interface{}{"name": longest}})
+	require.NoError(t, err)
+	sps := internal.NewScriptProperties("test.js")
+	zoneSps := internal.Zone{Name: longest}.ScriptProperties(sps)
+	require.Len(t, zoneSps.InitJobName(), 63)
+	_, err = internal.ParseZones([]interface{}{map[string]interface{}{"name": longest + "z"}})
+	require.ErrorContains(t, err, "at most 26 characters are allowed")
+	_, err = internal.ParseZones([]interface{}{map[string]interface{}{"name": "a"}, map[string]interface{}{"name": "a"}})
+	require.ErrorContains(t, err, "the zone 'a' is defined twice")
+	_, err = internal.ParseZones([]interface{}{map[string]interface{}{"name": "a", "parallelism": -1}})
+	require.ErrorContains(t, err, "must be at least 1")
+}
+
+func TestZone(t *testing.T) {
+	sps := internal.ScriptProperties{RunId: "l4q5ph7vsplt2pxkkv4l"}
+	zone := internal.Zone{
+		Name:         "spot",
+		Parallelism:  3,
+		NodeSelector: map[string]string{"pool": "spot"},
+		Tolerations:  []v1.Toleration{{Key: "spot", Operator: v1.TolerationOpExists}},
+	}
+	zoneSps := zone.ScriptProperties(sps)
+	require.Equal(t, "run-l4q5ph7vsplt2pxkkv4l-spot", zoneSps.ResourceName())
+	// all zones run the same uploaded script
+	require.Equal(t, sps.ConfigMapName(), zoneSps.ConfigMapName())
+
+	base := internal.NewK6Config(internal.K6Environment{}, "", "grafana/k6", 1, "", "", "test.js")
+	base.Runner.NodeSelector = map[string]string{"arch": "arm64"}
+	base.Runner.Tolerations = []v1.Toleration{{Key: "load", Operator: v1.TolerationOpExists}}
+	k6Conf := zone.K6Config(base)
+	require.Equal(t, 3, k6Conf.Parallelism)
+	require.True(t, k6Conf.Paused)
+	require.Equal(t, map[string]string{"arch": "arm64", "pool": "spot"}, k6Conf.Runner.NodeSelector)
+	require.Len(t, k6Conf.Runner.Tolerations, 2)
+	// the other zones start from the same configuration
+	require.Equal(t, map[string]string{"arch": "arm64"}, base.Runner.NodeSelector)
+	require.Len(t, base.Runner.Tolerations, 1)
+	require.False(t, base.Paused)
+
+	tVars := internal.NewTemplateVars(zoneSps)
+	tr, err := internal.NewTestRun(&k6Conf, &tVars)
+	require.NoError(t, err)
+	require.Equal(t, "run-l4q5ph7vsplt2pxkkv4l-spot", tr.Name)
+	require.Equal(t, "true", tr.Spec.Paused)
+}